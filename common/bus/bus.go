@@ -0,0 +1,42 @@
+// Package bus is the publish/subscribe interface the services use to exchange messages, with a
+// Kafka implementation for deployments and an in-process one for tests and local development.
+package bus
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// Message is one record on a topic.
+type Message struct {
+	Topic string
+	Key   []byte
+	Value []byte
+}
+
+// Handler processes one message. Subscribers call it sequentially, so a handler that blocks
+// applies backpressure to its topic.
+type Handler func(msg Message)
+
+// Bus publishes to and subscribes from named topics.
+type Bus interface {
+	// Publish sends value on topic. Messages with the same key keep their order.
+	Publish(ctx context.Context, topic string, key, value []byte) error
+	// Subscribe delivers every message on topic to handler, sharing the topic with any other
+	// subscriber in the same group (each message goes to one of them). It blocks until ctx is
+	// cancelled or the bus is closed. New groups start from the oldest retained message.
+	Subscribe(ctx context.Context, topic, group string, handler Handler) error
+	Close() error
+}
+
+// Brokers reads a comma-separated broker list from the first set variable in names,
+// defaulting to localhost:9092.
+func Brokers(names ...string) []string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return strings.Split(v, ",")
+		}
+	}
+	return []string{"localhost:9092"}
+}
@@ -0,0 +1,73 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka is a Bus backed by a Kafka cluster. Topics must already exist (deploy.sh creates them).
+type Kafka struct {
+	brokers []string
+	writer  *kafka.Writer
+}
+
+// NewKafka connects lazily: nothing is dialled until the first Publish or Subscribe.
+func NewKafka(brokers []string) *Kafka {
+	return &Kafka{
+		brokers: brokers,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 10 * time.Millisecond,
+			// acks=all: a message is only published once every in-sync replica has it
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+// Publish writes one message and waits for every in-sync replica to acknowledge it.
+func (k *Kafka) Publish(ctx context.Context, topic string, key, value []byte) error {
+	if err := k.writer.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value}); err != nil {
+		return fmt.Errorf("publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe consumes topic as part of group, committing each message once handler returns.
+func (k *Kafka) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     k.brokers,
+		Topic:       topic,
+		GroupID:     group,
+		StartOffset: kafka.FirstOffset,
+	})
+	defer reader.Close()
+
+	for {
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			log.Printf("❌ Kafka read error (%s): %v", topic, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		handler(Message{Topic: m.Topic, Key: m.Key, Value: m.Value})
+
+		if err := reader.CommitMessages(ctx, m); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️ Kafka commit failed (%s @ %d): %v", topic, m.Offset, err)
+		}
+	}
+}
+
+// Close flushes pending writes.
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
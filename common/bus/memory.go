@@ -0,0 +1,92 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by Publish after Close.
+var ErrClosed = errors.New("bus closed")
+
+// Memory is an in-process Bus. Each topic is an append-only log and each group keeps its own
+// offset into it, mirroring Kafka consumer groups closely enough to run the whole pipeline in one
+// process. Nothing is persisted and the logs are never trimmed, so it is for tests and local
+// development only.
+type Memory struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	topics map[string]*memoryTopic
+	closed bool
+}
+
+type memoryTopic struct {
+	log     []Message
+	offsets map[string]int
+}
+
+// NewMemory returns an empty in-process bus.
+func NewMemory() *Memory {
+	m := &Memory{topics: make(map[string]*memoryTopic)}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// topic must be called with mu held.
+func (m *Memory) topic(name string) *memoryTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memoryTopic{offsets: make(map[string]int)}
+		m.topics[name] = t
+	}
+	return t
+}
+
+// Publish appends the message to the topic's log.
+func (m *Memory) Publish(ctx context.Context, topic string, key, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	t := m.topic(topic)
+	t.log = append(t.log, Message{Topic: topic, Key: key, Value: value})
+	m.cond.Broadcast()
+	return nil
+}
+
+// Subscribe hands each message to exactly one subscriber of group, in publish order.
+func (m *Memory) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	stop := context.AfterFunc(ctx, func() {
+		m.mu.Lock()
+		m.cond.Broadcast()
+		m.mu.Unlock()
+	})
+	defer stop()
+
+	for {
+		m.mu.Lock()
+		t := m.topic(topic)
+		for t.offsets[group] >= len(t.log) && !m.closed && ctx.Err() == nil {
+			m.cond.Wait()
+		}
+		if m.closed || ctx.Err() != nil {
+			m.mu.Unlock()
+			return nil
+		}
+		msg := t.log[t.offsets[group]]
+		t.offsets[group]++
+		m.mu.Unlock()
+
+		handler(msg)
+	}
+}
+
+// Close wakes every subscriber and makes them return.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,17 @@
+// Command contractcheck runs the message contract compatibility suite against the recorded fixtures,
+// as go test does in contracttest.
+package main
+
+import (
+	"log"
+
+	"common/contracts"
+	"common/contracts/contracttest"
+)
+
+func main() {
+	if err := contracttest.Run(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Printf("✅ Message contracts (schema v%d) are compatible with every fixture", contracts.SchemaVersion)
+}
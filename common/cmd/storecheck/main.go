@@ -0,0 +1,58 @@
+// Command storecheck runs the jobstore conformance suite against a throwaway SQLite database
+// and, when DATABASE_URL is set, against that PostgreSQL database, as go test does in storetest.
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"common/jobstore"
+	"common/jobstore/storetest"
+)
+
+func main() {
+	dir, err := os.MkdirTemp("", "storecheck")
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	failed := false
+	check := func(name string, open func() (jobstore.Store, error)) {
+		s, err := open()
+		if err != nil {
+			log.Printf("❌ %s: open: %v", name, err)
+			failed = true
+			return
+		}
+		defer s.Close()
+
+		if err := s.Migrate(); err != nil {
+			log.Printf("❌ %s: migrate: %v", name, err)
+			failed = true
+			return
+		}
+		if err := storetest.Run(s); err != nil {
+			log.Printf("❌ %s: %v", name, err)
+			failed = true
+			return
+		}
+		log.Printf("✅ %s store passes", name)
+	}
+
+	check("sqlite", func() (jobstore.Store, error) {
+		return jobstore.NewSQLite(filepath.Join(dir, "jobs.db"))
+	})
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		check("postgres", func() (jobstore.Store, error) {
+			return jobstore.NewPostgres(dsn)
+		})
+	} else {
+		log.Println("⏭️ DATABASE_URL not set, skipping postgres")
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,684 @@
+// Package contracts defines every message the services exchange over Kafka. Messages carry a
+// schema_version and are decoded strictly: unknown fields, missing required fields and versions
+// newer than this build are rejected instead of being silently zero-valued.
+package contracts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version stamped on every message this build publishes. Bump it (and add
+// fixtures under fixtures/v<N>) whenever a message changes shape.
+const SchemaVersion = 10
+
+// Topics.
+const (
+	TopicJobsHigh      = "transcode-jobs-high"
+	TopicJobs          = "transcode-jobs"
+	TopicJobsLow       = "transcode-jobs-low"
+	TopicStatus        = "transcode-status"
+	TopicMPDGeneration = "mpd-generation"
+
+	// Since v4
+	TopicLadderAnalysis = "transcode-ladder-analysis"
+	TopicLadders        = "transcode-ladders"
+
+	// Since v6
+	TopicSplit      = "transcode-split"
+	TopicChunkPlans = "transcode-chunk-plans"
+
+	// Since v7
+	TopicThumbnails = "transcode-thumbnails"
+
+	// Since v8
+	TopicSubtitles = "transcode-subtitles"
+
+	// Since v9
+	TopicEdits       = "transcode-edits"
+	TopicEditResults = "transcode-edit-results"
+)
+
+// PriorityTopics maps a job priority to the topic its representation jobs are published on.
+// Normal priority keeps the original topic name so existing consumers continue to work.
+var PriorityTopics = map[string]string{
+	"high":   TopicJobsHigh,
+	"normal": TopicJobs,
+	"low":    TopicJobsLow,
+}
+
+// TopicForPriority returns the job topic for a priority, defaulting to normal.
+func TopicForPriority(priority string) string {
+	if topic, ok := PriorityTopics[priority]; ok {
+		return topic
+	}
+	return PriorityTopics["normal"]
+}
+
+// SegmentSeconds is the duration of the DASH segments the mpd-generator packages. The
+// controller's default GOP divides it evenly, so every segment starts on a keyframe.
+const SegmentSeconds = 4
+
+// Rate control modes of a TranscodeJob. An empty mode is plain average bitrate.
+const (
+	RateControlCBR     = "cbr"   // constant bitrate: maxrate equals bitrate
+	RateControlVBR     = "vbr"   // constrained VBR: average bitrate, peaks capped by maxrate/bufsize
+	RateControlCRF     = "crf"   // capped CRF: constant quality, peaks capped by maxrate/bufsize
+	RateControlTwoPass = "2pass" // two-pass average bitrate
+)
+
+// RateControlModes lists the valid rate_control values.
+var RateControlModes = map[string]bool{
+	RateControlCBR:     true,
+	RateControlVBR:     true,
+	RateControlCRF:     true,
+	RateControlTwoPass: true,
+}
+
+// EncoderCaps is what one FFmpeg video encoder can produce.
+type EncoderCaps struct {
+	Codec        string
+	RateControls []string // modes it implements besides average bitrate
+}
+
+// SupportsRateControl reports whether the encoder can encode with mode ("" for average bitrate).
+func (c EncoderCaps) SupportsRateControl(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range c.RateControls {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Encoders lists, by FFmpeg name, the video encoders the worker runs. The worker's argument
+// builders implement exactly these modes, and the controller refuses any other combination.
+var Encoders = map[string]EncoderCaps{
+	"libx264":    {"h264", []string{RateControlCBR, RateControlVBR, RateControlCRF, RateControlTwoPass}},
+	"libx265":    {"hevc", []string{RateControlCBR, RateControlVBR, RateControlCRF, RateControlTwoPass}},
+	"libvvenc":   {"vvc", []string{RateControlCRF, RateControlTwoPass}},
+	"libvpx-vp9": {"vp9", []string{RateControlCBR, RateControlVBR, RateControlCRF, RateControlTwoPass}},
+	"libaom-av1": {"av1", []string{RateControlCBR, RateControlVBR, RateControlCRF, RateControlTwoPass}},
+	"libsvtav1":  {"av1", []string{RateControlCBR, RateControlVBR, RateControlCRF}},
+	"librav1e":   {"av1", []string{RateControlTwoPass}},
+}
+
+// DefaultEncoders is the encoder each codec is encoded with. AV1_ENCODER, which the controller
+// and the workers must be given alike, swaps in another AV1 encoder.
+var DefaultEncoders = map[string]string{
+	"h264": "libx264",
+	"hevc": "libx265",
+	"vvc":  "libvvenc",
+	"vp9":  "libvpx-vp9",
+	"av1":  "libaom-av1",
+}
+
+// Quality profiles of a TranscodeJob, trading encoding speed against compression. An empty
+// profile is balanced.
+const (
+	QualityFast     = "fast"
+	QualityBalanced = "balanced"
+	QualityArchive  = "archive"
+)
+
+// QualityProfiles lists the valid quality_profile values.
+var QualityProfiles = map[string]bool{
+	QualityFast:     true,
+	QualityBalanced: true,
+	QualityArchive:  true,
+}
+
+// Subtitle formats a job's subtitle tracks are published in.
+const (
+	SubtitleWebVTT = "webvtt" // side-loaded WebVTT files
+	SubtitleWVTT   = "wvtt"   // WebVTT in fragmented MP4 segments
+	SubtitleSTPP   = "stpp"   // TTML in fragmented MP4 segments
+)
+
+// SubtitleFormats lists the valid subtitle format values.
+var SubtitleFormats = map[string]bool{
+	SubtitleWebVTT: true,
+	SubtitleWVTT:   true,
+	SubtitleSTPP:   true,
+}
+
+// Message is implemented by every type in this package.
+type Message interface {
+	// Validate reports the first missing or invalid required field.
+	Validate() error
+	version() *int
+}
+
+// TranscodeJob asks a worker to encode one representation of a job. Published by the
+// controller on the priority topics.
+type TranscodeJob struct {
+	SchemaVersion  int    `json:"schema_version"`
+	JobID          string `json:"job_id"`
+	InputURL       string `json:"input_url"`
+	Representation string `json:"representation"` // e.g., 720p
+	Resolution     string `json:"resolution"`     // e.g., 1280x720
+	Bitrate        string `json:"bitrate"`        // e.g., 2500k
+	Codec          string `json:"codec"`          // e.g., h264, hevc, vvc, vp9
+	OutputPath     string `json:"output_path,omitempty"`
+	GopSize        int    `json:"gop_size,omitempty"`
+	KeyintMin      int    `json:"keyint_min,omitempty"`
+	TenantID       string `json:"tenant_id,omitempty"` // charged for the encoded minutes
+	Priority       string `json:"priority,omitempty"`  // high, normal or low
+
+	// Since v2. Bitrate is the target of every mode except crf.
+	RateControl string `json:"rate_control,omitempty"` // cbr, vbr, crf or 2pass
+	Maxrate     string `json:"maxrate,omitempty"`      // peak rate for cbr, vbr and crf
+	Bufsize     string `json:"bufsize,omitempty"`      // VBV buffer for cbr, vbr and crf
+	CRF         int    `json:"crf,omitempty"`          // quality on the encoder's scale; 0 uses its default
+
+	// Since v3
+	QualityProfile string `json:"quality_profile,omitempty"` // fast, balanced or archive
+
+	// Since v5
+	QualityCheck *QualityCheck `json:"quality_check,omitempty"` // measure the output against the source
+
+	// Since v6. A chunk job encodes only its chunk of the input; the worker that finishes the
+	// last of the representation's ChunkCount chunks stitches them into its output.
+	Chunk      *Chunk `json:"chunk,omitempty"`
+	ChunkCount int    `json:"chunk_count,omitempty"`
+}
+
+// QualityCheck asks the worker to score a rendition against its source once it is encoded, and
+// to fail it if the mean of a measured metric is below its threshold. Thresholds on a metric the
+// worker cannot measure (VMAF without libvmaf) are not applied.
+type QualityCheck struct {
+	MinVMAF float64 `json:"min_vmaf,omitempty"` // 0-100
+	MinPSNR float64 `json:"min_psnr,omitempty"` // dB
+	MinSSIM float64 `json:"min_ssim,omitempty"` // 0-1
+}
+
+// Validate checks that every threshold is on its metric's scale.
+func (q QualityCheck) Validate() error {
+	switch {
+	case q.MinVMAF < 0 || q.MinVMAF > 100:
+		return fmt.Errorf("min_vmaf %g is not between 0 and 100", q.MinVMAF)
+	case q.MinPSNR < 0:
+		return fmt.Errorf("min_psnr %g is negative", q.MinPSNR)
+	case q.MinSSIM < 0 || q.MinSSIM > 1:
+		return fmt.Errorf("min_ssim %g is not between 0 and 1", q.MinSSIM)
+	}
+	return nil
+}
+
+func (m *TranscodeJob) version() *int { return &m.SchemaVersion }
+
+// Validate checks the fields a worker cannot run without, including the VBV settings the
+// rate control mode needs.
+func (m *TranscodeJob) Validate() error {
+	err := required(
+		"job_id", m.JobID,
+		"input_url", m.InputURL,
+		"representation", m.Representation,
+		"resolution", m.Resolution,
+		"bitrate", m.Bitrate,
+		"codec", m.Codec,
+	)
+	if err != nil {
+		return err
+	}
+
+	switch m.RateControl {
+	case "", RateControlTwoPass:
+	case RateControlCBR, RateControlVBR, RateControlCRF:
+		if err := required("maxrate", m.Maxrate, "bufsize", m.Bufsize); err != nil {
+			return fmt.Errorf("rate_control %s: %w", m.RateControl, err)
+		}
+	default:
+		return fmt.Errorf("unsupported rate_control %q", m.RateControl)
+	}
+	if m.CRF != 0 && m.RateControl != RateControlCRF {
+		return errors.New("crf is only used with rate_control crf")
+	}
+	if m.CRF < 0 {
+		return fmt.Errorf("invalid crf %d", m.CRF)
+	}
+	if m.QualityProfile != "" && !QualityProfiles[m.QualityProfile] {
+		return fmt.Errorf("unsupported quality_profile %q", m.QualityProfile)
+	}
+	if m.QualityCheck != nil {
+		if err := m.QualityCheck.Validate(); err != nil {
+			return fmt.Errorf("quality_check: %w", err)
+		}
+	}
+	switch {
+	case m.Chunk == nil && m.ChunkCount != 0:
+		return errors.New("chunk_count without a chunk")
+	case m.Chunk == nil:
+	case m.QualityCheck != nil:
+		return errors.New("quality_check is measured on whole representations, not chunks")
+	case m.Chunk.Index >= m.ChunkCount:
+		return fmt.Errorf("chunk index %d is not below chunk_count %d", m.Chunk.Index, m.ChunkCount)
+	default:
+		if err := m.Chunk.validate(); err != nil {
+			return fmt.Errorf("chunk: %w", err)
+		}
+	}
+	return nil
+}
+
+// StatusEvent is one job (or representation, if set) state transition. Published by every
+// service on TopicStatus; the tracker appends it to the job's history.
+type StatusEvent struct {
+	SchemaVersion  int       `json:"schema_version"`
+	JobID          string    `json:"job_id"`
+	Representation string    `json:"representation,omitempty"`
+	Service        string    `json:"service"`
+	FromState      string    `json:"from_state,omitempty"`
+	ToState        string    `json:"to_state"`
+	WorkerID       string    `json:"worker_id,omitempty"`
+	Message        string    `json:"message,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+func (m *StatusEvent) version() *int { return &m.SchemaVersion }
+
+// Validate checks the fields the tracker needs to record the event.
+func (m *StatusEvent) Validate() error {
+	if err := required("job_id", m.JobID, "service", m.Service, "to_state", m.ToState); err != nil {
+		return err
+	}
+	if m.Timestamp.IsZero() {
+		return errors.New("missing required field timestamp")
+	}
+	return nil
+}
+
+// MPDRequest tells the mpd-generator that every representation of a job is encoded. Published
+// by the tracker on TopicMPDGeneration.
+type MPDRequest struct {
+	SchemaVersion int    `json:"schema_version"`
+	JobID         string `json:"job_id"`
+	Status        string `json:"status"` // always ready_for_mpd
+}
+
+func (m *MPDRequest) version() *int { return &m.SchemaVersion }
+
+// Validate checks the job ID and status.
+func (m *MPDRequest) Validate() error {
+	if err := required("job_id", m.JobID, "status", m.Status); err != nil {
+		return err
+	}
+	if m.Status != "ready_for_mpd" {
+		return fmt.Errorf("unexpected status %q", m.Status)
+	}
+	return nil
+}
+
+// LadderRung is one representation of an encoding ladder.
+type LadderRung struct {
+	Representation string `json:"representation"` // e.g., 720p
+	Resolution     string `json:"resolution"`     // e.g., 1280x720
+	Bitrate        string `json:"bitrate"`        // e.g., 2500k
+}
+
+func (r LadderRung) validate() error {
+	return required(
+		"representation", r.Representation,
+		"resolution", r.Resolution,
+		"bitrate", r.Bitrate,
+	)
+}
+
+func validateRungs(field string, rungs []LadderRung) error {
+	if len(rungs) == 0 {
+		return fmt.Errorf("missing required field %s", field)
+	}
+	for i, r := range rungs {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%s[%d]: %w", field, i, err)
+		}
+	}
+	return nil
+}
+
+// LadderAnalysisJob asks a worker to probe-encode a job's input and choose its ladder among the
+// candidate rungs. Published by the controller on TopicLadderAnalysis for jobs submitted with an
+// automatic ladder. Since v4.
+type LadderAnalysisJob struct {
+	SchemaVersion int          `json:"schema_version"`
+	JobID         string       `json:"job_id"`
+	InputURL      string       `json:"input_url"`
+	Codec         string       `json:"codec"`
+	Candidates    []LadderRung `json:"candidates"` // the static ladder's rungs, lowest first
+}
+
+func (m *LadderAnalysisJob) version() *int { return &m.SchemaVersion }
+
+// Validate checks the input, the codec and every candidate rung.
+func (m *LadderAnalysisJob) Validate() error {
+	if err := required("job_id", m.JobID, "input_url", m.InputURL, "codec", m.Codec); err != nil {
+		return err
+	}
+	return validateRungs("candidates", m.Candidates)
+}
+
+// LadderResult carries the rungs a worker chose for a job. Published on TopicLadders; the
+// controller dispatches one TranscodeJob per rung. Since v4.
+type LadderResult struct {
+	SchemaVersion int          `json:"schema_version"`
+	JobID         string       `json:"job_id"`
+	Rungs         []LadderRung `json:"rungs"` // lowest first
+}
+
+func (m *LadderResult) version() *int { return &m.SchemaVersion }
+
+// Validate checks the job ID and every rung.
+func (m *LadderResult) Validate() error {
+	if err := required("job_id", m.JobID); err != nil {
+		return err
+	}
+	return validateRungs("rungs", m.Rungs)
+}
+
+// Chunk is a span of a job's input, cut at a keyframe, that is encoded on its own. Since v6.
+type Chunk struct {
+	Index int     `json:"index"`
+	Start float64 `json:"start"` // seconds
+	End   float64 `json:"end"`   // seconds, exclusive
+}
+
+func (c Chunk) validate() error {
+	switch {
+	case c.Index < 0:
+		return fmt.Errorf("invalid index %d", c.Index)
+	case c.Start < 0 || c.End <= c.Start:
+		return fmt.Errorf("invalid span %g-%g", c.Start, c.End)
+	}
+	return nil
+}
+
+// SplitJob asks a worker to cut a job's input into chunks of about ChunkSeconds at its keyframes.
+// Published by the controller on TopicSplit for jobs submitted with chunk_seconds. Since v6.
+type SplitJob struct {
+	SchemaVersion int     `json:"schema_version"`
+	JobID         string  `json:"job_id"`
+	InputURL      string  `json:"input_url"`
+	ChunkSeconds  float64 `json:"chunk_seconds"`
+	GopSize       int     `json:"gop_size,omitempty"` // cuts are moved onto this GOP's grid, when set
+}
+
+func (m *SplitJob) version() *int { return &m.SchemaVersion }
+
+// Validate checks the input and the chunk length.
+func (m *SplitJob) Validate() error {
+	if err := required("job_id", m.JobID, "input_url", m.InputURL); err != nil {
+		return err
+	}
+	if m.ChunkSeconds <= 0 {
+		return fmt.Errorf("invalid chunk_seconds %g", m.ChunkSeconds)
+	}
+	if m.GopSize < 0 {
+		return fmt.Errorf("invalid gop_size %d", m.GopSize)
+	}
+	return nil
+}
+
+// ChunkPlan carries the chunks a worker cut a job's input into, in order and back to back.
+// Published on TopicChunkPlans; the controller dispatches one TranscodeJob per chunk of every
+// rung, or one per rung if the input was too short to split. Since v6.
+type ChunkPlan struct {
+	SchemaVersion int     `json:"schema_version"`
+	JobID         string  `json:"job_id"`
+	Chunks        []Chunk `json:"chunks"`
+	// The file:// URL of the input the worker downloaded into the shared segments directory to
+	// cut it, which the chunks are then encoded from instead of each downloading it again.
+	// Empty from workers that removed it. Since v10.
+	InputURL string `json:"input_url,omitempty"`
+}
+
+func (m *ChunkPlan) version() *int { return &m.SchemaVersion }
+
+// Validate checks that the chunks are numbered in order and cover the input without gaps.
+func (m *ChunkPlan) Validate() error {
+	if err := required("job_id", m.JobID); err != nil {
+		return err
+	}
+	if len(m.Chunks) == 0 {
+		return errors.New("missing required field chunks")
+	}
+	for i, c := range m.Chunks {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("chunks[%d]: %w", i, err)
+		}
+		if c.Index != i {
+			return fmt.Errorf("chunks[%d] has index %d", i, c.Index)
+		}
+		if i > 0 && c.Start != m.Chunks[i-1].End {
+			return fmt.Errorf("chunks[%d] starts at %g, not where chunks[%d] ends", i, c.Start, i-1)
+		}
+	}
+	return nil
+}
+
+// Thumbnails asks for a poster image and scrubbing thumbnails: one thumbnail every Interval
+// seconds, tiled Columns by Rows into sprite sheets and indexed by a WebVTT track. Zero values are
+// filled in by the controller before the job is published.
+type Thumbnails struct {
+	PosterAt *float64 `json:"poster_at,omitempty"` // seconds; omitted picks a non-black frame near the start
+	Interval float64  `json:"interval,omitempty"`  // seconds between thumbnails
+	Width    int      `json:"width,omitempty"`     // thumbnail width in pixels; the height keeps the aspect ratio
+	Columns  int      `json:"columns,omitempty"`   // thumbnails per sprite sheet row
+	Rows     int      `json:"rows,omitempty"`      // thumbnail rows per sprite sheet
+}
+
+// Validate checks that no option is negative.
+func (t Thumbnails) Validate() error {
+	switch {
+	case t.PosterAt != nil && *t.PosterAt < 0:
+		return fmt.Errorf("poster_at %g is negative", *t.PosterAt)
+	case t.Interval < 0:
+		return fmt.Errorf("interval %g is negative", t.Interval)
+	case t.Width < 0:
+		return fmt.Errorf("width %d is negative", t.Width)
+	case t.Columns < 0 || t.Rows < 0:
+		return fmt.Errorf("invalid %dx%d sprite sheet", t.Columns, t.Rows)
+	}
+	return nil
+}
+
+// ThumbnailJob asks a worker to generate a job's poster, thumbnails, sprite sheets and thumbnail
+// track next to its renditions. Published by the controller on TopicThumbnails for jobs submitted
+// with thumbnails. Since v7.
+type ThumbnailJob struct {
+	SchemaVersion int        `json:"schema_version"`
+	JobID         string     `json:"job_id"`
+	InputURL      string     `json:"input_url"`
+	Thumbnails    Thumbnails `json:"thumbnails"`
+}
+
+func (m *ThumbnailJob) version() *int { return &m.SchemaVersion }
+
+// Validate checks the input and that every thumbnail option is set.
+func (m *ThumbnailJob) Validate() error {
+	if err := required("job_id", m.JobID, "input_url", m.InputURL); err != nil {
+		return err
+	}
+	t := m.Thumbnails
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("thumbnails: %w", err)
+	}
+	if t.Interval == 0 || t.Width == 0 || t.Columns == 0 || t.Rows == 0 {
+		return errors.New("thumbnails: interval, width, columns and rows are required")
+	}
+	return nil
+}
+
+// SubtitleSidecar is a subtitle file delivered alongside the input, in SRT, WebVTT or TTML.
+type SubtitleSidecar struct {
+	URL      string `json:"url"`
+	Language string `json:"language"`        // BCP 47 tag, e.g. en or pt-BR
+	Label    string `json:"label,omitempty"` // shown in the player's track menu
+}
+
+// Subtitles asks for a job's subtitle tracks: its sidecar files and, with Embedded, the text
+// subtitle streams of its input. The controller fills in the default format.
+type Subtitles struct {
+	Sidecars []SubtitleSidecar `json:"sidecars,omitempty"`
+	Embedded bool              `json:"embedded,omitempty"` // extract the input's text subtitle streams
+	Format   string            `json:"format,omitempty"`   // webvtt, wvtt or stpp
+}
+
+// Validate checks the format and every sidecar, and that there is something to extract.
+func (s Subtitles) Validate() error {
+	if s.Format != "" && !SubtitleFormats[s.Format] {
+		return fmt.Errorf("unsupported format %q", s.Format)
+	}
+	if len(s.Sidecars) == 0 && !s.Embedded {
+		return errors.New("no sidecars and embedded not set")
+	}
+	for i, sc := range s.Sidecars {
+		if err := required("url", sc.URL, "language", sc.Language); err != nil {
+			return fmt.Errorf("sidecars[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SubtitleJob asks a worker to convert a job's subtitles into the tracks the mpd-generator
+// packages. Published by the controller on TopicSubtitles for jobs submitted with subtitles.
+// Since v8.
+type SubtitleJob struct {
+	SchemaVersion int       `json:"schema_version"`
+	JobID         string    `json:"job_id"`
+	InputURL      string    `json:"input_url"`
+	Subtitles     Subtitles `json:"subtitles"`
+}
+
+func (m *SubtitleJob) version() *int { return &m.SchemaVersion }
+
+// Validate checks the input and the subtitle options, including the format.
+func (m *SubtitleJob) Validate() error {
+	if err := required("job_id", m.JobID, "input_url", m.InputURL); err != nil {
+		return err
+	}
+	if err := m.Subtitles.Validate(); err != nil {
+		return fmt.Errorf("subtitles: %w", err)
+	}
+	if m.Subtitles.Format == "" {
+		return errors.New("subtitles: missing required field format")
+	}
+	return nil
+}
+
+// Edit is one clip of an edit list: its input from In to Out seconds, or to the input's end when
+// Out is zero.
+type Edit struct {
+	InputURL string  `json:"input_url,omitempty"` // the job's input_url when empty
+	In       float64 `json:"in,omitempty"`
+	Out      float64 `json:"out,omitempty"`
+}
+
+// Validate checks that the clip's in point comes before its out point.
+func (e Edit) Validate() error {
+	if e.In < 0 {
+		return fmt.Errorf("invalid in %g", e.In)
+	}
+	if e.Out < 0 || e.Out != 0 && e.Out <= e.In {
+		return fmt.Errorf("out %g is not after in %g", e.Out, e.In)
+	}
+	return nil
+}
+
+// EditJob asks a worker to render a job's edit list, its clips back to back, into the single
+// input every representation is then encoded from. Published by the controller on TopicEdits
+// for jobs submitted with edits. Since v9.
+type EditJob struct {
+	SchemaVersion int    `json:"schema_version"`
+	JobID         string `json:"job_id"`
+	Edits         []Edit `json:"edits"`
+}
+
+func (m *EditJob) version() *int { return &m.SchemaVersion }
+
+// Validate checks every clip, each of which must name its input.
+func (m *EditJob) Validate() error {
+	if err := required("job_id", m.JobID); err != nil {
+		return err
+	}
+	if len(m.Edits) == 0 {
+		return errors.New("missing required field edits")
+	}
+	for i, e := range m.Edits {
+		if err := required("input_url", e.InputURL); err != nil {
+			return fmt.Errorf("edits[%d]: %w", i, err)
+		}
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("edits[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// EditResult carries where a worker rendered a job's edit list and the probed duration of the
+// result. Published on TopicEditResults; the controller dispatches the job with InputURL as its
+// input. Since v9.
+type EditResult struct {
+	SchemaVersion int     `json:"schema_version"`
+	JobID         string  `json:"job_id"`
+	InputURL      string  `json:"input_url"`
+	Duration      float64 `json:"duration"` // seconds
+}
+
+func (m *EditResult) version() *int { return &m.SchemaVersion }
+
+// Validate checks the rendered input and its duration.
+func (m *EditResult) Validate() error {
+	if err := required("job_id", m.JobID, "input_url", m.InputURL); err != nil {
+		return err
+	}
+	if m.Duration <= 0 {
+		return fmt.Errorf("invalid duration %g", m.Duration)
+	}
+	return nil
+}
+
+// Encode stamps the current SchemaVersion on m, validates it and marshals it.
+func Encode(m Message) ([]byte, error) {
+	*m.version() = SchemaVersion
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// Decode strictly unmarshals data into m: unknown fields, a missing or unsupported
+// schema_version and missing required fields are all errors.
+func Decode(data []byte, m Message) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(m); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after message")
+	}
+
+	switch v := *m.version(); {
+	case v == 0:
+		return errors.New("missing required field schema_version")
+	case v > SchemaVersion:
+		return fmt.Errorf("unsupported schema_version %d (this build understands up to %d)", v, SchemaVersion)
+	}
+	return m.Validate()
+}
+
+// required takes name/value pairs and reports the first empty value.
+func required(pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			return fmt.Errorf("missing required field %s", pairs[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package contracttest checks the message contracts against recorded fixtures: every message ever
+// published under a supported schema version must still decode, the current version must
+// round-trip byte-for-byte in meaning, and known-bad messages must be rejected.
+package contracttest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+)
+
+// Fixtures live in fixtures/v<N>/<message>[_<variant>].json (must decode) and
+// fixtures/reject/<message>__<case>.json (must fail to decode).
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// messages maps a fixture name prefix to a constructor for its type.
+var messages = map[string]func() contracts.Message{
+	"transcode_job":       func() contracts.Message { return &contracts.TranscodeJob{} },
+	"status_event":        func() contracts.Message { return &contracts.StatusEvent{} },
+	"mpd_request":         func() contracts.Message { return &contracts.MPDRequest{} },
+	"ladder_analysis_job": func() contracts.Message { return &contracts.LadderAnalysisJob{} },
+	"ladder_result":       func() contracts.Message { return &contracts.LadderResult{} },
+	"split_job":           func() contracts.Message { return &contracts.SplitJob{} },
+	"chunk_plan":          func() contracts.Message { return &contracts.ChunkPlan{} },
+	"thumbnail_job":       func() contracts.Message { return &contracts.ThumbnailJob{} },
+	"subtitle_job":        func() contracts.Message { return &contracts.SubtitleJob{} },
+	"edit_job":            func() contracts.Message { return &contracts.EditJob{} },
+	"edit_result":         func() contracts.Message { return &contracts.EditResult{} },
+}
+
+// Run checks every fixture and returns the first failure.
+func Run() error {
+	dirs, err := fs.ReadDir(fixtures, "fixtures")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		name := dir.Name()
+		if name == "reject" {
+			if err := checkRejected(path.Join("fixtures", name)); err != nil {
+				return err
+			}
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+		if err != nil || !strings.HasPrefix(name, "v") {
+			return fmt.Errorf("fixture directory %s must be named v<N> or reject", name)
+		}
+		if version > contracts.SchemaVersion {
+			return fmt.Errorf("fixtures for v%d exist but SchemaVersion is %d", version, contracts.SchemaVersion)
+		}
+		if err := checkAccepted(path.Join("fixtures", name), version == contracts.SchemaVersion, seen); err != nil {
+			return err
+		}
+	}
+
+	// Every message type needs a fixture at the current version
+	var missing []string
+	for prefix := range messages {
+		if !seen[prefix] {
+			missing = append(missing, prefix)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no v%d fixture for %s", contracts.SchemaVersion, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func checkAccepted(dir string, current bool, seen map[string]bool) error {
+	files, err := fs.ReadDir(fixtures, dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".json")
+		prefix, m, err := messageFor(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		data, err := fs.ReadFile(fixtures, path.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err := contracts.Decode(data, m); err != nil {
+			return fmt.Errorf("%s/%s no longer decodes: %w", dir, f.Name(), err)
+		}
+		if !current {
+			continue
+		}
+
+		seen[prefix] = true
+		encoded, err := contracts.Encode(m)
+		if err != nil {
+			return fmt.Errorf("%s/%s: encode: %w", dir, f.Name(), err)
+		}
+		if !sameJSON(data, encoded) {
+			return fmt.Errorf("%s/%s does not round-trip:\n  fixture: %s\n  encoded: %s", dir, f.Name(), compact(data), encoded)
+		}
+	}
+	return nil
+}
+
+func checkRejected(dir string) error {
+	files, err := fs.ReadDir(fixtures, dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name, _, ok := strings.Cut(strings.TrimSuffix(f.Name(), ".json"), "__")
+		if !ok {
+			return fmt.Errorf("reject fixture %s must be named <message>__<case>.json", f.Name())
+		}
+		newMessage, ok := messages[name]
+		if !ok {
+			return fmt.Errorf("reject fixture %s: unknown message %s", f.Name(), name)
+		}
+		data, err := fs.ReadFile(fixtures, path.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err := contracts.Decode(data, newMessage()); err == nil {
+			return fmt.Errorf("%s/%s was accepted", dir, f.Name())
+		}
+	}
+	return nil
+}
+
+// messageFor picks the message type whose name is the longest prefix of a fixture name.
+func messageFor(name string) (string, contracts.Message, error) {
+	best := ""
+	for prefix := range messages {
+		if (name == prefix || strings.HasPrefix(name, prefix+"_")) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return "", nil, fmt.Errorf("no message type matches")
+	}
+	return best, messages[best](), nil
+}
+
+func sameJSON(a, b []byte) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func compact(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
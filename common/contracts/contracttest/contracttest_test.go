@@ -0,0 +1,9 @@
+package contracttest
+
+import "testing"
+
+func TestFixtures(t *testing.T) {
+	if err := Run(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,157 @@
+// Package failure turns a failed external command (ffmpeg, ffprobe, MP4Box) or download into a
+// structured, classified record that the worker and mpd-generator persist on the job.
+package failure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Stages at which a job can fail.
+const (
+	StageSubmit     = "submit" // the controller could not dispatch the job
+	StageDownload   = "download"
+	StageTranscode  = "transcode"
+	StageProbe      = "probe"
+	StagePackage    = "package"
+	StageAnalyze    = "analyze"
+	StageQuality    = "quality"
+	StageSplit      = "split"
+	StageStitch     = "stitch"
+	StageThumbnails = "thumbnails"
+	StageSubtitles  = "subtitles"
+	StageEdit       = "edit"
+)
+
+// Classified reasons.
+const (
+	ReasonUnsupportedCodec    = "unsupported_codec"
+	ReasonCorruptInput        = "corrupt_input"
+	ReasonOutOfDisk           = "out_of_disk"
+	ReasonInputUnavailable    = "input_unavailable"
+	ReasonInvalidArguments    = "invalid_arguments"
+	ReasonPermissionDenied    = "permission_denied"
+	ReasonMissingSegments     = "missing_segments"
+	ReasonBelowThreshold      = "below_quality_threshold"
+	ReasonTimestamps          = "timestamp_mismatch"
+	ReasonKeyframesMisaligned = "keyframes_misaligned"
+	ReasonKilled              = "killed"
+	ReasonUnknown             = "unknown"
+)
+
+// TailLines is how much of a command's output is kept.
+const TailLines = 20
+
+// Failure describes why a stage failed.
+type Failure struct {
+	Stage      string `json:"stage"`
+	Reason     string `json:"reason"`
+	ExitCode   int    `json:"exit_code"` // -1 if the process was killed or never ran
+	Message    string `json:"message"`
+	StderrTail string `json:"stderr_tail,omitempty"`
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s failed (%s, exit %d): %s", f.Stage, f.Reason, f.ExitCode, f.Message)
+}
+
+// patterns maps tool output to reasons; the first match wins, so more specific entries come first.
+var patterns = []struct {
+	substr string
+	reason string
+}{
+	{"no space left on device", ReasonOutOfDisk},
+	{"disk quota exceeded", ReasonOutOfDisk},
+	{"unknown encoder", ReasonUnsupportedCodec},
+	{"encoder not found", ReasonUnsupportedCodec},
+	{"decoder not found", ReasonUnsupportedCodec},
+	{"unsupported codec", ReasonUnsupportedCodec},
+	{"codec not currently supported", ReasonUnsupportedCodec},
+	{"invalid data found when processing input", ReasonCorruptInput},
+	{"moov atom not found", ReasonCorruptInput},
+	{"error while decoding", ReasonCorruptInput},
+	{"corrupt", ReasonCorruptInput},
+	{"truncat", ReasonCorruptInput},
+	{"permission denied", ReasonPermissionDenied},
+	{"no such file or directory", ReasonInputUnavailable},
+	{"server returned 4", ReasonInputUnavailable},
+	{"connection refused", ReasonInputUnavailable},
+	{"unrecognized option", ReasonInvalidArguments},
+	{"invalid argument", ReasonInvalidArguments},
+}
+
+// Classify returns the reason for a failed command based on its output.
+func Classify(output string) string {
+	lower := strings.ToLower(output)
+	for _, p := range patterns {
+		if strings.Contains(lower, p.substr) {
+			return p.reason
+		}
+	}
+	return ReasonUnknown
+}
+
+// Tail returns the last n non-empty lines of output.
+func Tail(output string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FromCommand builds a Failure from the error and combined output of exec.Cmd.
+func FromCommand(stage string, err error, output []byte) Failure {
+	f := Failure{
+		Stage:      stage,
+		ExitCode:   -1,
+		Reason:     Classify(string(output)),
+		StderrTail: Tail(string(output), TailLines),
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		f.ExitCode = exitErr.ExitCode()
+		if f.ExitCode == -1 && f.Reason == ReasonUnknown {
+			f.Reason = ReasonKilled
+		}
+	}
+
+	// The last output line is usually the most specific error message
+	f.Message = err.Error()
+	if tail := Tail(string(output), 1); tail != "" {
+		f.Message = tail
+	}
+	return f
+}
+
+// New builds a Failure for an error that did not come from an external command.
+func New(stage, reason string, err error) Failure {
+	return Failure{Stage: stage, Reason: reason, ExitCode: -1, Message: err.Error()}
+}
+
+// Encode serializes f for storage in a Redis hash field.
+func (f Failure) Encode() string {
+	payload, _ := json.Marshal(f)
+	return string(payload)
+}
+
+// Decode parses a value written by Encode, returning nil if it is empty or malformed.
+func Decode(s string) *Failure {
+	if s == "" {
+		return nil
+	}
+	var f Failure
+	if err := json.Unmarshal([]byte(s), &f); err != nil {
+		return nil
+	}
+	return &f
+}
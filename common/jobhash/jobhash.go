@@ -0,0 +1,285 @@
+// Package jobhash owns the Redis layout shared by every service: the job:<id> hashes, the index
+// of active job IDs the tracker polls instead of KEYS, the key prefix and the TTLs.
+package jobhash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Defaults for the job hash TTLs. Active jobs get their TTL refreshed on every write, so only
+// jobs nobody has touched for the whole window expire; finished jobs are kept a day for the API.
+const (
+	DefaultActiveTTL   = 7 * 24 * time.Hour
+	DefaultFinishedTTL = 24 * time.Hour
+)
+
+// Store reads and writes job hashes under a common key prefix.
+type Store struct {
+	rdb         *redis.Client
+	prefix      string
+	activeTTL   time.Duration
+	finishedTTL time.Duration
+}
+
+// New wraps a Redis client. REDIS_KEY_PREFIX namespaces every key (default none),
+// JOB_TTL_HOURS and JOB_FINISHED_TTL_HOURS override the TTLs.
+func New(rdb *redis.Client) *Store {
+	return &Store{
+		rdb:         rdb,
+		prefix:      os.Getenv("REDIS_KEY_PREFIX"),
+		activeTTL:   envHours("JOB_TTL_HOURS", DefaultActiveTTL),
+		finishedTTL: envHours("JOB_FINISHED_TTL_HOURS", DefaultFinishedTTL),
+	}
+}
+
+func envHours(name string, fallback time.Duration) time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv(name)); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return fallback
+}
+
+// Client returns the underlying Redis client.
+func (s *Store) Client() *redis.Client {
+	return s.rdb
+}
+
+// Namespaced prefixes any other key a service keeps in Redis (quotas, idempotency keys).
+func (s *Store) Namespaced(key string) string {
+	return s.prefix + key
+}
+
+// Key is the Redis key of a job hash.
+func (s *Store) Key(jobID string) string {
+	return s.prefix + "job:" + jobID
+}
+
+func (s *Store) activeKey() string {
+	return s.prefix + "jobs:active"
+}
+
+// Create writes a new job hash, sets its TTL and adds it to the active index.
+func (s *Store) Create(ctx context.Context, jobID string, fields map[string]interface{}) error {
+	key := s.Key(jobID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields)
+		pipe.Expire(ctx, key, s.activeTTL)
+		pipe.SAdd(ctx, s.activeKey(), jobID)
+		return nil
+	})
+	return err
+}
+
+// Delete removes a job hash and drops it from the active index, for a job whose submission failed
+// before it was dispatched.
+func (s *Store) Delete(ctx context.Context, jobID string) error {
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.Key(jobID))
+		pipe.SRem(ctx, s.activeKey(), jobID)
+		return nil
+	})
+	return err
+}
+
+// Get reads and decodes a job hash, returning nil if it does not exist.
+func (s *Store) Get(ctx context.Context, jobID string) (*Job, error) {
+	fields, err := s.rdb.HGetAll(ctx, s.Key(jobID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return Parse(jobID, fields), nil
+}
+
+// Field reads one field, returning "" if the field or the hash is missing.
+func (s *Store) Field(ctx context.Context, jobID, field string) (string, error) {
+	v, err := s.rdb.HGet(ctx, s.Key(jobID), field).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return v, err
+}
+
+// Status reads the job status, returning "" if the job does not exist.
+func (s *Store) Status(ctx context.Context, jobID string) (string, error) {
+	return s.Field(ctx, jobID, FieldStatus)
+}
+
+// Set writes field/value pairs and refreshes the job's TTL.
+func (s *Store) Set(ctx context.Context, jobID string, values ...interface{}) error {
+	key := s.Key(jobID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, values...)
+		pipe.Expire(ctx, key, s.activeTTL)
+		return nil
+	})
+	return err
+}
+
+// transitionScript sets a status field (ARGV[1]) to ARGV[2] plus any extra field/value pairs and
+// refreshes the TTL (ARGV[3], in ms), all in one step, unless a guard stops it: with ARGV[4]
+// "unless" the field must not hold one of the ARGV[5] states that follow, with "only" it must.
+// Returns {previous value, 1 if it was changed}.
+var transitionScript = redis.NewScript(`
+local from = redis.call('HGET', KEYS[1], ARGV[1]) or ''
+local n = tonumber(ARGV[5])
+local listed = false
+for i = 1, n do
+	if from == ARGV[5 + i] then
+		listed = true
+	end
+end
+if (ARGV[4] == 'unless' and listed) or (ARGV[4] == 'only' and not listed) then
+	return {from, 0}
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2], unpack(ARGV, 6 + n))
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {from, 1}
+`)
+
+// Transition sets a status field (the job's "status" or a representation name) plus any extra
+// field/value pairs, and returns the value it replaced. It reads and writes in one step, so the
+// value returned is the one actually replaced even when several services race.
+func (s *Store) Transition(ctx context.Context, jobID, field, to string, values ...interface{}) (string, error) {
+	from, _, err := s.transition(ctx, jobID, field, to, "unless", nil, values)
+	return from, err
+}
+
+// TransitionUnless is Transition, except that a field holding one of the unless states is left as
+// it is. It reports whether the field was changed.
+func (s *Store) TransitionUnless(ctx context.Context, jobID, field, to string, unless []string, values ...interface{}) (string, bool, error) {
+	return s.transition(ctx, jobID, field, to, "unless", unless, values)
+}
+
+// TransitionFrom is Transition, except that only a field holding one of the from states is
+// changed. It reports whether it was.
+func (s *Store) TransitionFrom(ctx context.Context, jobID, field, to string, from []string, values ...interface{}) (string, bool, error) {
+	return s.transition(ctx, jobID, field, to, "only", from, values)
+}
+
+func (s *Store) transition(ctx context.Context, jobID, field, to, mode string, states []string, values []interface{}) (string, bool, error) {
+	args := []interface{}{field, to, s.activeTTL.Milliseconds(), mode, len(states)}
+	for _, state := range states {
+		args = append(args, state)
+	}
+	args = append(args, values...)
+	res, err := transitionScript.Run(ctx, s.rdb, []string{s.Key(jobID)}, args...).Slice()
+	if err != nil {
+		return "", false, err
+	}
+	from, _ := res[0].(string)
+	changed, _ := res[1].(int64)
+	return from, changed == 1, nil
+}
+
+// StartAttempt bumps a representation's attempt counter and clears the previous attempt's outcome
+// and quality scores.
+func (s *Store) StartAttempt(ctx context.Context, jobID, rep string) error {
+	key := s.Key(jobID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, RepField(rep, RepAttempt), 1)
+		pipe.HDel(ctx, key, RepField(rep, RepFinishedAt), RepField(rep, RepError), RepField(rep, RepFailure), RepField(rep, RepQuality))
+		pipe.Expire(ctx, key, s.activeTTL)
+		return nil
+	})
+	return err
+}
+
+// StartChunk starts the encode of one chunk of a representation. Its chunks share one attempt,
+// so only the first chunk to start sets the attempt and start time; any chunk clears the outcome
+// of an earlier failed one.
+func (s *Store) StartChunk(ctx context.Context, jobID, rep string) error {
+	key := s.Key(jobID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, RepField(rep, RepAttempt), 1)
+		pipe.HSetNX(ctx, key, RepField(rep, RepStartedAt), Now())
+		pipe.HDel(ctx, key, RepField(rep, RepFinishedAt), RepField(rep, RepError), RepField(rep, RepFailure))
+		pipe.Expire(ctx, key, s.activeTTL)
+		return nil
+	})
+	return err
+}
+
+// CompleteChunk records the output of an encoded chunk and returns how many of the
+// representation's chunks are now encoded. A redelivered chunk is only counted once, so exactly
+// one caller sees the last chunk complete.
+func (s *Store) CompleteChunk(ctx context.Context, jobID, rep string, index int, output string) (int, error) {
+	key := s.Key(jobID)
+	added, err := s.rdb.HSetNX(ctx, key, ChunkField(rep, index), output).Result()
+	if err != nil {
+		return 0, err
+	}
+	if !added {
+		done, err := s.rdb.HGet(ctx, key, RepField(rep, RepChunksDone)).Int()
+		return done, err
+	}
+	done, err := s.rdb.HIncrBy(ctx, key, RepField(rep, RepChunksDone), 1).Result()
+	return int(done), err
+}
+
+// ChunkOutputs returns the outputs of a representation's chunks in order, failing if one of
+// them has not been encoded.
+func (s *Store) ChunkOutputs(ctx context.Context, jobID, rep string, count int) ([]string, error) {
+	fields := make([]string, count)
+	for i := range fields {
+		fields[i] = ChunkField(rep, i)
+	}
+	values, err := s.rdb.HMGet(ctx, s.Key(jobID), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]string, count)
+	for i, v := range values {
+		output, ok := v.(string)
+		if !ok || output == "" {
+			return nil, fmt.Errorf("chunk %d of %s is not encoded", i, rep)
+		}
+		outputs[i] = output
+	}
+	return outputs, nil
+}
+
+// Finish shortens the TTL of a job that reached a terminal state. It stays in the active index
+// until the tracker has synced it and calls Untrack.
+func (s *Store) Finish(ctx context.Context, jobID string) error {
+	return s.rdb.Expire(ctx, s.Key(jobID), s.finishedTTL).Err()
+}
+
+// Track adds a job back to the active index, e.g. when a worker picks up a retried
+// representation of a job that had already failed and been untracked.
+func (s *Store) Track(ctx context.Context, jobID string) error {
+	return s.rdb.SAdd(ctx, s.activeKey(), jobID).Err()
+}
+
+// Untrack removes a job from the active index.
+func (s *Store) Untrack(ctx context.Context, jobID string) error {
+	return s.rdb.SRem(ctx, s.activeKey(), jobID).Err()
+}
+
+// ForEachActive calls fn for every job in the active index, using SSCAN so a large index never
+// blocks Redis.
+func (s *Store) ForEachActive(ctx context.Context, fn func(jobID string)) error {
+	iter := s.rdb.SScan(ctx, s.activeKey(), 0, "", 100).Iterator()
+	for iter.Next(ctx) {
+		fn(iter.Val())
+	}
+	return iter.Err()
+}
+
+// ForEachJob calls fn for every job hash, active or finished, using SCAN.
+func (s *Store) ForEachJob(ctx context.Context, fn func(jobID string)) error {
+	prefix := s.Key("")
+	iter := s.rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		fn(iter.Val()[len(prefix):])
+	}
+	return iter.Err()
+}
@@ -0,0 +1,214 @@
+package jobhash
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"common/failure"
+	"common/quality"
+	"common/subtitle"
+	"common/thumbnail"
+)
+
+// Job-level fields of the job hash.
+const (
+	FieldStreamName          = "stream_name"
+	FieldInputURL            = "input_url"
+	FieldCodec               = "codec"
+	FieldRequiredResolutions = "required_resolutions"
+	FieldTenantID            = "tenant_id"
+	FieldPriority            = "priority"
+	FieldStatus              = "status"
+	FieldWorkerID            = "worker_id"
+	FieldStartedAt           = "started_at"
+	FieldCompletedAt         = "completed_at"
+	FieldMPDPublished        = "mpd_published"
+	FieldFailure             = "failure"
+	FieldRequest             = "request"       // the submitted request as JSON, for jobs dispatched after a worker stage
+	FieldChunks              = "chunks"        // how many chunks a chunked job's input was cut into
+	FieldThumbnails          = "thumbnails"    // status of the job's thumbnails, unset if none were requested
+	FieldThumbnailSet        = "thumbnail_set" // the generated thumbnails, as JSON
+	FieldSubtitles           = "subtitles"     // status of the job's subtitles, unset if none were requested
+	FieldSubtitleSet         = "subtitle_set"  // the converted subtitle tracks, as JSON
+	FieldEditedInput         = "edited_input"  // where a job's edit list was rendered, once it has been
+)
+
+// FinalStatuses are the job statuses a job is not moved out of by a representation, thumbnails or
+// subtitles starting or finishing late: once one part of a job has failed or it was cancelled,
+// the rest cannot bring it back.
+var FinalStatuses = []string{"failed", "cancelled"}
+
+// Per-representation field suffixes. A representation's status is stored under its bare name
+// (e.g. "720p"), everything else under <name><suffix> (e.g. "720p_output").
+const (
+	RepResolution = "_resolution"
+	RepBitrate    = "_bitrate"
+	RepWorkerID   = "_worker_id"
+	RepAttempt    = "_attempt"
+	RepStartedAt  = "_started_at"
+	RepFinishedAt = "_finished_at"
+	RepOutput     = "_output"
+	RepOutputSize = "_output_size"
+	RepError      = "_error"
+	RepFailure    = "_failure"
+	RepQuality    = "_quality"
+	RepChunksDone = "_chunks_done" // how many chunks of a chunked representation are encoded
+)
+
+// RepField names one of a representation's fields.
+func RepField(rep, suffix string) string {
+	return rep + suffix
+}
+
+// ChunkField names the field holding the output of one encoded chunk of a representation.
+func ChunkField(rep string, index int) string {
+	return rep + "_chunk_" + strconv.Itoa(index)
+}
+
+// Job is a decoded job hash.
+type Job struct {
+	ID                  string
+	StreamName          string
+	InputURL            string
+	Codec               string
+	RequiredResolutions []string
+	TenantID            string
+	Priority            string
+	Status              string
+	WorkerID            string
+	StartedAt           *time.Time
+	CompletedAt         *time.Time
+	MPDPublished        bool
+	Failure             *failure.Failure
+	Thumbnails          string // status of the thumbnails, "" if none were requested
+	ThumbnailSet        *thumbnail.Set
+	Subtitles           string // status of the subtitles, "" if none were requested
+	SubtitleSet         *subtitle.Set
+	EditedInput         string // file:// URL of the rendered edit list, "" until it is rendered
+
+	// Representations holds every required representation that has a status, keyed by name.
+	Representations map[string]Representation
+}
+
+// Representation is one rendition's slice of the job hash.
+type Representation struct {
+	Name       string
+	Status     string
+	Resolution string
+	Bitrate    string
+	WorkerID   string
+	Attempt    int
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	OutputPath string
+	OutputSize int64
+	Error      string
+	Failure    *failure.Failure
+	Quality    *quality.Report
+}
+
+// Parse decodes the fields returned by HGETALL on a job hash.
+func Parse(jobID string, fields map[string]string) *Job {
+	job := &Job{
+		ID:                  jobID,
+		StreamName:          fields[FieldStreamName],
+		InputURL:            fields[FieldInputURL],
+		Codec:               fields[FieldCodec],
+		RequiredResolutions: SplitResolutions(fields[FieldRequiredResolutions]),
+		TenantID:            fields[FieldTenantID],
+		Priority:            fields[FieldPriority],
+		Status:              fields[FieldStatus],
+		WorkerID:            fields[FieldWorkerID],
+		StartedAt:           parseTime(fields[FieldStartedAt]),
+		CompletedAt:         parseTime(fields[FieldCompletedAt]),
+		MPDPublished:        fields[FieldMPDPublished] == "true",
+		Failure:             failure.Decode(fields[FieldFailure]),
+		Thumbnails:          fields[FieldThumbnails],
+		ThumbnailSet:        thumbnail.Decode(fields[FieldThumbnailSet]),
+		Subtitles:           fields[FieldSubtitles],
+		SubtitleSet:         subtitle.Decode(fields[FieldSubtitleSet]),
+		EditedInput:         fields[FieldEditedInput],
+		Representations:     make(map[string]Representation),
+	}
+
+	for _, rep := range job.RequiredResolutions {
+		status := fields[rep]
+		if status == "" {
+			continue // not dispatched yet
+		}
+		attempt, _ := strconv.Atoi(fields[RepField(rep, RepAttempt)])
+		outputSize, _ := strconv.ParseInt(fields[RepField(rep, RepOutputSize)], 10, 64)
+		job.Representations[rep] = Representation{
+			Name:       rep,
+			Status:     status,
+			Resolution: fields[RepField(rep, RepResolution)],
+			Bitrate:    fields[RepField(rep, RepBitrate)],
+			WorkerID:   fields[RepField(rep, RepWorkerID)],
+			Attempt:    attempt,
+			StartedAt:  parseTime(fields[RepField(rep, RepStartedAt)]),
+			FinishedAt: parseTime(fields[RepField(rep, RepFinishedAt)]),
+			OutputPath: fields[RepField(rep, RepOutput)],
+			OutputSize: outputSize,
+			Error:      fields[RepField(rep, RepError)],
+			Failure:    failure.Decode(fields[RepField(rep, RepFailure)]),
+			Quality:    quality.Decode(fields[RepField(rep, RepQuality)]),
+		}
+	}
+	return job
+}
+
+// AllRepresentations reports whether every required representation is in status.
+func (j *Job) AllRepresentations(status string) bool {
+	if len(j.RequiredResolutions) == 0 {
+		return false
+	}
+	for _, rep := range j.RequiredResolutions {
+		if j.Representations[rep].Status != status {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadyToPackage reports whether everything the manifest references has been generated: every
+// representation, and the thumbnails and subtitles if the job asked for them.
+func (j *Job) ReadyToPackage() bool {
+	return j.AllRepresentations("done") &&
+		(j.Thumbnails == "" || j.Thumbnails == "done") &&
+		(j.Subtitles == "" || j.Subtitles == "done")
+}
+
+// AnyRepresentation reports whether at least one required representation is in status.
+func (j *Job) AnyRepresentation(status string) bool {
+	for _, rep := range j.RequiredResolutions {
+		if j.Representations[rep].Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitResolutions parses the comma-separated required_resolutions field.
+func SplitResolutions(v string) []string {
+	var reps []string
+	for _, p := range strings.Split(v, ",") {
+		if rep := strings.TrimSpace(p); rep != "" {
+			reps = append(reps, rep)
+		}
+	}
+	return reps
+}
+
+// Now formats a timestamp the way every service writes them into the hash.
+func Now() string {
+	return time.Now().Format(time.RFC3339)
+}
+
+func parseTime(v string) *time.Time {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,49 @@
+package jobstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *sqlStore) AppendEvent(e JobEvent) error {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	_, err := s.exec(`
+		INSERT INTO job_events (occurred_at, job_id, representation, service, from_state, to_state, worker_id, message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.dialect.timeArg(e.Timestamp), e.JobID, e.Representation, e.Service, e.FromState, e.ToState, e.WorkerID, e.Message)
+	if err != nil {
+		return fmt.Errorf("append event for job %s: %w", e.JobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetJobEvents(jobID string) ([]JobEvent, error) {
+	rows, err := s.query(`
+		SELECT id, occurred_at, job_id, representation, service, from_state, to_state, worker_id, message
+		FROM job_events
+		WHERE job_id = ?
+		ORDER BY occurred_at, id`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []JobEvent{}
+	for rows.Next() {
+		var e JobEvent
+		var representation, fromState, workerID, message sql.NullString
+		err := rows.Scan(&e.ID, &e.Timestamp, &e.JobID, &representation, &e.Service, &fromState, &e.ToState, &workerID, &message)
+		if err != nil {
+			return nil, err
+		}
+		e.Representation = representation.String
+		e.FromState = fromState.String
+		e.WorkerID = workerID.String
+		e.Message = message.String
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,219 @@
+package jobstore
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The tracker applies migrations at startup; every other service calls WaitForSchema before
+// serving so it never runs against an older schema.
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change with its forward and rollback SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// LoadMigrations parses the embedded <version>_<name>.<up|down>.sql files for a dialect, ordered by version.
+func LoadMigrations(dialectName string) ([]Migration, error) {
+	dir := path.Join("migrations", dialectName)
+	files, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		base := strings.TrimSuffix(f.Name(), ".sql")
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", f.Name())
+		}
+		base = strings.TrimSuffix(base, "."+direction)
+
+		versionStr, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>", f.Name())
+		}
+
+		body, err := fs.ReadFile(migrationFiles, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LatestVersion is the schema version this build expects.
+func LatestVersion() int {
+	migrations, err := LoadMigrations("sqlite")
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the highest applied migration, or 0 for an unmigrated database.
+func (s *sqlStore) SchemaVersion() (int, error) {
+	var exists int
+	if err := s.db.QueryRow(s.dialect.tableExistsQuery(), "schema_migrations").Scan(&exists); err != nil || exists == 0 {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate applies every pending migration, each in its own transaction.
+func (s *sqlStore) Migrate() error {
+	if _, err := s.db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	migrations, err := LoadMigrations(s.dialect.name())
+	if err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := s.applyMigration(m.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+			return fmt.Errorf("migration %04d_%s up: %w", m.Version, m.Name, err)
+		}
+		log.Printf("⬆️ Applied migration %04d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// MigrateDown rolls back applied migrations, newest first, until the schema is at target.
+func (s *sqlStore) MigrateDown(target int) error {
+	migrations, err := LoadMigrations(s.dialect.name())
+	if err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		if m.Down == "" {
+			return fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		if err := s.applyMigration(m.Down, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+			return fmt.Errorf("migration %04d_%s down: %w", m.Version, m.Name, err)
+		}
+		log.Printf("⬇️ Rolled back migration %04d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// applyMigration runs a migration script and its schema_migrations bookkeeping in one transaction.
+func (s *sqlStore) applyMigration(script, record string, args ...interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.dialect.rebind(record), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// VerifySchema returns an error unless the database is at exactly the version this build expects.
+func (s *sqlStore) VerifySchema() error {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := LatestVersion(); current != latest {
+		return fmt.Errorf("schema version is %d, expected %d", current, latest)
+	}
+	return nil
+}
+
+// WaitForSchema polls until the owning service has migrated the database, or timeout elapses.
+func (s *sqlStore) WaitForSchema(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	latest := LatestVersion()
+	for {
+		err := s.VerifySchema()
+		if err == nil {
+			return nil
+		}
+		// A newer schema than this build knows about will not fix itself by waiting
+		if current, cerr := s.SchemaVersion(); cerr == nil && current > latest {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		log.Printf("⏳ Waiting for schema migrations: %v", err)
+		time.Sleep(2 * time.Second)
+	}
+}
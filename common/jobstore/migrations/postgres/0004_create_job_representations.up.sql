@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS job_representations (
+	job_id TEXT NOT NULL,
+	representation TEXT NOT NULL,
+	resolution TEXT,
+	bitrate TEXT,
+	codec TEXT,
+	status TEXT,
+	worker_id TEXT,
+	attempt INTEGER NOT NULL DEFAULT 0,
+	started_at TIMESTAMPTZ,
+	finished_at TIMESTAMPTZ,
+	output_path TEXT,
+	output_size BIGINT NOT NULL DEFAULT 0,
+	error TEXT,
+	PRIMARY KEY (job_id, representation)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS job_events (
+	id BIGSERIAL PRIMARY KEY,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	job_id TEXT NOT NULL,
+	representation TEXT,
+	service TEXT NOT NULL,
+	from_state TEXT,
+	to_state TEXT NOT NULL,
+	worker_id TEXT,
+	message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_events_job_id ON job_events(job_id, occurred_at, id);
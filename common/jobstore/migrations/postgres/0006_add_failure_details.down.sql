@@ -0,0 +1,10 @@
+ALTER TABLE job_representations DROP COLUMN stderr_tail;
+ALTER TABLE job_representations DROP COLUMN exit_code;
+ALTER TABLE job_representations DROP COLUMN error_reason;
+ALTER TABLE job_representations DROP COLUMN error_stage;
+
+ALTER TABLE transcoding_jobs DROP COLUMN stderr_tail;
+ALTER TABLE transcoding_jobs DROP COLUMN exit_code;
+ALTER TABLE transcoding_jobs DROP COLUMN error_reason;
+ALTER TABLE transcoding_jobs DROP COLUMN error_stage;
+ALTER TABLE transcoding_jobs DROP COLUMN error;
@@ -0,0 +1 @@
+ALTER TABLE transcoding_jobs DROP COLUMN quality_profile;
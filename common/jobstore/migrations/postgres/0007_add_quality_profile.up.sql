@@ -0,0 +1 @@
+ALTER TABLE transcoding_jobs ADD COLUMN quality_profile TEXT;
@@ -0,0 +1,9 @@
+ALTER TABLE job_representations DROP COLUMN vmaf_mean;
+ALTER TABLE job_representations DROP COLUMN vmaf_min;
+ALTER TABLE job_representations DROP COLUMN vmaf_p5;
+ALTER TABLE job_representations DROP COLUMN psnr_mean;
+ALTER TABLE job_representations DROP COLUMN psnr_min;
+ALTER TABLE job_representations DROP COLUMN psnr_p5;
+ALTER TABLE job_representations DROP COLUMN ssim_mean;
+ALTER TABLE job_representations DROP COLUMN ssim_min;
+ALTER TABLE job_representations DROP COLUMN ssim_p5;
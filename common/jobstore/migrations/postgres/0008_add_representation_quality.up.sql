@@ -0,0 +1,9 @@
+ALTER TABLE job_representations ADD COLUMN vmaf_mean DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN vmaf_min DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN vmaf_p5 DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN psnr_mean DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN psnr_min DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN psnr_p5 DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN ssim_mean DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN ssim_min DOUBLE PRECISION;
+ALTER TABLE job_representations ADD COLUMN ssim_p5 DOUBLE PRECISION;
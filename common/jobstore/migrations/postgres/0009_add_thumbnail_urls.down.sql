@@ -0,0 +1,2 @@
+ALTER TABLE transcoding_jobs DROP COLUMN thumbnails_url;
+ALTER TABLE transcoding_jobs DROP COLUMN poster_url;
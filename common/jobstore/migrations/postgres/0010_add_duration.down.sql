@@ -0,0 +1 @@
+ALTER TABLE transcoding_jobs DROP COLUMN duration;
@@ -0,0 +1 @@
+ALTER TABLE transcoding_jobs ADD COLUMN duration DOUBLE PRECISION;
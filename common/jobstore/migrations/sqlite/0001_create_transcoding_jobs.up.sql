@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS transcoding_jobs (
+	job_id TEXT PRIMARY KEY,
+	stream_name TEXT,
+	input_url TEXT,
+	codec TEXT,
+	representations TEXT,
+	mpd_url TEXT,
+	status TEXT,
+	worker_id TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP
+);
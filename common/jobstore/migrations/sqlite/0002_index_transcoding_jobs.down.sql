@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_jobs_created_at;
+DROP INDEX IF EXISTS idx_jobs_updated_at;
+DROP INDEX IF EXISTS idx_jobs_status_created_at;
+DROP INDEX IF EXISTS idx_jobs_codec_created_at;
+DROP INDEX IF EXISTS idx_jobs_worker_created_at;
+DROP INDEX IF EXISTS idx_jobs_stream_name;
@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON transcoding_jobs(created_at, job_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_updated_at ON transcoding_jobs(updated_at, job_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_created_at ON transcoding_jobs(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_codec_created_at ON transcoding_jobs(codec, created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_worker_created_at ON transcoding_jobs(worker_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_stream_name ON transcoding_jobs(stream_name, job_id);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS batches (
+	batch_id TEXT PRIMARY KEY,
+	tenant_id TEXT,
+	status TEXT,
+	webhook_url TEXT,
+	job_count INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	completed_at TIMESTAMP,
+	notified_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS batch_jobs (
+	job_id TEXT PRIMARY KEY,
+	batch_id TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_jobs_batch_id ON batch_jobs(batch_id);
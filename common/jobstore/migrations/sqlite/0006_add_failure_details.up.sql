@@ -0,0 +1,10 @@
+ALTER TABLE transcoding_jobs ADD COLUMN error TEXT;
+ALTER TABLE transcoding_jobs ADD COLUMN error_stage TEXT;
+ALTER TABLE transcoding_jobs ADD COLUMN error_reason TEXT;
+ALTER TABLE transcoding_jobs ADD COLUMN exit_code INTEGER;
+ALTER TABLE transcoding_jobs ADD COLUMN stderr_tail TEXT;
+
+ALTER TABLE job_representations ADD COLUMN error_stage TEXT;
+ALTER TABLE job_representations ADD COLUMN error_reason TEXT;
+ALTER TABLE job_representations ADD COLUMN exit_code INTEGER;
+ALTER TABLE job_representations ADD COLUMN stderr_tail TEXT;
@@ -0,0 +1,9 @@
+ALTER TABLE job_representations ADD COLUMN vmaf_mean REAL;
+ALTER TABLE job_representations ADD COLUMN vmaf_min REAL;
+ALTER TABLE job_representations ADD COLUMN vmaf_p5 REAL;
+ALTER TABLE job_representations ADD COLUMN psnr_mean REAL;
+ALTER TABLE job_representations ADD COLUMN psnr_min REAL;
+ALTER TABLE job_representations ADD COLUMN psnr_p5 REAL;
+ALTER TABLE job_representations ADD COLUMN ssim_mean REAL;
+ALTER TABLE job_representations ADD COLUMN ssim_min REAL;
+ALTER TABLE job_representations ADD COLUMN ssim_p5 REAL;
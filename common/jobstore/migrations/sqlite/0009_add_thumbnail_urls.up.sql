@@ -0,0 +1,2 @@
+ALTER TABLE transcoding_jobs ADD COLUMN poster_url TEXT;
+ALTER TABLE transcoding_jobs ADD COLUMN thumbnails_url TEXT;
@@ -0,0 +1,140 @@
+package jobstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"common/quality"
+)
+
+const upsertRepresentationStmt = `
+	INSERT INTO job_representations
+	(job_id, representation, resolution, bitrate, codec, status, worker_id, attempt,
+	 started_at, finished_at, output_path, output_size, error, error_stage, error_reason, exit_code, stderr_tail,
+	 vmaf_mean, vmaf_min, vmaf_p5, psnr_mean, psnr_min, psnr_p5, ssim_mean, ssim_min, ssim_p5)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(job_id, representation) DO UPDATE SET
+		resolution  = excluded.resolution,
+		bitrate     = excluded.bitrate,
+		codec       = excluded.codec,
+		status      = excluded.status,
+		worker_id   = excluded.worker_id,
+		attempt     = excluded.attempt,
+		started_at  = excluded.started_at,
+		finished_at = excluded.finished_at,
+		output_path = excluded.output_path,
+		output_size = excluded.output_size,
+		error        = excluded.error,
+		error_stage  = excluded.error_stage,
+		error_reason = excluded.error_reason,
+		exit_code    = excluded.exit_code,
+		stderr_tail  = excluded.stderr_tail,
+		vmaf_mean = excluded.vmaf_mean,
+		vmaf_min  = excluded.vmaf_min,
+		vmaf_p5   = excluded.vmaf_p5,
+		psnr_mean = excluded.psnr_mean,
+		psnr_min  = excluded.psnr_min,
+		psnr_p5   = excluded.psnr_p5,
+		ssim_mean = excluded.ssim_mean,
+		ssim_min  = excluded.ssim_min,
+		ssim_p5   = excluded.ssim_p5`
+
+func (s *sqlStore) UpsertRepresentation(rep Representation) error {
+	// A representation without a failure clears any failure left by an earlier attempt
+	var errMsg, errStage, errReason, exitCode, stderrTail interface{}
+	if f := rep.Failure; f != nil {
+		errMsg, errStage, errReason, exitCode, stderrTail = f.Message, f.Stage, f.Reason, f.ExitCode, f.StderrTail
+	}
+	args := []interface{}{
+		rep.JobID, rep.Representation, rep.Resolution, rep.Bitrate, rep.Codec, rep.Status, rep.WorkerID, rep.Attempt,
+		s.optionalTime(rep.StartedAt), s.optionalTime(rep.FinishedAt), rep.OutputPath, rep.OutputSize,
+		errMsg, errStage, errReason, exitCode, stderrTail,
+	}
+	// Likewise, unmeasured metrics are stored as NULL
+	var scores [3]*quality.Score
+	if q := rep.Quality; q != nil {
+		scores = [3]*quality.Score{q.VMAF, q.PSNR, q.SSIM}
+	}
+	for _, score := range scores {
+		if score == nil {
+			args = append(args, nil, nil, nil)
+		} else {
+			args = append(args, score.Mean, score.Min, score.P5)
+		}
+	}
+	_, err := s.exec(upsertRepresentationStmt, args...)
+	if err != nil {
+		return fmt.Errorf("upsert representation %s/%s: %w", rep.JobID, rep.Representation, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetRepresentations(jobID string) ([]Representation, error) {
+	rows, err := s.query(`
+		SELECT job_id, representation, resolution, bitrate, codec, status, worker_id, attempt,
+		       started_at, finished_at, output_path, output_size, error, error_stage, error_reason, exit_code, stderr_tail,
+		       vmaf_mean, vmaf_min, vmaf_p5, psnr_mean, psnr_min, psnr_p5, ssim_mean, ssim_min, ssim_p5
+		FROM job_representations
+		WHERE job_id = ?
+		ORDER BY representation`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reps := []Representation{}
+	for rows.Next() {
+		var rep Representation
+		var resolution, bitrate, codec, status, workerID, outputPath sql.NullString
+		var errMsg, errStage, errReason, stderrTail sql.NullString
+		var exitCode sql.NullInt64
+		var startedAt, finishedAt sql.NullTime
+		var vmaf, psnr, ssim [3]sql.NullFloat64
+		err := rows.Scan(&rep.JobID, &rep.Representation, &resolution, &bitrate, &codec, &status, &workerID, &rep.Attempt,
+			&startedAt, &finishedAt, &outputPath, &rep.OutputSize, &errMsg, &errStage, &errReason, &exitCode, &stderrTail,
+			&vmaf[0], &vmaf[1], &vmaf[2], &psnr[0], &psnr[1], &psnr[2], &ssim[0], &ssim[1], &ssim[2])
+		if err != nil {
+			return nil, err
+		}
+		rep.Resolution = resolution.String
+		rep.Bitrate = bitrate.String
+		rep.Codec = codec.String
+		rep.Status = status.String
+		rep.WorkerID = workerID.String
+		rep.OutputPath = outputPath.String
+		rep.Failure = scanFailure(errMsg, errStage, errReason, exitCode, stderrTail)
+		rep.Quality = scanQuality(vmaf, psnr, ssim)
+		if startedAt.Valid {
+			rep.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			rep.FinishedAt = &finishedAt.Time
+		}
+		reps = append(reps, rep)
+	}
+	return reps, rows.Err()
+}
+
+// scanQuality rebuilds the mean/min/p5 columns of each metric, or returns nil if none was measured.
+func scanQuality(vmaf, psnr, ssim [3]sql.NullFloat64) *quality.Report {
+	score := func(cols [3]sql.NullFloat64) *quality.Score {
+		if !cols[0].Valid {
+			return nil
+		}
+		return &quality.Score{Mean: cols[0].Float64, Min: cols[1].Float64, P5: cols[2].Float64}
+	}
+	r := quality.Report{VMAF: score(vmaf), PSNR: score(psnr), SSIM: score(ssim)}
+	if r.VMAF == nil && r.PSNR == nil && r.SSIM == nil {
+		return nil
+	}
+	return &r
+}
+
+// optionalTime maps a nil timestamp to SQL NULL.
+func (s *sqlStore) optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return s.dialect.timeArg(*t)
+}
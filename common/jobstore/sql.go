@@ -0,0 +1,457 @@
+package jobstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"common/failure"
+)
+
+// dialect captures the few places SQLite and PostgreSQL disagree; every query is written
+// once with ? placeholders and rebound per dialect.
+type dialect interface {
+	// name is also the migrations/<name> directory.
+	name() string
+	rebind(query string) string
+	// prefixMatch returns a condition and argument matching column values that start with prefix.
+	prefixMatch(column, prefix string) (string, interface{})
+	timeArg(t time.Time) interface{}
+	// cursorTime converts a timestamp read back from the driver into a comparable query argument.
+	cursorTime(ts string) interface{}
+	// tableExistsQuery takes the table name and returns a count.
+	tableExistsQuery() string
+}
+
+// sqlStore implements Store on database/sql for any dialect.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+const jobColumns = `job_id, stream_name, input_url, codec, representations, mpd_url, status, worker_id, created_at, updated_at, ` +
+	`error, error_stage, error_reason, exit_code, stderr_tail, quality_profile, ` +
+	`poster_url, thumbnails_url, duration`
+
+// qualifiedJobColumns is jobColumns for a query that aliases transcoding_jobs as j.
+var qualifiedJobColumns = "j." + strings.Join(strings.Split(jobColumns, ", "), ", j.")
+
+const upsertJobStmt = `
+	INSERT INTO transcoding_jobs
+	(job_id, stream_name, input_url, codec, quality_profile, representations, status, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	ON CONFLICT(job_id) DO UPDATE SET
+		stream_name=excluded.stream_name,
+		input_url=excluded.input_url,
+		codec=excluded.codec,
+		quality_profile=excluded.quality_profile,
+		representations=excluded.representations,
+		status=excluded.status,
+		updated_at=CURRENT_TIMESTAMP`
+
+// safeUpsertJobStmt keeps the stored value wherever the incoming one is empty, in one statement
+// so concurrent updates cannot interleave between a read and a write. A job in a final state is
+// left as it is.
+const safeUpsertJobStmt = `
+	INSERT INTO transcoding_jobs
+	(job_id, stream_name, input_url, codec, representations, worker_id, status, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	ON CONFLICT(job_id) DO UPDATE SET
+		stream_name     = COALESCE(NULLIF(excluded.stream_name, ''), transcoding_jobs.stream_name),
+		input_url       = COALESCE(NULLIF(excluded.input_url, ''), transcoding_jobs.input_url),
+		codec           = COALESCE(NULLIF(excluded.codec, ''), transcoding_jobs.codec),
+		representations = COALESCE(NULLIF(excluded.representations, ''), transcoding_jobs.representations),
+		worker_id       = COALESCE(NULLIF(excluded.worker_id, ''), transcoding_jobs.worker_id),
+		status          = COALESCE(NULLIF(excluded.status, ''), transcoding_jobs.status),
+		updated_at      = CURRENT_TIMESTAMP
+	WHERE ` + finalStatusGuard
+
+// finalStatusGuard holds when a job may still change status: it is neither cancelled nor done
+// and packaged. Done alone is not final, as the tracker syncs a job as done before handing it to
+// the mpd-generator. A failed job is only reopened by a retry, which the tracker syncs from Redis.
+const finalStatusGuard = `transcoding_jobs.status <> 'cancelled' AND NOT (transcoding_jobs.status = 'done' AND COALESCE(transcoding_jobs.mpd_url, '') <> '')`
+
+func (s *sqlStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqlStore) InsertJob(job Job) error {
+	_, err := s.exec(upsertJobStmt, job.JobID, job.StreamName, job.InputURL, job.Codec, job.QualityProfile, job.Representations, job.Status)
+	if err != nil {
+		return fmt.Errorf("insert job %s: %w", job.JobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) SafeUpdateJobMetadata(job Job) error {
+	_, err := s.exec(safeUpsertJobStmt, job.JobID, job.StreamName, job.InputURL, job.Codec, job.Representations, job.WorkerID, job.Status)
+	if err != nil {
+		return fmt.Errorf("update job %s: %w", job.JobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateJobStatus(jobID, status string) error {
+	_, err := s.exec(`
+		UPDATE transcoding_jobs SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE job_id = ? AND transcoding_jobs.status <> 'failed' AND `+finalStatusGuard, status, jobID)
+	if err != nil {
+		return fmt.Errorf("update status for job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateMPDURL(jobID, mpdURL string) error {
+	_, err := s.exec(`UPDATE transcoding_jobs SET mpd_url = ?, updated_at = CURRENT_TIMESTAMP WHERE job_id = ?`, mpdURL, jobID)
+	if err != nil {
+		return fmt.Errorf("update MPD URL for job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateThumbnailURLs(jobID, posterURL, thumbnailsURL string) error {
+	_, err := s.exec(`UPDATE transcoding_jobs SET poster_url = ?, thumbnails_url = ?, updated_at = CURRENT_TIMESTAMP WHERE job_id = ?`,
+		posterURL, thumbnailsURL, jobID)
+	if err != nil {
+		return fmt.Errorf("update thumbnail URLs for job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateDuration(jobID string, seconds float64) error {
+	_, err := s.exec(`UPDATE transcoding_jobs SET duration = ?, updated_at = CURRENT_TIMESTAMP WHERE job_id = ?`, seconds, jobID)
+	if err != nil {
+		return fmt.Errorf("update duration for job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) MarkJobFailed(jobID string, f failure.Failure) error {
+	_, err := s.exec(`
+		UPDATE transcoding_jobs
+		SET status = 'failed', error = ?, error_stage = ?, error_reason = ?, exit_code = ?, stderr_tail = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE job_id = ?`, f.Message, f.Stage, f.Reason, f.ExitCode, f.StderrTail, jobID)
+	if err != nil {
+		return fmt.Errorf("mark job %s failed: %w", jobID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetJob(jobID string) (*Job, error) {
+	rows, err := s.query(`SELECT `+jobColumns+` FROM transcoding_jobs WHERE job_id = ?`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+func (s *sqlStore) ListJobs(q ListQuery) (Page, error) {
+	var where []string
+	var args []interface{}
+
+	if len(q.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(q.Statuses)-1)+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+	if q.Codec != "" {
+		where = append(where, "codec = ?")
+		args = append(args, q.Codec)
+	}
+	if q.StreamNamePrefix != "" {
+		cond, arg := s.dialect.prefixMatch("stream_name", q.StreamNamePrefix)
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if !q.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, s.dialect.timeArg(q.CreatedAfter))
+	}
+	if !q.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, s.dialect.timeArg(q.CreatedBefore))
+	}
+	if q.WorkerID != "" {
+		where = append(where, "worker_id = ?")
+		args = append(args, q.WorkerID)
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var page Page
+	if err := s.queryRow("SELECT COUNT(*) FROM transcoding_jobs "+filter, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	sortCol, ok := SortColumns[q.SortBy]
+	if !ok {
+		sortCol = "created_at"
+	}
+	cmp, dir := ">", "ASC"
+	if q.Descending {
+		cmp, dir = "<", "DESC"
+	}
+	if q.After != nil {
+		var sortValue interface{} = q.After.SortValue
+		if sortCol != "stream_name" {
+			sortValue = s.dialect.cursorTime(q.After.SortValue)
+		}
+		where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND job_id %s ?))", sortCol, cmp, sortCol, cmp))
+		args = append(args, sortValue, sortValue, q.After.JobID)
+	}
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	// Fetch one extra row to know whether another page follows
+	args = append(args, q.Limit+1)
+	rows, err := s.query(fmt.Sprintf(`
+		SELECT %s
+		FROM transcoding_jobs
+		%s
+		ORDER BY %s %s, job_id %s
+		LIMIT ?`, jobColumns, filter, sortCol, dir, dir), args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return page, err
+	}
+	if len(jobs) > q.Limit {
+		jobs = jobs[:q.Limit]
+		last := jobs[len(jobs)-1]
+		page.NextCursor = EncodeCursor(Cursor{SortValue: sortValue(last, sortCol), JobID: last.JobID})
+	}
+	page.Jobs = jobs
+	if page.Jobs == nil {
+		page.Jobs = []Job{}
+	}
+	return page, nil
+}
+
+func sortValue(job Job, sortCol string) string {
+	switch sortCol {
+	case "updated_at":
+		return job.UpdatedAt
+	case "stream_name":
+		return job.StreamName
+	default:
+		return job.CreatedAt
+	}
+}
+
+// scanJobs reads rows selected with jobColumns.
+func scanJobs(rows *sql.Rows) ([]Job, error) {
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		var streamName, inputURL, codec, representations, mpdURL, status, workerID, createdAt, updatedAt sql.NullString
+		var errMsg, errStage, errReason, stderrTail, qualityProfile, posterURL, thumbnailsURL sql.NullString
+		var exitCode sql.NullInt64
+		var duration sql.NullFloat64
+		err := rows.Scan(&job.JobID, &streamName, &inputURL, &codec, &representations,
+			&mpdURL, &status, &workerID, &createdAt, &updatedAt,
+			&errMsg, &errStage, &errReason, &exitCode, &stderrTail, &qualityProfile,
+			&posterURL, &thumbnailsURL, &duration)
+		if err != nil {
+			log.Printf("⚠️ Scan error: %v", err)
+			continue
+		}
+		job.StreamName = streamName.String
+		job.InputURL = inputURL.String
+		job.Codec = codec.String
+		job.QualityProfile = qualityProfile.String
+		job.Representations = representations.String
+		job.MPDURL = mpdURL.String
+		job.PosterURL = posterURL.String
+		job.ThumbnailsURL = thumbnailsURL.String
+		job.Duration = duration.Float64
+		job.Status = status.String
+		job.WorkerID = workerID.String
+		job.CreatedAt = createdAt.String
+		job.UpdatedAt = updatedAt.String
+		job.Failure = scanFailure(errMsg, errStage, errReason, exitCode, stderrTail)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// scanFailure rebuilds the failure columns, or returns nil if no failure was recorded.
+func scanFailure(message, stage, reason sql.NullString, exitCode sql.NullInt64, stderrTail sql.NullString) *failure.Failure {
+	if message.String == "" && stage.String == "" {
+		return nil
+	}
+	f := failure.Failure{
+		Stage:      stage.String,
+		Reason:     reason.String,
+		ExitCode:   -1,
+		Message:    message.String,
+		StderrTail: stderrTail.String,
+	}
+	if exitCode.Valid {
+		f.ExitCode = int(exitCode.Int64)
+	}
+	if f.Reason == "" {
+		f.Reason = failure.ReasonUnknown
+	}
+	return &f
+}
+
+func (s *sqlStore) InsertBatch(batch Batch, jobs []Job) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(s.dialect.rebind(`
+		INSERT INTO batches (batch_id, tenant_id, status, webhook_url, job_count, created_at)
+		VALUES (?, ?, 'running', ?, ?, CURRENT_TIMESTAMP)`),
+		batch.BatchID, batch.TenantID, batch.WebhookURL, len(jobs))
+	if err != nil {
+		return fmt.Errorf("insert batch %s: %w", batch.BatchID, err)
+	}
+
+	for _, job := range jobs {
+		if _, err := tx.Exec(s.dialect.rebind(upsertJobStmt), job.JobID, job.StreamName, job.InputURL, job.Codec, job.QualityProfile, job.Representations, "waiting"); err != nil {
+			return fmt.Errorf("insert job %s: %w", job.JobID, err)
+		}
+		if _, err := tx.Exec(s.dialect.rebind(`INSERT INTO batch_jobs (job_id, batch_id) VALUES (?, ?)`), job.JobID, batch.BatchID); err != nil {
+			return fmt.Errorf("link job %s: %w", job.JobID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) GetBatch(batchID string) (*Batch, error) {
+	var b Batch
+	var webhookURL, createdAt, completedAt sql.NullString
+	err := s.queryRow(`
+		SELECT batch_id, tenant_id, status, webhook_url, job_count, created_at, completed_at
+		FROM batches
+		WHERE batch_id = ?`, batchID).
+		Scan(&b.BatchID, &b.TenantID, &b.Status, &webhookURL, &b.JobCount, &createdAt, &completedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.WebhookURL = webhookURL.String
+	b.CreatedAt = createdAt.String
+	b.CompletedAt = completedAt.String
+	return &b, nil
+}
+
+func (s *sqlStore) GetBatchJobs(batchID string) ([]Job, error) {
+	rows, err := s.query(`
+		SELECT `+qualifiedJobColumns+`
+		FROM batch_jobs b
+		JOIN transcoding_jobs j ON j.job_id = b.job_id
+		WHERE b.batch_id = ?
+		ORDER BY j.created_at, j.job_id`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
+
+func (s *sqlStore) CancelBatch(batchID string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(s.dialect.rebind(`
+		SELECT j.job_id
+		FROM batch_jobs b
+		JOIN transcoding_jobs j ON j.job_id = b.job_id
+		WHERE b.batch_id = ? AND j.status NOT IN ('done', 'failed', 'cancelled')`), batchID)
+	if err != nil {
+		return nil, err
+	}
+	var jobIDs []string
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	rows.Close()
+
+	for _, jobID := range jobIDs {
+		if _, err := tx.Exec(s.dialect.rebind(`UPDATE transcoding_jobs SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP WHERE job_id = ?`), jobID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(s.dialect.rebind(`UPDATE batches SET status = 'cancelled' WHERE batch_id = ?`), batchID); err != nil {
+		return nil, err
+	}
+
+	return jobIDs, tx.Commit()
+}
+
+func (s *sqlStore) GetUnnotifiedBatches() ([]Batch, error) {
+	rows, err := s.query(`
+		SELECT batch_id, tenant_id, status, webhook_url, job_count, created_at
+		FROM batches
+		WHERE notified_at IS NULL AND status IN ('running', 'cancelled')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []Batch
+	for rows.Next() {
+		var b Batch
+		var webhookURL, createdAt sql.NullString
+		if err := rows.Scan(&b.BatchID, &b.TenantID, &b.Status, &webhookURL, &b.JobCount, &createdAt); err != nil {
+			log.Printf("⚠️ Scan error: %v", err)
+			continue
+		}
+		b.WebhookURL = webhookURL.String
+		b.CreatedAt = createdAt.String
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+func (s *sqlStore) MarkBatchFinished(batchID, status string) error {
+	_, err := s.exec(`
+		UPDATE batches
+		SET status = ?, completed_at = CURRENT_TIMESTAMP, notified_at = CURRENT_TIMESTAMP
+		WHERE batch_id = ?`, status, batchID)
+	return err
+}
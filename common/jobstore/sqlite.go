@@ -0,0 +1,67 @@
+package jobstore
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// NewSQLite opens the SQLite database at path in WAL mode, so readers in other containers do not
+// block the writer, with a busy timeout instead of failing fast with "database is locked".
+func NewSQLite(path string) (Store, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: sqliteDialect{}}, nil
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string { return "sqlite" }
+
+func (sqliteDialect) rebind(query string) string { return query }
+
+// GLOB is case-sensitive and lets SQLite use the stream_name index for prefix scans.
+func (sqliteDialect) prefixMatch(column, prefix string) (string, interface{}) {
+	return column + " GLOB ?", globEscape(prefix) + "*"
+}
+
+// Timestamps are stored as CURRENT_TIMESTAMP text, so comparisons must use the same format.
+func (sqliteDialect) timeArg(t time.Time) interface{} {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// The driver returns TIMESTAMP columns as RFC 3339; convert back to the stored text form.
+func (sqliteDialect) cursorTime(ts string) interface{} {
+	if t, err := time.Parse(time.RFC3339, ts); err == nil {
+		return t.UTC().Format(sqliteTimeLayout)
+	}
+	return ts
+}
+
+func (sqliteDialect) tableExistsQuery() string {
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+}
+
+// globEscape wraps GLOB metacharacters in brackets so they match literally.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[':
+			b.WriteString("[" + string(r) + "]")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,199 @@
+// Package jobstore is the job and batch repository shared by every service, with SQLite and
+// PostgreSQL implementations selected by DB_DRIVER.
+package jobstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"common/failure"
+	"common/quality"
+)
+
+// Job is one row of transcoding_jobs.
+type Job struct {
+	JobID           string  `json:"job_id"`
+	StreamName      string  `json:"stream_name"`
+	InputURL        string  `json:"input_url"`
+	Codec           string  `json:"codec"`
+	QualityProfile  string  `json:"quality_profile,omitempty"`
+	Representations string  `json:"representations"`
+	MPDURL          string  `json:"mpd_url"`
+	PosterURL       string  `json:"poster_url,omitempty"`
+	ThumbnailsURL   string  `json:"thumbnails_url,omitempty"` // WebVTT track of the scrubbing thumbnails
+	Duration        float64 `json:"duration,omitempty"`       // seconds, probed from the rendered edit list
+	Status          string  `json:"status"`
+	WorkerID        string  `json:"worker_id"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at"`
+
+	Failure *failure.Failure `json:"failure,omitempty"`
+}
+
+// Representation is one rendition of a job, as last reported by the worker that encoded it.
+type Representation struct {
+	JobID          string     `json:"job_id"`
+	Representation string     `json:"representation"`
+	Resolution     string     `json:"resolution"`
+	Bitrate        string     `json:"bitrate"`
+	Codec          string     `json:"codec"`
+	Status         string     `json:"status"`
+	WorkerID       string     `json:"worker_id,omitempty"`
+	Attempt        int        `json:"attempt"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	OutputPath     string     `json:"output_path,omitempty"`
+	OutputSize     int64      `json:"output_size"`
+
+	Failure *failure.Failure `json:"failure,omitempty"`
+	Quality *quality.Report  `json:"quality,omitempty"`
+}
+
+// JobEvent is one state transition in a job's append-only history.
+type JobEvent struct {
+	ID             int64     `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	JobID          string    `json:"job_id"`
+	Representation string    `json:"representation,omitempty"`
+	Service        string    `json:"service"`
+	FromState      string    `json:"from_state"`
+	ToState        string    `json:"to_state"`
+	WorkerID       string    `json:"worker_id,omitempty"`
+	Message        string    `json:"message,omitempty"`
+}
+
+// Batch is a group of jobs submitted together via POST /batches.
+type Batch struct {
+	BatchID     string `json:"batch_id"`
+	TenantID    string `json:"tenant_id"`
+	Status      string `json:"status"`
+	WebhookURL  string `json:"webhook_url,omitempty"`
+	JobCount    int    `json:"job_count"`
+	CreatedAt   string `json:"created_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+}
+
+// ListQuery holds the filters, sort and page position for ListJobs.
+type ListQuery struct {
+	Statuses         []string
+	Codec            string
+	StreamNamePrefix string
+	CreatedAfter     time.Time // inclusive, ignored if zero
+	CreatedBefore    time.Time // exclusive, ignored if zero
+	WorkerID         string
+	SortBy           string // created_at, updated_at or stream_name
+	Descending       bool
+	Limit            int
+	After            *Cursor
+}
+
+// Cursor is the sort key and job_id of the last row of the previous page.
+type Cursor struct {
+	SortValue string `json:"v"`
+	JobID     string `json:"id"`
+}
+
+// Page is one page of ListJobs results.
+type Page struct {
+	Jobs       []Job  `json:"jobs"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// SortColumns maps sort names to columns; each has a matching (column, job_id) index.
+var SortColumns = map[string]string{
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+	"stream_name": "stream_name",
+}
+
+// Store is the job repository. Implementations must pass storetest.Run.
+type Store interface {
+	// InsertJob upserts a newly submitted job with its request fields and status.
+	InsertJob(job Job) error
+	// SafeUpdateJobMetadata upserts a job but keeps existing values for any empty field. It leaves
+	// the status of a cancelled job, or of a done job once packaged, as it is.
+	SafeUpdateJobMetadata(job Job) error
+	// UpdateJobStatus moves a job forward; a failed, cancelled or packaged job keeps its status.
+	UpdateJobStatus(jobID, status string) error
+	UpdateMPDURL(jobID, mpdURL string) error
+	// UpdateThumbnailURLs records where the job's poster and thumbnail track are published.
+	UpdateThumbnailURLs(jobID, posterURL, thumbnailsURL string) error
+	// UpdateDuration records the probed duration of the input the job is encoded from.
+	UpdateDuration(jobID string, seconds float64) error
+	// MarkJobFailed sets the job's status to failed and records why.
+	MarkJobFailed(jobID string, f failure.Failure) error
+	// GetJob returns the job, or nil if it does not exist.
+	GetJob(jobID string) (*Job, error)
+	// ListJobs returns one page of jobs matching q plus the total number of matches.
+	ListJobs(q ListQuery) (Page, error)
+
+	// UpsertRepresentation writes the full state of one rendition, replacing the stored row.
+	UpsertRepresentation(rep Representation) error
+	// GetRepresentations lists a job's renditions ordered by name.
+	GetRepresentations(jobID string) ([]Representation, error)
+
+	// AppendEvent adds a transition to the job's history; events are never updated or deleted.
+	AppendEvent(event JobEvent) error
+	// GetJobEvents returns the job's history, oldest first.
+	GetJobEvents(jobID string) ([]JobEvent, error)
+
+	// InsertBatch creates the batch and all of its jobs (status "waiting") in a single transaction.
+	InsertBatch(batch Batch, jobs []Job) error
+	// GetBatch returns the batch, or nil if it does not exist.
+	GetBatch(batchID string) (*Batch, error)
+	GetBatchJobs(batchID string) ([]Job, error)
+	// CancelBatch marks the batch and its unfinished jobs cancelled, returning the cancelled job IDs.
+	CancelBatch(batchID string) ([]string, error)
+	// GetUnnotifiedBatches returns running or cancelled batches whose completion has not been reported yet.
+	GetUnnotifiedBatches() ([]Batch, error)
+	// MarkBatchFinished records that every job in the batch reached a terminal state.
+	MarkBatchFinished(batchID, status string) error
+
+	Migrate() error
+	MigrateDown(target int) error
+	SchemaVersion() (int, error)
+	WaitForSchema(timeout time.Duration) error
+	Close() error
+}
+
+// OpenFromEnv opens the store selected by DB_DRIVER (sqlite or postgres, default sqlite).
+// SQLite reads SQLITE_DB_PATH; PostgreSQL reads DATABASE_URL.
+func OpenFromEnv() (Store, error) {
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "sqlite":
+		path := os.Getenv("SQLITE_DB_PATH")
+		if path == "" {
+			path = "/app/db/data/jobs.db"
+		}
+		return NewSQLite(path)
+	case "postgres":
+		dsn := os.Getenv("DATABASE_URL")
+		if dsn == "" {
+			return nil, fmt.Errorf("DATABASE_URL must be set when DB_DRIVER=postgres")
+		}
+		return NewPostgres(dsn)
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q (want sqlite or postgres)", driver)
+	}
+}
+
+// EncodeCursor turns a cursor into the opaque next_cursor token.
+func EncodeCursor(c Cursor) string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor.
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(payload, &c)
+	return c, err
+}
@@ -0,0 +1,512 @@
+// Package storetest is the conformance suite every jobstore.Store implementation must pass.
+// It works against a migrated, possibly shared database: all rows it writes use a fresh prefix.
+package storetest
+
+import (
+	"fmt"
+	"time"
+
+	"common/failure"
+	"common/jobstore"
+	"common/quality"
+
+	"github.com/google/uuid"
+)
+
+// checks are run in order, each with a fresh prefix for the rows it writes.
+var checks = []struct {
+	name string
+	fn   func(jobstore.Store, string) error
+}{
+	{"schema", checkSchema},
+	{"insert and get", checkInsertAndGet},
+	{"safe update", checkSafeUpdate},
+	{"status and mpd", checkStatusAndMPD},
+	{"final statuses", checkFinalStatuses},
+	{"list jobs", checkListJobs},
+	{"representations", checkRepresentations},
+	{"events", checkEvents},
+	{"failures", checkFailures},
+	{"batches", checkBatches},
+}
+
+// Run executes every check against s and returns the first failure.
+func Run(s jobstore.Store) error {
+	for _, c := range checks {
+		if err := c.fn(s, newPrefix()); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+func newPrefix() string {
+	return "storetest-" + uuid.NewString()[:8] + "-"
+}
+
+func checkSchema(s jobstore.Store, _ string) error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version != jobstore.LatestVersion() {
+		return fmt.Errorf("schema version %d, want %d", version, jobstore.LatestVersion())
+	}
+	return s.WaitForSchema(time.Second)
+}
+
+func checkInsertAndGet(s jobstore.Store, prefix string) error {
+	want := jobstore.Job{
+		JobID:           prefix + "job",
+		StreamName:      prefix + "stream",
+		InputURL:        "https://example.com/in.mp4",
+		Codec:           "h264",
+		QualityProfile:  "archive",
+		Representations: "1080p,720p",
+		Status:          "waiting",
+	}
+	if err := s.InsertJob(want); err != nil {
+		return err
+	}
+	got, err := s.GetJob(want.JobID)
+	if err != nil {
+		return err
+	}
+	if got == nil {
+		return fmt.Errorf("job %s not found after insert", want.JobID)
+	}
+	if got.StreamName != want.StreamName || got.Codec != want.Codec || got.QualityProfile != want.QualityProfile ||
+		got.Representations != want.Representations || got.Status != want.Status {
+		return fmt.Errorf("got %+v, want %+v", *got, want)
+	}
+	if got.CreatedAt == "" {
+		return fmt.Errorf("created_at not set")
+	}
+
+	missing, err := s.GetJob(prefix + "missing")
+	if err != nil {
+		return err
+	}
+	if missing != nil {
+		return fmt.Errorf("GetJob of unknown id returned %+v", *missing)
+	}
+	return nil
+}
+
+func checkSafeUpdate(s jobstore.Store, prefix string) error {
+	jobID := prefix + "job"
+	if err := s.InsertJob(jobstore.Job{JobID: jobID, StreamName: "stream", InputURL: "in", Codec: "h264", Representations: "720p", Status: "waiting"}); err != nil {
+		return err
+	}
+	// Only status and worker are known; everything else must survive
+	if err := s.SafeUpdateJobMetadata(jobstore.Job{JobID: jobID, WorkerID: "worker-1", Status: "transcoding"}); err != nil {
+		return err
+	}
+	got, err := s.GetJob(jobID)
+	if err != nil {
+		return err
+	}
+	if got.StreamName != "stream" || got.InputURL != "in" || got.Codec != "h264" || got.Representations != "720p" {
+		return fmt.Errorf("metadata overwritten with empty values: %+v", *got)
+	}
+	if got.Status != "transcoding" || got.WorkerID != "worker-1" {
+		return fmt.Errorf("status/worker not updated: %+v", *got)
+	}
+
+	// Unknown jobs are created
+	newID := prefix + "new"
+	if err := s.SafeUpdateJobMetadata(jobstore.Job{JobID: newID, StreamName: "s2", Status: "waiting"}); err != nil {
+		return err
+	}
+	if got, err := s.GetJob(newID); err != nil || got == nil || got.StreamName != "s2" {
+		return fmt.Errorf("safe update did not insert new job: %+v, %v", got, err)
+	}
+	return nil
+}
+
+func checkStatusAndMPD(s jobstore.Store, prefix string) error {
+	jobID := prefix + "job"
+	if err := s.InsertJob(jobstore.Job{JobID: jobID, StreamName: "stream", Status: "waiting"}); err != nil {
+		return err
+	}
+	if err := s.UpdateJobStatus(jobID, "done"); err != nil {
+		return err
+	}
+	if err := s.UpdateMPDURL(jobID, "https://cdn.example.com/stream.mpd"); err != nil {
+		return err
+	}
+	if err := s.UpdateThumbnailURLs(jobID, "https://cdn.example.com/poster.jpg", "https://cdn.example.com/thumbnails.vtt"); err != nil {
+		return err
+	}
+	if err := s.UpdateDuration(jobID, 176.04); err != nil {
+		return err
+	}
+	got, err := s.GetJob(jobID)
+	if err != nil {
+		return err
+	}
+	if got.Status != "done" || got.MPDURL != "https://cdn.example.com/stream.mpd" || got.UpdatedAt == "" {
+		return fmt.Errorf("got %+v", *got)
+	}
+	if got.PosterURL != "https://cdn.example.com/poster.jpg" || got.ThumbnailsURL != "https://cdn.example.com/thumbnails.vtt" {
+		return fmt.Errorf("thumbnail URLs not stored: %+v", *got)
+	}
+	if got.Duration != 176.04 {
+		return fmt.Errorf("duration not stored: %+v", *got)
+	}
+	return nil
+}
+
+// checkFinalStatuses expects a late status write to leave a cancelled, failed or packaged job
+// as it is, except for a retry reopening a failed job through SafeUpdateJobMetadata.
+func checkFinalStatuses(s jobstore.Store, prefix string) error {
+	cancelled, failed, packaged, ready := prefix+"cancelled", prefix+"failed", prefix+"packaged", prefix+"ready"
+	for _, job := range []jobstore.Job{
+		{JobID: cancelled, Status: "cancelled"},
+		{JobID: failed, Status: "failed"},
+		{JobID: packaged, Status: "done"},
+		{JobID: ready, Status: "done"},
+	} {
+		if err := s.InsertJob(job); err != nil {
+			return err
+		}
+	}
+	if err := s.UpdateMPDURL(packaged, "https://cdn.example.com/stream.mpd"); err != nil {
+		return err
+	}
+
+	for _, jobID := range []string{cancelled, failed, packaged, ready} {
+		if err := s.UpdateJobStatus(jobID, "ready_for_mpd"); err != nil {
+			return err
+		}
+	}
+	for _, jobID := range []string{cancelled, packaged} {
+		if err := s.SafeUpdateJobMetadata(jobstore.Job{JobID: jobID, WorkerID: "worker-1", Status: "transcoding"}); err != nil {
+			return err
+		}
+	}
+	for jobID, want := range map[string]string{cancelled: "cancelled", failed: "failed", packaged: "done", ready: "ready_for_mpd"} {
+		got, err := s.GetJob(jobID)
+		if err != nil {
+			return err
+		}
+		if got.Status != want {
+			return fmt.Errorf("job %s is %s, want %s", jobID, got.Status, want)
+		}
+	}
+
+	if err := s.SafeUpdateJobMetadata(jobstore.Job{JobID: failed, Status: "waiting"}); err != nil {
+		return err
+	}
+	if got, err := s.GetJob(failed); err != nil || got.Status != "waiting" {
+		return fmt.Errorf("retried job not reopened: %+v, %v", got, err)
+	}
+	return nil
+}
+
+func checkListJobs(s jobstore.Store, prefix string) error {
+	const n = 7
+	for i := 0; i < n; i++ {
+		codec := "h264"
+		if i%2 == 1 {
+			codec = "vp9"
+		}
+		job := jobstore.Job{JobID: fmt.Sprintf("%sjob-%d", prefix, i), StreamName: fmt.Sprintf("%s%d", prefix, i), Codec: codec, Status: "waiting"}
+		if err := s.InsertJob(job); err != nil {
+			return err
+		}
+	}
+
+	for _, sortBy := range []string{"created_at", "updated_at", "stream_name"} {
+		for _, desc := range []bool{false, true} {
+			q := jobstore.ListQuery{StreamNamePrefix: prefix, SortBy: sortBy, Descending: desc, Limit: 3}
+			seen := map[string]bool{}
+			for pages := 0; pages < n; pages++ {
+				page, err := s.ListJobs(q)
+				if err != nil {
+					return err
+				}
+				if page.Total != n {
+					return fmt.Errorf("sort=%s desc=%v: total %d, want %d", sortBy, desc, page.Total, n)
+				}
+				for _, job := range page.Jobs {
+					if seen[job.JobID] {
+						return fmt.Errorf("sort=%s desc=%v: job %s returned twice", sortBy, desc, job.JobID)
+					}
+					seen[job.JobID] = true
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				cursor, err := jobstore.DecodeCursor(page.NextCursor)
+				if err != nil {
+					return err
+				}
+				q.After = &cursor
+			}
+			if len(seen) != n {
+				return fmt.Errorf("sort=%s desc=%v: paged through %d jobs, want %d", sortBy, desc, len(seen), n)
+			}
+		}
+	}
+
+	page, err := s.ListJobs(jobstore.ListQuery{StreamNamePrefix: prefix, Codec: "vp9", Statuses: []string{"waiting", "done"}, Limit: 50})
+	if err != nil {
+		return err
+	}
+	if page.Total != n/2 || len(page.Jobs) != n/2 {
+		return fmt.Errorf("codec filter: total %d, %d jobs, want %d", page.Total, len(page.Jobs), n/2)
+	}
+
+	page, err = s.ListJobs(jobstore.ListQuery{StreamNamePrefix: prefix, CreatedAfter: time.Now().Add(time.Hour), Limit: 50})
+	if err != nil {
+		return err
+	}
+	if page.Total != 0 {
+		return fmt.Errorf("created_after in the future matched %d jobs", page.Total)
+	}
+	page, err = s.ListJobs(jobstore.ListQuery{StreamNamePrefix: prefix, CreatedAfter: time.Now().Add(-time.Hour), CreatedBefore: time.Now().Add(time.Hour), Limit: 50})
+	if err != nil {
+		return err
+	}
+	if page.Total != n {
+		return fmt.Errorf("created window matched %d jobs, want %d", page.Total, n)
+	}
+
+	// Wildcards in the prefix must match literally
+	for i, name := range []string{"w%_*?[x", "wabcdex"} {
+		if err := s.InsertJob(jobstore.Job{JobID: fmt.Sprintf("%swild-%d", prefix, i), StreamName: prefix + name, Status: "waiting"}); err != nil {
+			return err
+		}
+	}
+	page, err = s.ListJobs(jobstore.ListQuery{StreamNamePrefix: prefix + "w%_*?[", Limit: 50})
+	if err != nil {
+		return err
+	}
+	if page.Total != 1 {
+		return fmt.Errorf("wildcard prefix matched %d jobs, want 1", page.Total)
+	}
+	return nil
+}
+
+func checkRepresentations(s jobstore.Store, prefix string) error {
+	jobID := prefix + "job"
+	started := time.Now().UTC().Truncate(time.Second)
+	queued := jobstore.Representation{JobID: jobID, Representation: "720p", Resolution: "1280x720", Bitrate: "2500k", Codec: "h264", Status: "queued"}
+	if err := s.UpsertRepresentation(queued); err != nil {
+		return err
+	}
+	if err := s.UpsertRepresentation(jobstore.Representation{JobID: jobID, Representation: "1080p", Status: "queued"}); err != nil {
+		return err
+	}
+
+	done := queued
+	done.Status = "done"
+	done.WorkerID = "worker-1"
+	done.Attempt = 2
+	done.StartedAt = &started
+	finished := started.Add(90 * time.Second)
+	done.FinishedAt = &finished
+	done.OutputPath = "/segments/out.mp4"
+	done.OutputSize = 5 << 30
+	done.Quality = &quality.Report{
+		PSNR: &quality.Score{Mean: 42.125, Min: 38.5, P5: 39.75},
+		SSIM: &quality.Score{Mean: 0.9812, Min: 0.95, P5: 0.9601},
+	}
+	if err := s.UpsertRepresentation(done); err != nil {
+		return err
+	}
+
+	reps, err := s.GetRepresentations(jobID)
+	if err != nil {
+		return err
+	}
+	if len(reps) != 2 || reps[0].Representation != "1080p" || reps[1].Representation != "720p" {
+		return fmt.Errorf("got %+v, want 1080p and 720p", reps)
+	}
+	got := reps[1]
+	if got.Status != "done" || got.WorkerID != "worker-1" || got.Attempt != 2 || got.OutputSize != done.OutputSize || got.Resolution != "1280x720" {
+		return fmt.Errorf("got %+v", got)
+	}
+	if got.StartedAt == nil || !got.StartedAt.Equal(started) || got.FinishedAt == nil || !got.FinishedAt.Equal(finished) {
+		return fmt.Errorf("timestamps %v/%v, want %v/%v", got.StartedAt, got.FinishedAt, started, finished)
+	}
+	if q := got.Quality; q == nil || q.VMAF != nil || q.PSNR == nil || *q.PSNR != *done.Quality.PSNR || q.SSIM == nil || *q.SSIM != *done.Quality.SSIM {
+		return fmt.Errorf("quality %+v, want %+v", got.Quality, done.Quality)
+	}
+	if reps[0].StartedAt != nil || reps[0].Quality != nil {
+		return fmt.Errorf("queued representation has started_at %v, quality %+v", reps[0].StartedAt, reps[0].Quality)
+	}
+
+	if reps, err = s.GetRepresentations(prefix + "missing"); err != nil || len(reps) != 0 {
+		return fmt.Errorf("unknown job returned %v, %v", reps, err)
+	}
+	return nil
+}
+
+func checkEvents(s jobstore.Store, prefix string) error {
+	jobID := prefix + "job"
+	base := time.Now().UTC().Truncate(time.Second)
+	// Appended out of order: history is ordered by timestamp, then by insertion
+	events := []jobstore.JobEvent{
+		{Timestamp: base.Add(2 * time.Second), JobID: jobID, Representation: "720p", Service: "transcode-worker", FromState: "processing", ToState: "done", WorkerID: "worker-1"},
+		{Timestamp: base, JobID: jobID, Service: "transcoding-controller", ToState: "waiting"},
+		{Timestamp: base.Add(time.Second), JobID: jobID, Representation: "720p", Service: "transcode-worker", FromState: "queued", ToState: "processing", WorkerID: "worker-1"},
+		{Timestamp: base.Add(2 * time.Second), JobID: jobID, Service: "tracker", FromState: "processing", ToState: "ready_for_mpd", Message: "all representations done"},
+	}
+	for _, e := range events {
+		if err := s.AppendEvent(e); err != nil {
+			return err
+		}
+	}
+
+	got, err := s.GetJobEvents(jobID)
+	if err != nil {
+		return err
+	}
+	want := []string{"waiting", "processing", "done", "ready_for_mpd"}
+	if len(got) != len(want) {
+		return fmt.Errorf("got %d events, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		if e.ToState != want[i] {
+			return fmt.Errorf("event %d is %s, want %s", i, e.ToState, want[i])
+		}
+	}
+	if last := got[3]; last.Service != "tracker" || last.Message != "all representations done" || !last.Timestamp.Equal(base.Add(2*time.Second)) {
+		return fmt.Errorf("got %+v", last)
+	}
+	if got[1].WorkerID != "worker-1" || got[1].Representation != "720p" || got[0].FromState != "" {
+		return fmt.Errorf("got %+v / %+v", got[0], got[1])
+	}
+
+	if got, err = s.GetJobEvents(prefix + "missing"); err != nil || len(got) != 0 {
+		return fmt.Errorf("unknown job returned %v, %v", got, err)
+	}
+	return nil
+}
+
+func checkFailures(s jobstore.Store, prefix string) error {
+	jobID := prefix + "job"
+	if err := s.InsertJob(jobstore.Job{JobID: jobID, StreamName: prefix + "stream", Status: "ready_for_mpd"}); err != nil {
+		return err
+	}
+	if got, err := s.GetJob(jobID); err != nil || got.Failure != nil {
+		return fmt.Errorf("new job has failure %+v, %v", got, err)
+	}
+
+	want := failure.Failure{Stage: failure.StagePackage, Reason: failure.ReasonMissingSegments, ExitCode: 1, Message: "missing 720p", StderrTail: "line 1\nline 2"}
+	if err := s.MarkJobFailed(jobID, want); err != nil {
+		return err
+	}
+	got, err := s.GetJob(jobID)
+	if err != nil {
+		return err
+	}
+	if got.Status != "failed" || got.Failure == nil || *got.Failure != want {
+		return fmt.Errorf("got %+v / %+v, want %+v", *got, got.Failure, want)
+	}
+	page, err := s.ListJobs(jobstore.ListQuery{StreamNamePrefix: prefix, Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(page.Jobs) != 1 || page.Jobs[0].Failure == nil || *page.Jobs[0].Failure != want {
+		return fmt.Errorf("failure missing from ListJobs: %+v", page.Jobs)
+	}
+
+	repFailure := failure.Failure{Stage: failure.StageTranscode, Reason: failure.ReasonCorruptInput, ExitCode: 183, Message: "moov atom not found"}
+	rep := jobstore.Representation{JobID: jobID, Representation: "720p", Status: "failed", Attempt: 1, Failure: &repFailure}
+	if err := s.UpsertRepresentation(rep); err != nil {
+		return err
+	}
+	reps, err := s.GetRepresentations(jobID)
+	if err != nil {
+		return err
+	}
+	if len(reps) != 1 || reps[0].Failure == nil || *reps[0].Failure != repFailure {
+		return fmt.Errorf("got %+v, want failure %+v", reps, repFailure)
+	}
+
+	// A successful retry clears the earlier failure
+	rep.Status, rep.Attempt, rep.Failure = "done", 2, nil
+	if err := s.UpsertRepresentation(rep); err != nil {
+		return err
+	}
+	if reps, err = s.GetRepresentations(jobID); err != nil || reps[0].Failure != nil {
+		return fmt.Errorf("failure not cleared: %+v, %v", reps, err)
+	}
+	return nil
+}
+
+func checkBatches(s jobstore.Store, prefix string) error {
+	batchID := prefix + "batch"
+	jobs := []jobstore.Job{
+		{JobID: prefix + "a", StreamName: prefix + "a", Codec: "h264", Representations: "720p"},
+		{JobID: prefix + "b", StreamName: prefix + "b", Codec: "h264", Representations: "720p"},
+	}
+	if err := s.InsertBatch(jobstore.Batch{BatchID: batchID, TenantID: "tenant", WebhookURL: "https://example.com/hook"}, jobs); err != nil {
+		return err
+	}
+
+	batch, err := s.GetBatch(batchID)
+	if err != nil {
+		return err
+	}
+	if batch == nil || batch.Status != "running" || batch.JobCount != 2 || batch.WebhookURL != "https://example.com/hook" {
+		return fmt.Errorf("got batch %+v", batch)
+	}
+	if missing, err := s.GetBatch(prefix + "missing"); err != nil || missing != nil {
+		return fmt.Errorf("GetBatch of unknown id returned %+v, %v", missing, err)
+	}
+
+	unnotified, err := s.GetUnnotifiedBatches()
+	if err != nil {
+		return err
+	}
+	if !containsBatch(unnotified, batchID) {
+		return fmt.Errorf("running batch missing from unnotified batches")
+	}
+
+	if err := s.UpdateJobStatus(prefix+"a", "done"); err != nil {
+		return err
+	}
+	cancelled, err := s.CancelBatch(batchID)
+	if err != nil {
+		return err
+	}
+	if len(cancelled) != 1 || cancelled[0] != prefix+"b" {
+		return fmt.Errorf("cancelled %v, want only the unfinished job", cancelled)
+	}
+
+	batchJobs, err := s.GetBatchJobs(batchID)
+	if err != nil {
+		return err
+	}
+	statuses := map[string]string{}
+	for _, job := range batchJobs {
+		statuses[job.JobID] = job.Status
+	}
+	if len(batchJobs) != 2 || statuses[prefix+"a"] != "done" || statuses[prefix+"b"] != "cancelled" {
+		return fmt.Errorf("batch job statuses %v", statuses)
+	}
+
+	if err := s.MarkBatchFinished(batchID, "cancelled"); err != nil {
+		return err
+	}
+	if batch, err = s.GetBatch(batchID); err != nil || batch.CompletedAt == "" {
+		return fmt.Errorf("completed_at not set: %+v, %v", batch, err)
+	}
+	if unnotified, err = s.GetUnnotifiedBatches(); err != nil || containsBatch(unnotified, batchID) {
+		return fmt.Errorf("finished batch still unnotified (err=%v)", err)
+	}
+	return nil
+}
+
+func containsBatch(batches []jobstore.Batch, batchID string) bool {
+	for _, b := range batches {
+		if b.BatchID == batchID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package storetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"common/jobstore"
+)
+
+func TestSQLite(t *testing.T) {
+	runChecks(t, func() (jobstore.Store, error) {
+		return jobstore.NewSQLite(filepath.Join(t.TempDir(), "jobs.db"))
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	runChecks(t, func() (jobstore.Store, error) {
+		return jobstore.NewPostgres(dsn)
+	})
+}
+
+// runChecks migrates the store open returns and runs every check against it as a subtest.
+func runChecks(t *testing.T, open func() (jobstore.Store, error)) {
+	s, err := open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.fn(s, newPrefix()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
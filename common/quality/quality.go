@@ -0,0 +1,71 @@
+// Package quality holds the objective quality scores a worker measures on a rendition against
+// its source, which the tracker copies from the job hash into the representation record.
+package quality
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// Score summarizes one metric over every frame of a rendition.
+type Score struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P5   float64 `json:"p5"` // 5th percentile: the worst frames, ignoring the few outliers
+}
+
+// Report holds the scores of one rendition. VMAF is measured when FFmpeg has libvmaf, PSNR (dB)
+// and SSIM otherwise.
+type Report struct {
+	VMAF *Score `json:"vmaf,omitempty"`
+	PSNR *Score `json:"psnr,omitempty"`
+	SSIM *Score `json:"ssim,omitempty"`
+}
+
+// Summarize computes the score of per-frame values, or returns nil if there are none.
+func Summarize(values []float64) *Score {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	// Nearest-rank percentile
+	rank := int(math.Ceil(0.05*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return &Score{
+		Mean: round(sum / float64(len(sorted))),
+		Min:  round(sorted[0]),
+		P5:   round(sorted[rank]),
+	}
+}
+
+// round keeps four decimals, more than any of the metrics is precise to.
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
+
+// Encode serializes r for storage in a Redis hash field.
+func (r Report) Encode() string {
+	payload, _ := json.Marshal(r)
+	return string(payload)
+}
+
+// Decode parses a value written by Encode, returning nil if it is empty or malformed.
+func Decode(s string) *Report {
+	if s == "" {
+		return nil
+	}
+	var r Report
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		return nil
+	}
+	return &r
+}
@@ -0,0 +1,48 @@
+// Package subtitle describes the subtitle tracks a worker converts for a job, which the
+// mpd-generator packages along with its renditions.
+package subtitle
+
+import "encoding/json"
+
+// Dir is the directory, next to a job's manifest, that holds its subtitle tracks. Every path in
+// a Set is relative to the job's directory.
+const Dir = "subtitles"
+
+// Sources of a track.
+const (
+	SourceSidecar  = "sidecar"
+	SourceEmbedded = "embedded"
+)
+
+// Track is one converted subtitle track.
+type Track struct {
+	Path      string `json:"path"`     // e.g. subtitles/0_en.vtt
+	Language  string `json:"language"` // BCP 47 for sidecars, the stream's tag (ISO 639-2) for embedded tracks
+	Label     string `json:"label,omitempty"`
+	Source    string `json:"source"`    // sidecar or embedded
+	Bandwidth int    `json:"bandwidth"` // bits per second over the input's duration
+}
+
+// Set is every track of one job, in one format.
+type Set struct {
+	Format string  `json:"format"` // webvtt, wvtt or stpp
+	Tracks []Track `json:"tracks"`
+}
+
+// Encode serializes s for storage in a Redis hash field.
+func (s Set) Encode() string {
+	payload, _ := json.Marshal(s)
+	return string(payload)
+}
+
+// Decode parses a value written by Encode, returning nil if it is empty or malformed.
+func Decode(v string) *Set {
+	if v == "" {
+		return nil
+	}
+	var s Set
+	if err := json.Unmarshal([]byte(v), &s); err != nil {
+		return nil
+	}
+	return &s
+}
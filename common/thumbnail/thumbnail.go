@@ -0,0 +1,83 @@
+// Package thumbnail describes the poster, sprite sheets and WebVTT thumbnail track a worker
+// generates for a job, which the mpd-generator publishes along with its manifest.
+package thumbnail
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+)
+
+// Dir is the directory, next to a job's manifest, that holds its poster and thumbnails. Every
+// path in a Set is relative to the job's directory.
+const Dir = "thumbnails"
+
+// Set is what was generated for one job.
+type Set struct {
+	Poster   string  `json:"poster"`   // e.g. thumbnails/poster.jpg
+	Track    string  `json:"track"`    // WebVTT track pointing into the sprite sheets
+	Sprites  string  `json:"sprites"`  // sprite sheet path with $Number$ for the sheet, counted from 1
+	Sheets   int     `json:"sheets"`   // number of sprite sheets
+	Count    int     `json:"count"`    // number of thumbnails
+	Interval float64 `json:"interval"` // seconds between thumbnails
+	Width    int     `json:"width"`    // of one thumbnail
+	Height   int     `json:"height"`   // of one thumbnail
+	Columns  int     `json:"columns"`
+	Rows     int     `json:"rows"`
+}
+
+// SheetPath returns the path of sprite sheet n, counted from 1.
+func (s Set) SheetPath(n int) string {
+	return strings.ReplaceAll(s.Sprites, "$Number$", fmt.Sprint(n))
+}
+
+// SheetSeconds is how much of the timeline one full sprite sheet covers.
+func (s Set) SheetSeconds() float64 {
+	return float64(s.Columns*s.Rows) * s.Interval
+}
+
+// WebVTT writes the thumbnail track of an input of duration seconds: one cue per thumbnail,
+// pointing at its tile with a media fragment. The track sits next to the sprite sheets.
+func (s Set) WebVTT(duration float64) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	perSheet := s.Columns * s.Rows
+	for i := 0; i < s.Count; i++ {
+		start := float64(i) * s.Interval
+		if start >= duration {
+			break
+		}
+		end := math.Min(start+s.Interval, duration)
+		tile := i % perSheet
+		sheet := path.Base(s.SheetPath(i/perSheet + 1))
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", timestamp(start), timestamp(end),
+			sheet, tile%s.Columns*s.Width, tile/s.Columns*s.Height, s.Width, s.Height)
+	}
+	return b.String()
+}
+
+// timestamp formats seconds as a WebVTT hh:mm:ss.ttt timestamp.
+func timestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Encode serializes s for storage in a Redis hash field.
+func (s Set) Encode() string {
+	payload, _ := json.Marshal(s)
+	return string(payload)
+}
+
+// Decode parses a value written by Encode, returning nil if it is empty or malformed.
+func Decode(v string) *Set {
+	if v == "" {
+		return nil
+	}
+	var s Set
+	if err := json.Unmarshal([]byte(v), &s); err != nil {
+		return nil
+	}
+	return &s
+}
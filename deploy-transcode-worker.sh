@@ -4,6 +4,11 @@ set -e
 
 echo "🚀 Preparing to build and deploy transcode-worker..."
 
+if [ ! -f common/go.mod ]; then
+  echo "🧩 Initializing shared common module..."
+  (cd common && go mod init common && go mod tidy)
+fi
+
 cd transcode-worker
 
 # Initialize go.mod if not present
@@ -11,12 +16,7 @@ if [ ! -f go.mod ]; then
   echo "📦 go.mod not found. Initializing Go module..."
   go mod init transcode-worker
 fi
-
-# Ensure Kafka dependency is present in go.mod
-if ! grep -q "github.com/confluentinc/confluent-kafka-go" go.mod 2>/dev/null; then
-  echo "📦 Adding Kafka dependency..."
-  go get github.com/confluentinc/confluent-kafka-go/kafka
-fi
+go mod edit -replace common=../common
 
 # Ensure go.sum exists and is synced
 echo "📦 Tidying Go modules..."
@@ -25,7 +25,7 @@ go mod tidy
 cd ..
 
 echo "🐳 Building transcode-worker Docker image..."
-docker build -t transcode-worker:latest -f transcode-worker/Dockerfile ./transcode-worker
+docker build -t transcode-worker:latest -f transcode-worker/Dockerfile .
 
 echo "✅ Docker image built!"
 
@@ -5,11 +5,17 @@ set -e
 echo "📁 Navigating to project root..."
 cd "$(dirname "$0")"
 
-# Step 1: Initialize Go module if missing
+# Step 1: Initialize Go modules if missing
+if [ ! -f "./common/go.mod" ]; then
+  echo "🧩 Initializing shared common module..."
+  (cd ./common && go mod init common && go mod tidy)
+fi
+
 if [ ! -f "./transcoding-controller/go.mod" ]; then
   echo "🧩 go.mod not found. Initializing Go module..."
   cd ./transcoding-controller
   go mod init transcoding-controller
+  go mod edit -replace common=../common
   go mod tidy
   cd ..
 else
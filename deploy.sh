@@ -22,6 +22,11 @@ init_go_mod() {
     pushd "$service_dir" > /dev/null
   fi
 
+  # Services resolve the shared module from the repo instead of a registry
+  if [ "$module_name" != "common" ]; then
+    go mod edit -replace common=../common
+  fi
+
   go mod tidy
 
   for dep in "${dependencies[@]}"; do
@@ -34,10 +39,11 @@ init_go_mod() {
 }
 
 # Step 1: Initialize Go modules and install dependencies
+init_go_mod "./common" "common"
 init_go_mod "./transcoding-controller" "transcoding-controller"
 init_go_mod "./transcode-worker" "transcode-worker"
-init_go_mod "./tracker" "tracker" "github.com/mattn/go-sqlite3"
-init_go_mod "./mpd-generator" "mpd-generator" "github.com/mattn/go-sqlite3"
+init_go_mod "./tracker" "tracker"
+init_go_mod "./mpd-generator" "mpd-generator"
 
 # Step 2: Build and start services with Docker Compose
 echo "🏗️  Building and starting Docker Compose services..."
@@ -48,20 +54,20 @@ echo "⏳ Waiting for Kafka to be ready..."
 sleep 10  # Adjust delay as needed for your environment
 
 # Step 4: Create required Kafka topics
-echo "🌀 Creating Kafka topic: mpd-generation"
-docker exec -i kafka kafka-topics.sh \
-  --create \
-  --if-not-exists \
-  --topic mpd-generation \
-  --bootstrap-server localhost:9092 \
-  --partitions 1 \
-  --replication-factor 1 2>/dev/null
-
-if [ $? -eq 0 ]; then
-  echo "✅ Kafka topic 'mpd-generation' created or already exists."
-else
-  echo "⚠️ Kafka topic 'mpd-generation' may already exist or creation failed."
-fi
+for topic in mpd-generation transcode-status transcode-jobs-high transcode-jobs transcode-jobs-low transcode-ladder-analysis transcode-ladders transcode-split transcode-chunk-plans transcode-thumbnails transcode-subtitles transcode-edits transcode-edit-results; do
+  echo "🌀 Creating Kafka topic: $topic"
+  if docker exec -i kafka kafka-topics.sh \
+    --create \
+    --if-not-exists \
+    --topic "$topic" \
+    --bootstrap-server localhost:9092 \
+    --partitions 1 \
+    --replication-factor 1 2>/dev/null; then
+    echo "✅ Kafka topic '$topic' created or already exists."
+  else
+    echo "⚠️ Kafka topic '$topic' may already exist or creation failed."
+  fi
+done
 
 echo "✅ Deployment complete."
 echo "🌐 Access Transcoding Controller: http://13.57.143.121:8080/transcode"
@@ -0,0 +1,40 @@
+#!/bin/bash
+# Runs the whole pipeline in one process (`transcoder dev`): no Kafka, Redis, Docker or nginx needed,
+# only Go, ffmpeg/ffprobe and MP4Box. Extra arguments are passed through, e.g. ./dev.sh -workers 4
+# `./dev.sh e2e` runs the end-to-end scenarios instead (`go test` in transcoder), with fake ffmpeg/ffprobe/MP4Box.
+
+set -e
+cd "$(dirname "$0")"
+
+services=(transcoding-controller transcode-worker tracker mpd-generator)
+
+# Same module layout deploy.sh creates, plus the transcoder module that imports every service
+for mod in common "${services[@]}" transcoder; do
+  if [ ! -f "$mod/go.mod" ]; then
+    echo "🧩 Initializing Go module in $mod..."
+    (cd "$mod" && go mod init "$mod")
+  fi
+done
+
+for svc in "${services[@]}"; do
+  (cd "$svc" && go mod edit -replace common=../common)
+done
+(
+  cd transcoder
+  go mod edit -replace common=../common
+  for svc in "${services[@]}"; do
+    go mod edit -replace "$svc=../$svc"
+  done
+)
+
+for mod in common "${services[@]}" transcoder; do
+  echo "📦 Tidying $mod..."
+  (cd "$mod" && go mod tidy)
+done
+
+cd transcoder
+if [ "$1" = "e2e" ]; then
+  shift
+  exec go test -count=1 -v -run TestE2E . -args "$@"
+fi
+exec go run . dev "$@"
@@ -0,0 +1,58 @@
+package mpdgen
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"common/failure"
+	"common/jobstore"
+)
+
+var store jobstore.Store
+
+// InitDB opens the job store selected by DB_DRIVER.
+func InitDB() {
+	var err error
+	store, err = jobstore.OpenFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to open DB: %v", err)
+	}
+
+	// Schema is owned by the tracker; wait until it has been migrated to the version we expect
+	if err = store.WaitForSchema(2 * time.Minute); err != nil {
+		log.Fatalf("❌ DB schema not ready: %v", err)
+	}
+
+	log.Println("📁 DB initialized (mpd-generator).")
+}
+
+// UpdateMPDUrl updates only the mpd_url field for a given job_id.
+func UpdateMPDUrl(jobID, mpdURL string) error {
+	if err := store.UpdateMPDURL(jobID, mpdURL); err != nil {
+		return fmt.Errorf("❌ Failed to update MPD URL for job %s: %w", jobID, err)
+	}
+
+	log.Printf("✅ Updated MPD URL for job %s", jobID)
+	return nil
+}
+
+// UpdateThumbnailURLs records where the job's poster and thumbnail track are published.
+func UpdateThumbnailURLs(jobID, posterURL, thumbnailsURL string) error {
+	if err := store.UpdateThumbnailURLs(jobID, posterURL, thumbnailsURL); err != nil {
+		return fmt.Errorf("❌ Failed to update thumbnail URLs for job %s: %w", jobID, err)
+	}
+
+	log.Printf("✅ Updated thumbnail URLs for job %s", jobID)
+	return nil
+}
+
+// MarkJobFailed records a packaging failure as the job's terminal state.
+func MarkJobFailed(jobID string, f failure.Failure) error {
+	if err := store.MarkJobFailed(jobID, f); err != nil {
+		return fmt.Errorf("❌ Failed to mark job %s failed: %w", jobID, err)
+	}
+
+	log.Printf("🛑 Job %s failed at %s: %s", jobID, f.Stage, f.Reason)
+	return nil
+}
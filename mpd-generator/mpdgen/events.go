@@ -0,0 +1,30 @@
+package mpdgen
+
+import (
+	"log"
+	"time"
+
+	"common/bus"
+	"common/contracts"
+)
+
+// msgBus delivers MPD requests and carries status events; the tracker appends those to the job's history.
+var msgBus bus.Bus
+
+func publishStatusEvent(jobID, from, to, message string) {
+	payload, err := contracts.Encode(&contracts.StatusEvent{
+		JobID:     jobID,
+		Service:   "mpd-generator",
+		FromState: from,
+		ToState:   to,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to encode status event for job %s: %v", jobID, err)
+		return
+	}
+	if err := msgBus.Publish(ctx, contracts.TopicStatus, []byte(jobID), payload); err != nil {
+		log.Printf("⚠️ Failed to publish status event for job %s: %v", jobID, err)
+	}
+}
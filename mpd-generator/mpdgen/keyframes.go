@@ -0,0 +1,96 @@
+package mpdgen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Keyframe alignment modes, set with KEYFRAME_ALIGNMENT.
+const (
+	AlignmentFail = "fail" // misaligned jobs fail instead of being packaged (default)
+	AlignmentWarn = "warn" // misaligned jobs are packaged, with the mismatch in their history
+	AlignmentOff  = "off"  // keyframes are not checked
+)
+
+// keyframeTolerance is how far apart, in seconds, two renditions' keyframes may be and still
+// count as aligned: well under a frame at 120 fps, and above the timestamp rounding of any
+// timescale an encoder uses.
+const keyframeTolerance = 0.004
+
+// rendition is one encoded representation to be packaged.
+type rendition struct {
+	Name string
+	Path string
+}
+
+// checkKeyframeAlignment reads the keyframe times of every rendition and compares them with the
+// first one's. It returns a description of the first keyframe that does not line up, or "" if
+// a player can switch between the renditions at every keyframe.
+func checkKeyframeAlignment(renditions []rendition) (string, error) {
+	var reference []float64
+	for i, r := range renditions {
+		keyframes, err := probeKeyframes(r.Path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", r.Name, err)
+		}
+		if i == 0 {
+			reference = keyframes
+			continue
+		}
+		if msg := compareKeyframes(renditions[0].Name, reference, r.Name, keyframes); msg != "" {
+			return msg, nil
+		}
+	}
+	return "", nil
+}
+
+// compareKeyframes describes the first keyframe of a or b that the other lacks.
+func compareKeyframes(nameA string, a []float64, nameB string, b []float64) string {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if math.Abs(a[i]-b[i]) > keyframeTolerance {
+			return fmt.Sprintf("keyframe %d is at %.3fs in %s but %.3fs in %s", i+1, a[i], nameA, b[i], nameB)
+		}
+	}
+	switch {
+	case len(a) > len(b):
+		return fmt.Sprintf("%s has a keyframe at %.3fs that %s lacks", nameA, a[len(b)], nameB)
+	case len(b) > len(a):
+		return fmt.Sprintf("%s has a keyframe at %.3fs that %s lacks", nameB, b[len(a)], nameA)
+	}
+	return ""
+}
+
+// probeKeyframes returns the presentation times of the keyframes of a file's first video stream,
+// read from the packet flags without decoding anything.
+func probeKeyframes(path string) ([]float64, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var times []float64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		pts, flags, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ",")
+		if !strings.HasPrefix(flags, "K") {
+			continue
+		}
+		t, err := strconv.ParseFloat(pts, 64)
+		if err != nil {
+			continue // N/A
+		}
+		times = append(times, t)
+	}
+	return times, scanner.Err()
+}
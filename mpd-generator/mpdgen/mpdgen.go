@@ -0,0 +1,261 @@
+package mpdgen
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"common/bus"
+	"common/contracts"
+	"common/failure"
+	"common/jobhash"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ctx         = context.Background()
+	segmentsDir = "/segments"
+	publicHost  string
+	alignment   = AlignmentFail
+
+	redisClient *redis.Client
+	jobHashes   *jobhash.Store
+)
+
+// Init connects to Redis and the job store, reads PUBLIC_HOST, SEGMENTS_DIR (default /segments)
+// and KEYFRAME_ALIGNMENT (fail, warn or off; default fail), and consumes MPD requests and
+// publishes status events on b.
+func Init(b bus.Bus) {
+	msgBus = b
+	publicHost = os.Getenv("PUBLIC_HOST")
+	if dir := os.Getenv("SEGMENTS_DIR"); dir != "" {
+		segmentsDir = dir
+	}
+	switch mode := os.Getenv("KEYFRAME_ALIGNMENT"); mode {
+	case "":
+	case AlignmentFail, AlignmentWarn, AlignmentOff:
+		alignment = mode
+	default:
+		log.Fatalf("❌ Invalid KEYFRAME_ALIGNMENT %q (use fail, warn or off)", mode)
+	}
+
+	InitDB() // Only for updating mpd_url and the thumbnail URLs
+
+	redisClient = redis.NewClient(&redis.Options{
+		Addr: os.Getenv("REDIS_ADDR"),
+	})
+	if _, err := redisClient.Ping(ctx).Result(); err != nil {
+		log.Fatalf("❌ Failed to connect to Redis: %v", err)
+	}
+	jobHashes = jobhash.New(redisClient)
+	log.Println("✅ Connected to Redis")
+}
+
+// Run packages every job announced on the mpd-generation topic until ctx is cancelled.
+func Run(ctx context.Context) error {
+	return msgBus.Subscribe(ctx, contracts.TopicMPDGeneration, "mpd-generator", handleMPDRequest)
+}
+
+func handleMPDRequest(m bus.Message) {
+	var msg contracts.MPDRequest
+	if err := contracts.Decode(m.Value, &msg); err != nil {
+		log.Printf("❌ Rejected MPD request (%v): %s", err, string(m.Value))
+		return
+	}
+
+	generateMPD(msg.JobID)
+}
+
+func generateMPD(jobID string) {
+	jobDir := filepath.Join(segmentsDir, jobID)
+	localMPDPath := filepath.Join(jobDir, "manifest.mpd")
+	publicMPDURL := fmt.Sprintf("%s/%s/manifest.mpd", strings.TrimRight(publicHost, "/"), jobID)
+
+	os.MkdirAll(jobDir, 0755)
+
+	job, err := jobHashes.Get(ctx, jobID)
+	if err == nil && job == nil {
+		err = fmt.Errorf("job hash %s not found", jobHashes.Key(jobID))
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read job %s from Redis: %v", jobID, err)
+		failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonUnknown, fmt.Errorf("read job: %w", err)))
+		return
+	}
+	if job.Codec == "" || len(job.RequiredResolutions) == 0 {
+		log.Printf("❌ Job %s has no codec or required_resolutions in Redis", jobID)
+		failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonUnknown, fmt.Errorf("job %s is missing codec or required_resolutions", jobID)))
+		return
+	}
+	codec := strings.ToLower(job.Codec)
+	requiredReps := job.RequiredResolutions
+
+	args := []string{
+		"-dash", strconv.Itoa(contracts.SegmentSeconds * 1000),
+		"-rap", "-frag-rap",
+		"-out", localMPDPath,
+	}
+
+	if codec == "h264" || codec == "avc" {
+		args = append([]string{"-profile", "dashavc264:live"}, args...)
+	}
+
+	var renditions []rendition
+	for _, rep := range requiredReps {
+		file := filepath.Join(segmentsDir, fmt.Sprintf("%s_%s.mp4", jobID, rep))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			log.Printf("⚠️ Missing segment file: %s", file)
+			failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonMissingSegments, fmt.Errorf("missing segment file %s", file)))
+			return
+		}
+		renditions = append(renditions, rendition{Name: rep, Path: file})
+		args = append(args, file)
+	}
+
+	if !verifyKeyframes(jobID, renditions) {
+		return
+	}
+
+	if job.SubtitleSet != nil && !sideLoaded(job.SubtitleSet) {
+		inputs, err := subtitleInputs(jobDir, *job.SubtitleSet)
+		if err != nil {
+			log.Printf("⚠️ Missing subtitle track for job %s: %v", jobID, err)
+			failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonMissingSegments, err))
+			return
+		}
+		args = append(args, inputs...)
+	}
+
+	cmd := exec.Command("MP4Box", args...)
+	log.Printf("📦 Running MP4Box: %s", strings.Join(cmd.Args, " "))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		f := failure.FromCommand(failure.StagePackage, err, output)
+		log.Printf("❌ MP4Box failed (%s, exit %d):\n%s", f.Reason, f.ExitCode, f.StderrTail)
+		failPackaging(jobID, f)
+		return
+	}
+
+	if job.ThumbnailSet != nil {
+		if err := addThumbnails(localMPDPath, jobDir, *job.ThumbnailSet); err != nil {
+			log.Printf("❌ Failed to add thumbnails to the manifest of job %s: %v", jobID, err)
+			failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonMissingSegments, fmt.Errorf("thumbnails: %w", err)))
+			return
+		}
+		publicJobURL := fmt.Sprintf("%s/%s/", strings.TrimRight(publicHost, "/"), jobID)
+		err := UpdateThumbnailURLs(jobID, publicJobURL+job.ThumbnailSet.Poster, publicJobURL+job.ThumbnailSet.Track)
+		if err != nil {
+			log.Printf("⚠️ Failed to update thumbnail URLs in DB for job %s: %v", jobID, err)
+		}
+	}
+
+	if sideLoaded(job.SubtitleSet) {
+		if err := addSubtitles(localMPDPath, jobDir, *job.SubtitleSet); err != nil {
+			log.Printf("❌ Failed to add subtitles to the manifest of job %s: %v", jobID, err)
+			failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonMissingSegments, fmt.Errorf("subtitles: %w", err)))
+			return
+		}
+	}
+
+	log.Printf("✅ MPD generated: %s", localMPDPath)
+
+	// Update MPD URL in DB
+	if err := UpdateMPDUrl(jobID, publicMPDURL); err != nil {
+		log.Printf("⚠️ Failed to update MPD URL in DB for job %s: %v", jobID, err)
+	} else {
+		log.Printf("✅ MPD URL updated in DB for job %s", jobID)
+	}
+
+	// ✅ Also update Redis status to "done"
+	from, err := jobHashes.Transition(ctx, jobID, jobhash.FieldStatus, "done")
+	if err != nil {
+		log.Printf("⚠️ Failed to update Redis status for job %s: %v", jobID, err)
+	} else {
+		log.Printf("✅ Job %s marked as done in Redis", jobID)
+		publishStatusEvent(jobID, from, "done", "manifest published at "+publicMPDURL)
+	}
+	jobHashes.Finish(ctx, jobID)
+
+	// The rendered edit list is only kept for retries until the job is packaged
+	if file, ok := strings.CutPrefix(job.EditedInput, "file://"); ok {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to remove the edited input of job %s: %v", jobID, err)
+		}
+	}
+}
+
+// verifyKeyframes checks that the renditions can be switched between at every keyframe, and
+// reports whether the job may be packaged. A misaligned job fails, or with KEYFRAME_ALIGNMENT=warn
+// is packaged with the mismatch recorded in its history.
+func verifyKeyframes(jobID string, renditions []rendition) bool {
+	if alignment == AlignmentOff || len(renditions) < 2 {
+		return true
+	}
+	msg, err := checkKeyframeAlignment(renditions)
+	if err != nil {
+		log.Printf("❌ Failed to read the keyframes of job %s: %v", jobID, err)
+		failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonUnknown, fmt.Errorf("read keyframes: %w", err)))
+		return false
+	}
+	if msg == "" {
+		log.Printf("🔑 Job %s keyframes are aligned across %d renditions", jobID, len(renditions))
+		return true
+	}
+
+	if alignment == AlignmentWarn {
+		log.Printf("⚠️ Job %s keyframes are misaligned, packaging anyway: %s", jobID, msg)
+		publishStatusEvent(jobID, "ready_for_mpd", "ready_for_mpd", "keyframes misaligned: "+msg)
+		return true
+	}
+	log.Printf("❌ Job %s keyframes are misaligned: %s", jobID, msg)
+	failPackaging(jobID, failure.New(failure.StagePackage, failure.ReasonKeyframesMisaligned, errors.New(msg)))
+	return false
+}
+
+// failPackaging moves the job to the terminal failed state instead of leaving it at ready_for_mpd.
+func failPackaging(jobID string, f failure.Failure) {
+	from, err := jobHashes.Transition(ctx, jobID, jobhash.FieldStatus, "failed",
+		jobhash.FieldFailure, f.Encode(),
+		jobhash.FieldCompletedAt, jobhash.Now(),
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to mark job %s failed in Redis: %v", jobID, err)
+	}
+	jobHashes.Finish(ctx, jobID)
+
+	if err := MarkJobFailed(jobID, f); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	publishStatusEvent(jobID, from, "failed", f.Error())
+}
+
+// insertAdaptationSets adds AdaptationSets to the end of the manifest's last Period, after the
+// ones MP4Box wrote.
+func insertAdaptationSets(mpdPath, sets string) error {
+	manifest, err := os.ReadFile(mpdPath)
+	if err != nil {
+		return err
+	}
+	end := strings.LastIndex(string(manifest), "</Period>")
+	if end < 0 {
+		return fmt.Errorf("%s has no Period", mpdPath)
+	}
+	out := string(manifest[:end]) + sets + string(manifest[end:])
+	return os.WriteFile(mpdPath, []byte(out), 0644)
+}
+
+// escapeAttr escapes a value for an XML attribute or element.
+func escapeAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package mpdgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"common/contracts"
+	"common/subtitle"
+)
+
+// subtitleRole marks a text AdaptationSet as subtitles, as opposed to captions or descriptions.
+const subtitleRole = `<Role schemeIdUri="urn:mpeg:dash:role:2011" value="subtitle"/>`
+
+// subtitleInputs returns the MP4Box inputs of the job's wvtt or stpp tracks, which MP4Box
+// segments into fragmented MP4 next to the renditions.
+func subtitleInputs(jobDir string, set subtitle.Set) ([]string, error) {
+	var inputs []string
+	for _, t := range set.Tracks {
+		file := filepath.Join(jobDir, t.Path)
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("subtitle track %s: %w", t.Path, err)
+		}
+		inputs = append(inputs, fmt.Sprintf("%s:lang=%s:role=subtitle", file, t.Language))
+	}
+	return inputs, nil
+}
+
+// addSubtitles adds the job's side-loaded WebVTT tracks to the manifest, one text AdaptationSet
+// per track with the whole file as its only Representation.
+func addSubtitles(mpdPath, jobDir string, set subtitle.Set) error {
+	var sets strings.Builder
+	for i, t := range set.Tracks {
+		if _, err := os.Stat(filepath.Join(jobDir, t.Path)); err != nil {
+			return fmt.Errorf("subtitle track %s: %w", t.Path, err)
+		}
+		fmt.Fprintf(&sets, `<AdaptationSet contentType="text" mimeType="text/vtt" lang="%s">`, escapeAttr(t.Language))
+		if t.Label != "" {
+			fmt.Fprintf(&sets, `<Label>%s</Label>`, escapeAttr(t.Label))
+		}
+		fmt.Fprintf(&sets, `%s<Representation id="subtitles_%d" bandwidth="%d"><BaseURL>%s</BaseURL></Representation></AdaptationSet>`,
+			subtitleRole, i, t.Bandwidth, escapeAttr(t.Path))
+	}
+	return insertAdaptationSets(mpdPath, sets.String())
+}
+
+// sideLoaded reports whether a job's subtitles are added to the manifest after MP4Box, rather than
+// packaged by it.
+func sideLoaded(set *subtitle.Set) bool {
+	return set != nil && set.Format == contracts.SubtitleWebVTT
+}
@@ -0,0 +1,39 @@
+package mpdgen
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"common/thumbnail"
+)
+
+// thumbnailTimescale lets a sprite sheet cover a fractional number of seconds.
+const thumbnailTimescale = 1000
+
+// addThumbnails adds the job's sprite sheets to the manifest as a DASH-IF thumbnail
+// AdaptationSet: one image "segment" per sheet, each tiled Columns by Rows.
+func addThumbnails(mpdPath, jobDir string, set thumbnail.Set) error {
+	largest := int64(0)
+	for n := 1; n <= set.Sheets; n++ {
+		info, err := os.Stat(filepath.Join(jobDir, set.SheetPath(n)))
+		if err != nil {
+			return fmt.Errorf("sprite sheet %d: %w", n, err)
+		}
+		if info.Size() > largest {
+			largest = info.Size()
+		}
+	}
+
+	sheetSeconds := set.SheetSeconds()
+	adaptationSet := fmt.Sprintf(`<AdaptationSet contentType="image" mimeType="image/jpeg">`+
+		`<SegmentTemplate media="%s" timescale="%d" duration="%d" startNumber="1"/>`+
+		`<Representation id="thumbnails" bandwidth="%d" width="%d" height="%d">`+
+		`<EssentialProperty schemeIdUri="http://dashif.org/thumbnail_tile" value="%dx%d"/>`+
+		`</Representation></AdaptationSet>`,
+		set.Sprites, thumbnailTimescale, int64(math.Round(sheetSeconds*thumbnailTimescale)),
+		int64(math.Ceil(float64(largest*8)/sheetSeconds)), set.Columns*set.Width, set.Rows*set.Height,
+		set.Columns, set.Rows)
+	return insertAdaptationSets(mpdPath, adaptationSet)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"common/jobstore"
+)
+
+// runMigrateCommand handles `tracker migrate [up | down <version> | status]` without starting the service.
+func runMigrateCommand(args []string) {
+	s, err := jobstore.OpenFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to open DB: %v", err)
+	}
+	defer s.Close()
+
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		err = s.Migrate()
+	case "down":
+		if len(args) < 2 {
+			log.Fatal("❌ Usage: tracker migrate down <version>")
+		}
+		target, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			log.Fatalf("❌ Invalid target version %q", args[1])
+		}
+		err = s.MigrateDown(target)
+	case "status":
+	default:
+		log.Fatalf("❌ Unknown migrate action %q (want up, down or status)", action)
+	}
+	if err != nil {
+		log.Fatalf("❌ Migration failed: %v", err)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		log.Fatalf("❌ Failed to read schema version: %v", err)
+	}
+	log.Printf("📁 Schema version %d (latest %d)", current, jobstore.LatestVersion())
+}
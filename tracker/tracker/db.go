@@ -0,0 +1,55 @@
+package tracker
+
+import (
+	"fmt"
+	"log"
+
+	"common/jobstore"
+)
+
+var store jobstore.Store
+
+// InitDB opens the job store and migrates it to the latest schema version.
+func InitDB() {
+	var err error
+	store, err = jobstore.OpenFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to open DB: %v", err)
+	}
+
+	// The tracker owns the schema: apply any pending migrations before anything else touches the DB
+	if err = store.Migrate(); err != nil {
+		log.Fatalf("❌ Failed to migrate DB: %v", err)
+	}
+
+	log.Printf("📁 DB initialized at schema version %d.", jobstore.LatestVersion())
+}
+
+// UpdateJobStatus updates only the job status in DB.
+func UpdateJobStatus(jobID, status string) error {
+	if err := store.UpdateJobStatus(jobID, status); err != nil {
+		return fmt.Errorf("❌ Failed to update status for job %s: %w", jobID, err)
+	}
+
+	log.Printf("✅ DB status update: job_id=%s, status=%s", jobID, status)
+	return nil
+}
+
+// SafeUpdateJobMetadata prevents overwriting valid DB metadata with missing Redis values.
+func SafeUpdateJobMetadata(jobID, streamName, inputURL, codec, representations, workerID, status string) error {
+	err := store.SafeUpdateJobMetadata(jobstore.Job{
+		JobID:           jobID,
+		StreamName:      streamName,
+		InputURL:        inputURL,
+		Codec:           codec,
+		Representations: representations,
+		WorkerID:        workerID,
+		Status:          status,
+	})
+	if err != nil {
+		return fmt.Errorf("❌ Failed to insert or update job %s: %w", jobID, err)
+	}
+
+	log.Printf("✅ DB upsert: job_id=%s, status=%s", jobID, status)
+	return nil
+}
@@ -0,0 +1,57 @@
+package tracker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"common/bus"
+	"common/contracts"
+	"common/jobstore"
+)
+
+// statusTopic carries the state transitions published by the controller, workers and mpd-generator.
+const statusTopic = contracts.TopicStatus
+
+// consumeStatusEvents appends every published transition to job_events. The tracker is the only writer.
+func consumeStatusEvents(ctx context.Context) {
+	log.Printf("🎧 Recording job events from topic: %s", statusTopic)
+	err := msgBus.Subscribe(ctx, statusTopic, "tracker", func(m bus.Message) {
+		var ev contracts.StatusEvent
+		if err := contracts.Decode(m.Value, &ev); err != nil {
+			log.Printf("⚠️ Skipping malformed status event (%v): %s", err, string(m.Value))
+			return
+		}
+		appendEvent(jobstore.JobEvent{
+			Timestamp:      ev.Timestamp,
+			JobID:          ev.JobID,
+			Representation: ev.Representation,
+			Service:        ev.Service,
+			FromState:      ev.FromState,
+			ToState:        ev.ToState,
+			WorkerID:       ev.WorkerID,
+			Message:        ev.Message,
+		})
+	})
+	if err != nil {
+		log.Printf("❌ Status event consumer stopped: %v", err)
+	}
+}
+
+// recordTransition logs a job status change made by the tracker itself.
+func recordTransition(jobID, from, to, message string) {
+	appendEvent(jobstore.JobEvent{
+		Timestamp: time.Now(),
+		JobID:     jobID,
+		Service:   "tracker",
+		FromState: from,
+		ToState:   to,
+		Message:   message,
+	})
+}
+
+func appendEvent(e jobstore.JobEvent) {
+	if err := store.AppendEvent(e); err != nil {
+		log.Printf("⚠️ Failed to record event for job %s: %v", e.JobID, err)
+	}
+}
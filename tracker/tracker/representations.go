@@ -0,0 +1,44 @@
+package tracker
+
+import (
+	"log"
+
+	"common/jobhash"
+	"common/jobstore"
+)
+
+// syncRepresentations copies each representation's <rep>_* fields from the job hash into
+// job_representations, so per-rendition history outlives the Redis key.
+func syncRepresentations(job *jobhash.Job) {
+	for _, rep := range job.Representations {
+		record := jobstore.Representation{
+			JobID:          job.ID,
+			Representation: rep.Name,
+			Resolution:     rep.Resolution,
+			Bitrate:        rep.Bitrate,
+			Codec:          job.Codec,
+			Status:         rep.Status,
+			WorkerID:       rep.WorkerID,
+			Attempt:        rep.Attempt,
+			StartedAt:      rep.StartedAt,
+			FinishedAt:     rep.FinishedAt,
+			OutputPath:     rep.OutputPath,
+			OutputSize:     rep.OutputSize,
+			Failure:        rep.Failure,
+			Quality:        rep.Quality,
+		}
+		if err := store.UpsertRepresentation(record); err != nil {
+			log.Printf("⚠️ Failed to sync representation %s for job %s: %v", rep.Name, job.ID, err)
+		}
+	}
+}
+
+// syncJobFailure copies the failure a worker or the mpd-generator recorded in the job hash into the job record.
+func syncJobFailure(job *jobhash.Job) {
+	if job.Status != "failed" || job.Failure == nil {
+		return
+	}
+	if err := store.MarkJobFailed(job.ID, *job.Failure); err != nil {
+		log.Printf("⚠️ Failed to sync failure for job %s: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,228 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"common/bus"
+	"common/contracts"
+	"common/jobhash"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ctx         = context.Background()
+	redisClient *redis.Client
+	jobHashes   *jobhash.Store
+	msgBus      bus.Bus
+
+	pollInterval = 5 * time.Second
+)
+
+// Init connects to Redis and the job store, migrating it to the latest schema, and publishes
+// MPD requests and consumes status events on b. TRACKER_POLL_INTERVAL (a Go duration, default
+// 5s) sets how often the active jobs are checked.
+func Init(b bus.Bus) {
+	msgBus = b
+	if v := os.Getenv("TRACKER_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("❌ Invalid TRACKER_POLL_INTERVAL %q", v)
+		}
+		pollInterval = d
+	}
+
+	// Redis
+	redisClient = redis.NewClient(&redis.Options{
+		Addr: os.Getenv("REDIS_ADDR"),
+	})
+	if _, err := redisClient.Ping(ctx).Result(); err != nil {
+		log.Fatalf("❌ Redis error: %v", err)
+	}
+	jobHashes = jobhash.New(redisClient)
+
+	// Job store (SQLite or PostgreSQL)
+	InitDB()
+}
+
+// Handler returns the tracker's HTTP API.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/job-summary", handleJobSummary)
+	return mux
+}
+
+// Run records status events, promotes jobs every pollInterval and serves the API on addr until
+// ctx is cancelled.
+func Run(ctx context.Context, addr string) error {
+	go consumeStatusEvents(ctx)
+
+	go func() {
+		for ctx.Err() == nil {
+			checkCompletedJobs()
+			time.Sleep(pollInterval)
+		}
+	}()
+
+	server := &http.Server{Addr: addr, Handler: Handler()}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("📡 Tracker API running on %s/job-summary", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// checkCompletedJobs walks the active job index rather than KEYS, so its cost grows with the
+// number of in-flight jobs, not with everything still cached in Redis.
+func checkCompletedJobs() {
+	err := jobHashes.ForEachActive(ctx, checkJob)
+	if err != nil {
+		log.Printf("❌ Redis scan failed: %v", err)
+	}
+}
+
+func checkJob(jobID string) {
+	job, err := jobHashes.Get(ctx, jobID)
+	if err != nil {
+		log.Printf("❌ Redis read failed (%s): %v", jobID, err)
+		return
+	}
+	if job == nil {
+		// The hash expired before the job finished; nothing left to sync
+		log.Printf("🧹 Job %s expired from Redis, dropping it from the active index", jobID)
+		jobHashes.Untrack(ctx, jobID)
+		return
+	}
+
+	currentStatus := job.Status
+
+	// Safely update DB metadata
+	err = SafeUpdateJobMetadata(jobID, job.StreamName, job.InputURL, job.Codec,
+		strings.Join(job.RequiredResolutions, ","), job.WorkerID, currentStatus)
+	if err != nil {
+		log.Printf("⚠️ Failed to sync metadata to DB for job %s: %v", jobID, err)
+	}
+	syncRepresentations(job)
+	syncJobFailure(job)
+
+	// Terminal jobs have been synced for the last time
+	if isTerminal(job) {
+		jobHashes.Untrack(ctx, jobID)
+		return
+	}
+
+	// Promote from waiting → transcoding if any representation is processing. Both promotions
+	// check the status as they write it, so a job a worker has failed or the controller has
+	// cancelled since it was read keeps that status.
+	if currentStatus == "waiting" && job.AnyRepresentation("processing") {
+		_, ok, err := jobHashes.TransitionFrom(ctx, jobID, jobhash.FieldStatus, "transcoding", []string{"waiting"})
+		if err != nil {
+			log.Printf("❌ Failed to promote job %s to transcoding: %v", jobID, err)
+		} else if ok {
+			log.Printf("🚧 Job %s entering transcoding...", jobID)
+			_ = UpdateJobStatus(jobID, "transcoding")
+			recordTransition(jobID, currentStatus, "transcoding", "representation processing")
+		}
+	}
+
+	// Skip jobs already handed to the mpd-generator
+	if job.MPDPublished {
+		return
+	}
+
+	// If all representations (and any thumbnails) are done, mark job as ready_for_mpd
+	if job.ReadyToPackage() {
+		from, ok, err := jobHashes.TransitionUnless(ctx, jobID, jobhash.FieldStatus, "ready_for_mpd", jobhash.FinalStatuses,
+			jobhash.FieldMPDPublished, "true",
+		)
+		if err != nil {
+			log.Printf("❌ Failed to mark job %s ready_for_mpd: %v", jobID, err)
+			return
+		}
+		if !ok {
+			log.Printf("🛑 Job %s is %s, not packaging it", jobID, from)
+			return
+		}
+		log.Printf("✅ Job %s all representations done. Marking ready_for_mpd.", jobID)
+		publishReadyForMPD(jobID)
+
+		_ = UpdateJobStatus(jobID, "ready_for_mpd")
+		recordTransition(jobID, from, "ready_for_mpd", "all representations done")
+	}
+}
+
+// isTerminal reports whether a job will not change again. A worker marks a job done once every
+// representation is encoded, but it is only finished once the mpd-generator has packaged it. A
+// failed or cancelled job is finished once none of its representations is queued or encoding any
+// more, so the outcome of those a worker skips or completes afterwards is synced too.
+func isTerminal(job *jobhash.Job) bool {
+	switch job.Status {
+	case "failed", "cancelled":
+		return !job.AnyRepresentation("queued") && !job.AnyRepresentation("processing")
+	case "done":
+		return job.MPDPublished
+	}
+	return false
+}
+
+func publishReadyForMPD(jobID string) {
+	payload, err := contracts.Encode(&contracts.MPDRequest{
+		JobID:  jobID,
+		Status: "ready_for_mpd",
+	})
+	if err != nil {
+		log.Printf("❌ Failed to encode MPD request for job %s: %v", jobID, err)
+		return
+	}
+
+	err = msgBus.Publish(ctx, contracts.TopicMPDGeneration, []byte(jobID), payload)
+	if err != nil {
+		log.Printf("❌ Kafka publish failed for job %s: %v", jobID, err)
+	} else {
+		log.Printf("📤 Kafka published (mpd-generation): job_id=%s", jobID)
+	}
+}
+
+func handleJobSummary(w http.ResponseWriter, r *http.Request) {
+	counts := aggregateJobStatuses()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+func aggregateJobStatuses() map[string]int {
+	counts := map[string]int{
+		"waiting":       0,
+		"editing":       0,
+		"analyzing":     0,
+		"splitting":     0,
+		"transcoding":   0,
+		"processing":    0,
+		"done":          0,
+		"failed":        0,
+		"ready_for_mpd": 0,
+		"cancelled":     0,
+	}
+
+	// Finished jobs leave the active index but stay cached until their TTL, so count via SCAN
+	err := jobHashes.ForEachJob(ctx, func(jobID string) {
+		if status, err := jobHashes.Status(ctx, jobID); err == nil && status != "" {
+			counts[status]++
+		}
+	})
+	if err != nil {
+		log.Printf("❌ Redis scan failed: %v", err)
+	}
+
+	return counts
+}
@@ -47,8 +47,8 @@ export default function App() {
     try {
       const res = await fetch("http://13.57.143.121:8080/jobs");
       const data = await res.json();
-      if (Array.isArray(data)) {
-        setJobs(data);
+      if (Array.isArray(data.jobs)) {
+        setJobs(data.jobs);
       }
     } catch (err) {
       console.error("❌ Failed to load jobs:", err);
@@ -76,11 +76,16 @@ export default function App() {
       gop_size: parseInt(gopSize) || 48,
       keyint_min: parseInt(keyintMin) || 48,
     };
+    // One key per submission so network retries don't create duplicate jobs
+    const idempotencyKey = `${Date.now()}-${Math.random().toString(36).slice(2)}`;
 
     try {
       const res = await fetch("http://13.57.143.121:8080/transcode", {
         method: "POST",
-        headers: { "Content-Type": "application/json" },
+        headers: {
+          "Content-Type": "application/json",
+          "Idempotency-Key": idempotencyKey,
+        },
         body: JSON.stringify(payload),
       });
 
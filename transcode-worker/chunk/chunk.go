@@ -0,0 +1,79 @@
+// Package chunk splits a long input into chunks that workers encode in parallel, and checks the
+// timestamps of a representation stitched back together from them.
+package chunk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"common/contracts"
+)
+
+// Plan cuts an input of duration seconds into chunks of at least target seconds. Cuts are made at
+// the source's keyframes, which its encoder placed at scene changes, so a chunk boundary rarely
+// splits a shot. With gopSeconds set, each cut is moved to the nearest multiple of it: every
+// chunk then starts a GOP on the same grid an unchunked encode would use. The last chunk is
+// never shorter than half the target, and an input shorter than two targets is a single chunk.
+func Plan(keyframes []float64, duration, target, gopSeconds float64) []contracts.Chunk {
+	var chunks []contracts.Chunk
+	start := 0.0
+	if duration >= 2*target {
+		for _, kf := range keyframes {
+			cut := kf
+			if gopSeconds > 0 {
+				cut = math.Round(kf/gopSeconds) * gopSeconds
+			}
+			cut = round(cut)
+			if cut-start < target || duration-cut < target/2 {
+				continue
+			}
+			chunks = append(chunks, contracts.Chunk{Index: len(chunks), Start: start, End: cut})
+			start = cut
+		}
+	}
+	return append(chunks, contracts.Chunk{Index: len(chunks), Start: start, End: round(duration)})
+}
+
+// round keeps millisecond precision, finer than any frame interval.
+func round(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
+
+// maxGap is how many typical frame intervals two consecutive frames of a stitched output may be
+// apart before a chunk is considered to be missing frames or misplaced.
+const maxGap = 2.5
+
+// Verify checks the video decode timestamps (in seconds) of a stitched representation: they must
+// rise across every chunk boundary without a gap, and its duration must be the sum of its
+// chunks' durations, give or take a frame per chunk.
+func Verify(dts []float64, duration float64, chunkDurations []float64) error {
+	if len(dts) < 2 {
+		return fmt.Errorf("only %d video frames", len(dts))
+	}
+	steps := make([]float64, 0, len(dts)-1)
+	for i := 1; i < len(dts); i++ {
+		step := dts[i] - dts[i-1]
+		if step <= 0 {
+			return fmt.Errorf("timestamp %.3fs of frame %d does not follow %.3fs", dts[i], i, dts[i-1])
+		}
+		steps = append(steps, step)
+	}
+	sorted := append([]float64(nil), steps...)
+	sort.Float64s(sorted)
+	frame := sorted[len(sorted)/2]
+	for i, step := range steps {
+		if step > maxGap*frame {
+			return fmt.Errorf("%.3fs gap before frame %d at %.3fs", step, i+1, dts[i+1])
+		}
+	}
+
+	sum := 0.0
+	for _, d := range chunkDurations {
+		sum += d
+	}
+	if tolerance := frame * float64(len(chunkDurations)); math.Abs(duration-sum) > tolerance {
+		return fmt.Errorf("duration %.3fs, but the chunks add up to %.3fs", duration, sum)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package chunk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/contracts"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyframes  []float64
+		duration   float64
+		target     float64
+		gopSeconds float64
+		want       [][2]float64
+	}{
+		{"shorter than two targets is one chunk", []float64{5, 10, 15}, 39.9, 20, 0, [][2]float64{{0, 39.9}}},
+		{"no keyframes is one chunk", nil, 120, 20, 0, [][2]float64{{0, 120}}},
+		{
+			"cuts at the first keyframe a target past the previous cut",
+			[]float64{4.1, 19.5, 21.3, 40.2, 45}, 70, 20, 0,
+			[][2]float64{{0, 21.3}, {21.3, 45}, {45, 70}},
+		},
+		{
+			"cuts moved to the GOP grid",
+			[]float64{19.1, 39.9}, 60.02, 20, 2,
+			[][2]float64{{0, 20}, {20, 40}, {40, 60.02}},
+		},
+		{
+			"no last chunk shorter than half the target",
+			[]float64{21, 41}, 45, 20, 0,
+			[][2]float64{{0, 21}, {21, 45}},
+		},
+		{
+			"cuts and the end kept to the millisecond",
+			[]float64{20.00049}, 40.12345, 20, 0,
+			[][2]float64{{0, 20}, {20, 40.123}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []contracts.Chunk
+			for i, c := range tt.want {
+				want = append(want, contracts.Chunk{Index: i, Start: c[0], End: c[1]})
+			}
+			if got := Plan(tt.keyframes, tt.duration, tt.target, tt.gopSeconds); !reflect.DeepEqual(got, want) {
+				t.Errorf("Plan = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// frames returns the decode timestamps of n frames at 24 fps from start.
+func frames(start float64, n int) []float64 {
+	dts := make([]float64, n)
+	for i := range dts {
+		dts[i] = start + float64(i)/24
+	}
+	return dts
+}
+
+func TestVerify(t *testing.T) {
+	stitched := append(frames(0, 480), frames(20, 480)...)
+	tests := []struct {
+		name     string
+		dts      []float64
+		duration float64
+		chunks   []float64
+		err      string // substring of the error, "" for none
+	}{
+		{"chunks back to back", stitched, 40, []float64{20, 20}, ""},
+		{"a frame short per chunk is tolerated", stitched, 40, []float64{20.04, 20.04}, ""},
+		{"too few frames", []float64{0}, 1, []float64{1}, "only 1 video frames"},
+		{"timestamps going back at a boundary", append(frames(0, 480), frames(19.5, 480)...), 40, []float64{20, 20}, "does not follow"},
+		{"a gap at a boundary", append(frames(0, 480), frames(20.5, 480)...), 40.5, []float64{20, 20.5}, "gap before frame 480"},
+		{"duration off by more than a frame per chunk", stitched, 40, []float64{20, 21}, "chunks add up to 41.000s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.dts, tt.duration, tt.chunks)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Verify = %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Verify = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Package cue converts subtitles between SRT, WebVTT and TTML. Every format is read into the same
+// list of timed cues, which is written out as WebVTT or TTML; styling beyond line breaks and the
+// WebVTT cue settings does not survive the conversion.
+package cue
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Cue is one subtitle shown from Start to End, in seconds.
+type Cue struct {
+	Start    float64
+	End      float64
+	Text     string // lines separated by \n; WebVTT/SRT inline tags (<i>, <b>, <u>) are kept
+	Settings string // WebVTT cue settings, e.g. "line:0 align:start"
+}
+
+// Parse reads SRT, WebVTT or TTML, told apart by their content, and returns the cues in order
+// of their start time. Cues that end before they start are dropped.
+func Parse(data []byte) ([]Cue, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+
+	var cues []Cue
+	var err error
+	switch trimmed := strings.TrimSpace(text); {
+	case strings.HasPrefix(trimmed, "WEBVTT"):
+		cues, err = parseText(text, true)
+	case strings.HasPrefix(trimmed, "<"):
+		cues, err = parseTTML([]byte(text))
+	default:
+		cues, err = parseText(text, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kept := cues[:0]
+	for _, c := range cues {
+		if c.End > c.Start {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, errors.New("no cues")
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Start < kept[j].Start })
+	return kept, nil
+}
+
+// fontTag and assTag are SRT markup WebVTT does not have.
+var (
+	fontTag = regexp.MustCompile(`(?i)</?font[^>]*>`)
+	assTag  = regexp.MustCompile(`\{\\[^}]*\}`)
+)
+
+// parseText reads the blocks of an SRT or WebVTT file. A block is a cue if one of its first two
+// lines is a timing line; the WebVTT header and NOTE, STYLE and REGION blocks are skipped.
+func parseText(text string, vtt bool) ([]Cue, error) {
+	var cues []Cue
+	for i, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if vtt && i == 0 || lines[0] == "" {
+			continue
+		}
+		timing := 0
+		if !strings.Contains(lines[0], "-->") {
+			timing = 1 // cue identifier, or the SRT counter
+		}
+		if timing >= len(lines) || !strings.Contains(lines[timing], "-->") {
+			continue
+		}
+
+		start, rest, _ := strings.Cut(lines[timing], "-->")
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("cue %q has no end time", lines[timing])
+		}
+		c := Cue{Text: strings.Join(lines[timing+1:], "\n")}
+		var err error
+		if c.Start, err = parseTimestamp(strings.TrimSpace(start)); err != nil {
+			return nil, err
+		}
+		if c.End, err = parseTimestamp(fields[0]); err != nil {
+			return nil, err
+		}
+		if vtt {
+			c.Settings = strings.Join(fields[1:], " ")
+		} else {
+			c.Text = assTag.ReplaceAllString(fontTag.ReplaceAllString(c.Text, ""), "")
+		}
+		cues = append(cues, c)
+	}
+	return cues, nil
+}
+
+// parseTimestamp reads hh:mm:ss.ttt or mm:ss.ttt, with SRT's comma accepted for the dot.
+func parseTimestamp(ts string) (float64, error) {
+	parts := strings.Split(strings.Replace(ts, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	seconds := 0.0
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+// ttmlTiming holds the timing parameters of a TTML document.
+type ttmlTiming struct {
+	frameRate float64
+	tickRate  float64
+}
+
+const ttmlParameters = "http://www.w3.org/ns/ttml#parameter"
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// parseTTML reads every <p> of a TTML document, at any depth, with <br/> as a line break and
+// the text of its spans. A <p> without its own timing takes its parent <div>'s.
+func parseTTML(data []byte) ([]Cue, error) {
+	timing := ttmlTiming{frameRate: 30, tickRate: 1}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var cues []Cue
+	var current *Cue
+	var text strings.Builder
+	var inherited [][2]float64 // begin/end offsets of the enclosing elements
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("invalid TTML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "tt" {
+				timing.read(t.Attr)
+			}
+			if t.Name.Local == "br" {
+				if current != nil {
+					text.WriteString("\n")
+				}
+				continue
+			}
+			parent := [2]float64{0, math.Inf(1)}
+			if len(inherited) > 0 {
+				parent = inherited[len(inherited)-1]
+			}
+			span, err := timing.span(t.Attr, parent)
+			if err != nil {
+				return nil, err
+			}
+			inherited = append(inherited, span)
+			if t.Name.Local == "p" {
+				current = &Cue{Start: span[0], End: span[1]}
+				text.Reset()
+			}
+		case xml.EndElement:
+			if t.Name.Local == "br" {
+				continue
+			}
+			inherited = inherited[:len(inherited)-1]
+			if t.Name.Local == "p" && current != nil {
+				lines := strings.Split(text.String(), "\n")
+				for i := range lines {
+					lines[i] = strings.TrimSpace(lines[i])
+				}
+				current.Text = strings.Join(lines, "\n")
+				if !math.IsInf(current.End, 1) {
+					cues = append(cues, *current)
+				}
+				current = nil
+			}
+		case xml.CharData:
+			if current != nil {
+				text.WriteString(whitespace.ReplaceAllString(string(t), " "))
+			}
+		}
+	}
+	return cues, nil
+}
+
+// read takes the frame and tick rates from the <tt> element's attributes.
+func (tm *ttmlTiming) read(attrs []xml.Attr) {
+	for _, a := range attrs {
+		if a.Name.Space != ttmlParameters {
+			continue
+		}
+		v, err := strconv.ParseFloat(a.Value, 64)
+		if err != nil || v <= 0 {
+			continue
+		}
+		switch a.Name.Local {
+		case "frameRate":
+			tm.frameRate = v
+		case "tickRate":
+			tm.tickRate = v
+		}
+	}
+}
+
+// span returns the begin and end of an element, in seconds from the start of the document:
+// begin and end are offsets from its parent's begin, and dur ends it that long after its begin.
+func (tm ttmlTiming) span(attrs []xml.Attr, parent [2]float64) ([2]float64, error) {
+	span := parent
+	var dur float64 = -1
+	for _, a := range attrs {
+		if a.Name.Space != "" {
+			continue // begin, end and dur are unqualified
+		}
+		var err error
+		var v float64
+		switch a.Name.Local {
+		case "begin", "end", "dur":
+			if v, err = tm.parse(a.Value); err != nil {
+				return span, err
+			}
+		default:
+			continue
+		}
+		switch a.Name.Local {
+		case "begin":
+			span[0] = parent[0] + v
+		case "end":
+			span[1] = parent[0] + v
+		case "dur":
+			dur = v
+		}
+	}
+	if dur >= 0 {
+		span[1] = math.Min(span[1], span[0]+dur)
+	}
+	return span, nil
+}
+
+var ttmlClock = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})(?:\.(\d+)|:(\d+(?:\.\d+)?))?$`)
+var ttmlOffset = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|m|s|ms|f|t)$`)
+
+// parse reads a TTML time expression: a clock time, with fractional seconds or frames, or an
+// offset in hours, minutes, seconds, milliseconds, frames or ticks.
+func (tm ttmlTiming) parse(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if m := ttmlClock.FindStringSubmatch(v); m != nil {
+		h, _ := strconv.ParseFloat(m[1], 64)
+		min, _ := strconv.ParseFloat(m[2], 64)
+		s, _ := strconv.ParseFloat(m[3], 64)
+		seconds := h*3600 + min*60 + s
+		if m[4] != "" {
+			frac, _ := strconv.ParseFloat("0."+m[4], 64)
+			seconds += frac
+		}
+		if m[5] != "" {
+			frames, _ := strconv.ParseFloat(m[5], 64)
+			seconds += frames / tm.frameRate
+		}
+		return seconds, nil
+	}
+	if m := ttmlOffset.FindStringSubmatch(v); m != nil {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "h":
+			return n * 3600, nil
+		case "m":
+			return n * 60, nil
+		case "s":
+			return n, nil
+		case "ms":
+			return n / 1000, nil
+		case "f":
+			return n / tm.frameRate, nil
+		case "t":
+			return n / tm.tickRate, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid TTML time %q", v)
+}
+
+// WebVTT writes cues as a WebVTT file.
+func WebVTT(cues []Cue) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s", timestamp(c.Start), timestamp(c.End))
+		if c.Settings != "" {
+			b.WriteString(" " + c.Settings)
+		}
+		// A blank line would end the cue early
+		b.WriteString("\n" + strings.ReplaceAll(strings.Trim(c.Text, "\n"), "\n\n", "\n") + "\n")
+	}
+	return []byte(b.String())
+}
+
+var inlineTag = regexp.MustCompile(`<[^>]*>`)
+
+// TTML writes cues as a TTML document in language lang. Inline tags are dropped.
+func TTML(cues []Cue, lang string) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<tt xmlns="http://www.w3.org/ns/ttml" xml:lang="%s">`+"\n", escape(lang))
+	b.WriteString("<body><div>\n")
+	for _, c := range cues {
+		lines := strings.Split(strings.Trim(c.Text, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = escape(html.UnescapeString(inlineTag.ReplaceAllString(line, "")))
+		}
+		fmt.Fprintf(&b, `<p begin="%s" end="%s">%s</p>`+"\n",
+			timestamp(c.Start), timestamp(c.End), strings.Join(lines, "<br/>"))
+	}
+	b.WriteString("</div></body>\n</tt>\n")
+	return []byte(b.String())
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// timestamp formats seconds as hh:mm:ss.ttt, which both WebVTT and TTML accept.
+func timestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
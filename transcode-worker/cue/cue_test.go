@@ -0,0 +1,143 @@
+package cue
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Cue
+	}{
+		{
+			"SRT with Windows line endings, a BOM and SRT-only markup",
+			"\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\n<font color=\"#ffffff\">Hello</font>\r\n\r\n" +
+				"2\r\n00:00:03,000 --> 00:00:04,000\r\n{\\an8}Two\r\n<i>lines</i>\r\n",
+			[]Cue{{Start: 1, End: 2.5, Text: "Hello"}, {Start: 3, End: 4, Text: "Two\n<i>lines</i>"}},
+		},
+		{
+			"SRT with old Mac line endings",
+			"1\r00:00:01,000 --> 00:00:02,000\rHi\r",
+			[]Cue{{Start: 1, End: 2, Text: "Hi"}},
+		},
+		{
+			"WebVTT with settings, identifiers, NOTE blocks and short timestamps",
+			"WEBVTT - title\n\nNOTE a comment\n\nintro\n00:01.000 --> 00:02.000 line:0 align:start\nTop\n\n" +
+				"01:00:00.500 --> 01:00:01.000\n<b>Late</b>\n",
+			[]Cue{{Start: 1, End: 2, Text: "Top", Settings: "line:0 align:start"}, {Start: 3600.5, End: 3601, Text: "<b>Late</b>"}},
+		},
+		{
+			"cues sorted by start, empty and backwards ones dropped",
+			"1\n00:00:05,000 --> 00:00:06,000\nSecond\n\n2\n00:00:01,000 --> 00:00:02,000\nFirst\n\n" +
+				"3\n00:00:03,000 --> 00:00:03,000\nEmpty\n\n4\n00:00:04,000 --> 00:00:03,000\nBackwards\n",
+			[]Cue{{Start: 1, End: 2, Text: "First"}, {Start: 5, End: 6, Text: "Second"}},
+		},
+		{
+			"TTML in ticks and clock times relative to the <div>",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:tickRate="10000000">
+<body><div begin="2s">
+<p begin="10000000t" end="00:00:02.500">Bonjour<br/>
+  le   monde</p>
+</div></body></tt>`,
+			[]Cue{{Start: 3, End: 4.5, Text: "Bonjour\nle monde"}},
+		},
+		{
+			"TTML nested offsets add up, end is relative to the parent's begin, and a <p> without timing takes its parent's",
+			`<tt xmlns="http://www.w3.org/ns/ttml"><body begin="1s"><div begin="2s"><div begin="500ms" end="3s">
+<p begin="1s" dur="1s">Inner</p>
+<p>Whole div</p>
+</div></div></body></tt>`,
+			[]Cue{{Start: 3.5, End: 6, Text: "Whole div"}, {Start: 4.5, End: 5.5, Text: "Inner"}},
+		},
+		{
+			"TTML frames at the document's frame rate, spans and dur capped by end",
+			`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:frameRate="25">
+<body><div><p begin="00:00:01:05" end="2s" dur="10s"><span>Spanned</span> <span>text</span></p>
+<p begin="50f" end="0.05m">Frames</p></div></body></tt>`,
+			[]Cue{{Start: 1.2, End: 2, Text: "Spanned text"}, {Start: 2, End: 3, Text: "Frames"}},
+		},
+		{
+			"TTML <p> without an end anywhere is dropped",
+			`<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="1s">Open</p><p begin="1s" end="2s">Closed</p></div></body></tt>`,
+			[]Cue{{Start: 1, End: 2, Text: "Closed"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cues) != len(tt.want) {
+				t.Fatalf("got %d cues %+v, want %+v", len(cues), cues, tt.want)
+			}
+			for i := range cues {
+				if !approx(cues[i], tt.want[i]) {
+					t.Errorf("cue %d = %+v, want %+v", i, cues[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, input string
+	}{
+		{"malformed SRT start", "1\n00:00:xx,000 --> 00:00:02,000\nHi\n"},
+		{"too many timestamp fields", "1\n1:00:00:01,000 --> 1:00:00:02,000\nHi\n"},
+		{"a bare number is not a timestamp", "1\n5 --> 6\nHi\n"},
+		{"negative timestamp", "WEBVTT\n\n00:-1.000 --> 00:02.000\nHi\n"},
+		{"missing end time", "1\n00:00:01,000 -->\nHi\n"},
+		{"malformed TTML time", `<tt xmlns="http://www.w3.org/ns/ttml"><body><p begin="1 s" end="2s">Hi</p></body></tt>`},
+		{"TTML time in an unknown unit", `<tt xmlns="http://www.w3.org/ns/ttml"><body><p begin="1s" end="2d">Hi</p></body></tt>`},
+		{"truncated TTML", `<tt xmlns="http://www.w3.org/ns/ttml"><body><p begin="1s" end="2s">Hi`},
+		{"no cues", "WEBVTT\n\nNOTE nothing here\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cues, err := Parse([]byte(tt.input)); err == nil {
+				t.Errorf("Parse succeeded with %+v", cues)
+			}
+		})
+	}
+}
+
+func TestWebVTT(t *testing.T) {
+	got := string(WebVTT([]Cue{
+		{Start: 1, End: 2.5, Text: "Hello", Settings: "line:0"},
+		{Start: 3723.0004, End: 3724, Text: "Blank\n\nline\n"},
+	}))
+	want := "WEBVTT\n\n00:00:01.000 --> 00:00:02.500 line:0\nHello\n\n01:02:03.000 --> 01:02:04.000\nBlank\nline\n"
+	if got != want {
+		t.Errorf("WebVTT =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestTTML(t *testing.T) {
+	got := string(TTML([]Cue{{Start: 1, End: 2, Text: "<i>Fish</i> &amp; chips\n<b>a &lt; b</b>"}}, "en"))
+	want := `<p begin="00:00:01.000" end="00:00:02.000">Fish &amp; chips<br/>a &lt; b</p>`
+	if !strings.Contains(got, want) || !strings.Contains(got, `xml:lang="en"`) {
+		t.Errorf("TTML =\n%s\nwant it to contain\n%s", got, want)
+	}
+
+	// What TTML writes reads back as the same cues, without the inline tags
+	cues, err := Parse([]byte(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Cue{{Start: 1, End: 2, Text: "Fish & chips\na < b"}}; !reflect.DeepEqual(cues, want) {
+		t.Errorf("round trip = %+v, want %+v", cues, want)
+	}
+}
+
+// approx compares cues with timings to the microsecond, as frame and tick rates do not divide evenly.
+func approx(a, b Cue) bool {
+	const eps = 1e-6
+	return a.Text == b.Text && a.Settings == b.Settings &&
+		a.Start-b.Start < eps && b.Start-a.Start < eps && a.End-b.End < eps && b.End-a.End < eps
+}
@@ -0,0 +1,87 @@
+// Package edit builds the FFmpeg filter graph that renders an edit list: every clip conformed to
+// the picture and sound of the first, then concatenated in order.
+package edit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SampleRate and ChannelLayout are the sound every clip is conformed to.
+const (
+	SampleRate    = 48000
+	ChannelLayout = "stereo"
+)
+
+// Clip is one edit, already cut to its in and out points by the input options.
+type Clip struct {
+	Duration float64 // seconds
+	Audio    bool    // whether its input has an audio stream
+}
+
+// Format is the picture every clip is scaled, padded and retimed to.
+type Format struct {
+	Width  int
+	Height int
+	FPS    float64
+}
+
+// HasAudio reports whether the rendered edit has sound: if any clip does, the others get silence.
+func HasAudio(clips []Clip) bool {
+	for _, c := range clips {
+		if c.Audio {
+			return true
+		}
+	}
+	return false
+}
+
+// Graph returns the -filter_complex for clips that are FFmpeg inputs 0 to n-1, in order. Its
+// outputs are [v] and, if any clip has sound, [a]. Each clip is letterboxed into the format
+// rather than stretched, and its timestamps restart at zero so concat lays the clips back to back.
+func Graph(clips []Clip, f Format) string {
+	audio := HasAudio(clips)
+	fps := strconv.FormatFloat(f.FPS, 'f', -1, 64)
+
+	var parts []string
+	var inputs strings.Builder
+	for i, c := range clips {
+		parts = append(parts, fmt.Sprintf(
+			"[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,setpts=PTS-STARTPTS[v%d]",
+			i, f.Width, f.Height, f.Width, f.Height, fps, i))
+		fmt.Fprintf(&inputs, "[v%d]", i)
+		if !audio {
+			continue
+		}
+		if c.Audio {
+			parts = append(parts, fmt.Sprintf("[%d:a:0]aresample=%d,aformat=channel_layouts=%s,asetpts=PTS-STARTPTS[a%d]",
+				i, SampleRate, ChannelLayout, i))
+		} else {
+			parts = append(parts, fmt.Sprintf("anullsrc=r=%d:cl=%s,atrim=duration=%s[a%d]",
+				SampleRate, ChannelLayout, strconv.FormatFloat(c.Duration, 'f', 3, 64), i))
+		}
+		fmt.Fprintf(&inputs, "[a%d]", i)
+	}
+
+	outputs, a := "[v]", 0
+	if audio {
+		outputs, a = "[v][a]", 1
+	}
+	parts = append(parts, fmt.Sprintf("%sconcat=n=%d:v=1:a=%d%s", inputs.String(), len(clips), a, outputs))
+	return strings.Join(parts, ";")
+}
+
+// Verify checks the duration of the rendered edit: it must be the sum of its clips' durations,
+// give or take a frame per clip, or a clip was cut short or misplaced.
+func Verify(duration float64, clips []Clip, fps float64) error {
+	sum := 0.0
+	for _, c := range clips {
+		sum += c.Duration
+	}
+	if tolerance := float64(len(clips)) / fps; math.Abs(duration-sum) > tolerance {
+		return fmt.Errorf("rendered %.3fs, but the clips add up to %.3fs", duration, sum)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package edit
+
+import "testing"
+
+func TestGraph(t *testing.T) {
+	format := Format{Width: 1280, Height: 720, FPS: 23.976}
+	tests := []struct {
+		name  string
+		clips []Clip
+		want  string
+	}{
+		{
+			"silent clips",
+			[]Clip{{Duration: 5}, {Duration: 3.5}},
+			"[0:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=23.976,setpts=PTS-STARTPTS[v0];" +
+				"[1:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=23.976,setpts=PTS-STARTPTS[v1];" +
+				"[v0][v1]concat=n=2:v=1:a=0[v]",
+		},
+		{
+			"a silent clip gets silence of its duration when another has sound",
+			[]Clip{{Duration: 5, Audio: true}, {Duration: 3.5}},
+			"[0:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=23.976,setpts=PTS-STARTPTS[v0];" +
+				"[0:a:0]aresample=48000,aformat=channel_layouts=stereo,asetpts=PTS-STARTPTS[a0];" +
+				"[1:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=23.976,setpts=PTS-STARTPTS[v1];" +
+				"anullsrc=r=48000:cl=stereo,atrim=duration=3.500[a1];" +
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[v][a]",
+		},
+		{
+			"a single clip",
+			[]Clip{{Duration: 8, Audio: true}},
+			"[0:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=23.976,setpts=PTS-STARTPTS[v0];" +
+				"[0:a:0]aresample=48000,aformat=channel_layouts=stereo,asetpts=PTS-STARTPTS[a0];" +
+				"[v0][a0]concat=n=1:v=1:a=1[v][a]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Graph(tt.clips, format); got != tt.want {
+				t.Errorf("Graph =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	clips := []Clip{{Duration: 8}, {Duration: 3}, {Duration: 5.5}}
+	tests := []struct {
+		duration float64
+		ok       bool
+	}{
+		{16.5, true},
+		{16.6, true}, // within a frame per clip at 24 fps
+		{16.4, true},
+		{16.7, false}, // more than three frames long
+		{13.5, false}, // a clip missing
+	}
+	for _, tt := range tests {
+		if err := Verify(tt.duration, clips, 24); (err == nil) != tt.ok {
+			t.Errorf("Verify(%.3f) = %v, want ok = %t", tt.duration, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package encoder
+
+import (
+	"strings"
+
+	"common/contracts"
+)
+
+// libaom is the reference AV1 encoder and the default for "av1".
+type libaom struct{ av1MP4 }
+
+var libaomProfiles = map[string][]string{
+	contracts.QualityFast:     {"-cpu-used", "6", "-tiles", "2x2", "-row-mt", "1"},
+	contracts.QualityBalanced: {"-cpu-used", "4", "-tiles", "2x1", "-row-mt", "1"},
+	contracts.QualityArchive:  {"-cpu-used", "2", "-row-mt", "1"},
+}
+
+func (libaom) Codec() string { return "av1" }
+func (libaom) Name() string  { return "libaom-av1" }
+
+func (e libaom) Args(o Options) ([]string, error) {
+	rate, err := libvpxRateArgs(e, o, 32)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-c:v", "libaom-av1", "-usage", "good"}
+	args = append(args, profileArgs(libaomProfiles, o)...)
+	args = append(args, "-pix_fmt", "yuv420p")
+	args = append(args, rate...)
+	return append(args, keyframeArgs(o)...), nil
+}
+
+// svtAV1 is SVT-AV1, much faster than libaom at similar quality. Its own rate control modes
+// (rc=1 VBR capped at mbr, rc=2 CBR) are set through -svtav1-params, and it caps CRF with
+// -maxrate. FFmpeg cannot run it in two passes.
+type svtAV1 struct{ av1MP4 }
+
+var svtAV1Profiles = map[string][]string{
+	contracts.QualityFast:     {"-preset", "10"},
+	contracts.QualityBalanced: {"-preset", "8"},
+	contracts.QualityArchive:  {"-preset", "4"},
+}
+
+func (svtAV1) Codec() string { return "av1" }
+func (svtAV1) Name() string  { return "libsvtav1" }
+
+func (e svtAV1) Args(o Options) ([]string, error) {
+	args := []string{"-c:v", "libsvtav1"}
+	args = append(args, profileArgs(svtAV1Profiles, o)...)
+	args = append(args, "-pix_fmt", "yuv420p")
+
+	// SVT-AV1 takes the keyframe interval from -g; scene-change keyframes are turned off in its own parameters
+	params := []string{"scd=0"}
+	switch o.RateControl {
+	case "":
+		args = append(args, "-b:v", o.Bitrate)
+	case contracts.RateControlCBR:
+		// SVT-AV1 only does CBR with its low-delay prediction structure
+		args = append(args, "-b:v", o.Bitrate)
+		params = append(params, "rc=2", "pred-struct=1")
+	case contracts.RateControlVBR:
+		mbr, err := kbps(o.Maxrate)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-b:v", o.Bitrate)
+		params = append(params, "rc=1", "mbr="+mbr)
+	case contracts.RateControlCRF:
+		args = append(args, "-crf", crf(o, 35), "-maxrate", o.Maxrate)
+	default:
+		return nil, unsupportedRateControl(e, o)
+	}
+
+	args = append(args, keyframeArgs(Options{GopSize: o.GopSize})...)
+	if o.QualityProfile == contracts.QualityArchive {
+		// Tune for subjective quality rather than PSNR
+		params = append(params, "tune=0")
+	}
+	return append(args, "-svtav1-params", strings.Join(params, ":")), nil
+}
+
+// rav1e is the Rust AV1 encoder. It has no VBV to constrain peaks, so it only targets a bitrate,
+// in one or two passes.
+type rav1e struct{ av1MP4 }
+
+var rav1eProfiles = map[string][]string{
+	contracts.QualityFast:     {"-speed", "10", "-tiles", "4"},
+	contracts.QualityBalanced: {"-speed", "6", "-tiles", "2"},
+	contracts.QualityArchive:  {"-speed", "3"},
+}
+
+func (rav1e) Codec() string { return "av1" }
+func (rav1e) Name() string  { return "librav1e" }
+
+func (e rav1e) Args(o Options) ([]string, error) {
+	args := []string{"-c:v", "librav1e"}
+	args = append(args, profileArgs(rav1eProfiles, o)...)
+	args = append(args, "-pix_fmt", "yuv420p", "-b:v", o.Bitrate)
+
+	switch o.RateControl {
+	case "":
+	case contracts.RateControlTwoPass:
+		args = append(args, passArgs(o)...)
+	default:
+		return nil, unsupportedRateControl(e, o)
+	}
+	return append(args, keyframeArgs(o)...), nil
+}
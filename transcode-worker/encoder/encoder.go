@@ -0,0 +1,243 @@
+// Package encoder turns a representation's settings into FFmpeg flags. Each FFmpeg video encoder
+// has its own implementation owning its rate control, preset, profile/level, pixel format and
+// container flags, and a registry maps codec names to the implementation in use.
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+)
+
+// ErrUnknownCodec is returned for a codec no registered encoder produces.
+var ErrUnknownCodec = errors.New("unsupported codec")
+
+// Options are the per-representation settings an encoder maps to its own flags.
+type Options struct {
+	Width, Height int
+	Bitrate       string
+	GopSize       int // 0 leaves keyframe placement to the encoder
+	KeyintMin     int
+
+	QualityProfile string // one of the contracts.Quality* profiles, "" for balanced
+
+	RateControl      string // one of the contracts.RateControl* modes, "" for average bitrate
+	Maxrate, Bufsize string
+	CRF              int // 0 uses the encoder's default
+
+	// Pass is 1 or 2 during a two-pass encode, whose runs share statistics under PassLog
+	Pass    int
+	PassLog string
+}
+
+// NewOptions reads the encoding settings of a transcode job.
+func NewOptions(job contracts.TranscodeJob) (Options, error) {
+	w, h, ok := strings.Cut(job.Resolution, "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return Options{}, fmt.Errorf("invalid resolution %q, want WIDTHxHEIGHT", job.Resolution)
+	}
+	return Options{
+		Width:     width,
+		Height:    height,
+		Bitrate:   job.Bitrate,
+		GopSize:   job.GopSize,
+		KeyintMin: job.KeyintMin,
+
+		QualityProfile: job.QualityProfile,
+
+		RateControl: job.RateControl,
+		Maxrate:     job.Maxrate,
+		Bufsize:     job.Bufsize,
+		CRF:         job.CRF,
+	}, nil
+}
+
+// Passes is the number of FFmpeg runs an encode takes.
+func (o Options) Passes() int {
+	if o.RateControl == contracts.RateControlTwoPass {
+		return 2
+	}
+	return 1
+}
+
+// Encoder builds the FFmpeg output options for one video encoder.
+type Encoder interface {
+	// Codec is the codec this encoder produces, e.g. "av1".
+	Codec() string
+	// Name is the FFmpeg encoder name passed to -c:v, e.g. "libsvtav1".
+	Name() string
+	// Args returns the video flags for one FFmpeg run, starting with -c:v. It fails if the
+	// encoder cannot do the requested rate control.
+	Args(o Options) ([]string, error)
+	// ContainerArgs returns the output format flags for the fragmented MP4 MP4Box packages.
+	ContainerArgs() []string
+}
+
+var (
+	// available holds every implementation by FFmpeg name, selected the ones in use by codec
+	available = map[string]Encoder{}
+	selected  = map[string]Encoder{}
+
+	aliases = map[string]string{
+		"avc":  "h264",
+		"h265": "hevc",
+		"h266": "vvc",
+	}
+)
+
+func init() {
+	for _, e := range []Encoder{x264{}, x265{}, vvenc{}, vp9{}, libaom{}, svtAV1{}, rav1e{}} {
+		register(e)
+	}
+	// The alternative AV1 encoders are chosen with Select
+	for codec, name := range contracts.DefaultEncoders {
+		selected[codec] = available[name]
+	}
+}
+
+func register(e Encoder) {
+	available[e.Name()] = e
+}
+
+// Lookup returns the encoder in use for codec (case-insensitive, aliases such as h265 allowed).
+func Lookup(codec string) (Encoder, error) {
+	codec = strings.ToLower(codec)
+	if canonical, ok := aliases[codec]; ok {
+		codec = canonical
+	}
+	e, ok := selected[codec]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (supported: %s)", ErrUnknownCodec, codec, strings.Join(Codecs(), ", "))
+	}
+	return e, nil
+}
+
+// Select makes the FFmpeg encoder name serve its codec, e.g. Select("libsvtav1") for AV1.
+// It is meant to be called at startup, before any job runs.
+func Select(name string) error {
+	e, ok := available[name]
+	if !ok {
+		names := make([]string, 0, len(available))
+		for n := range available {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown encoder %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	selected[e.Codec()] = e
+	return nil
+}
+
+// Codecs lists the supported codec names.
+func Codecs() []string {
+	codecs := make([]string, 0, len(selected))
+	for c := range selected {
+		codecs = append(codecs, c)
+	}
+	sort.Strings(codecs)
+	return codecs
+}
+
+// keyframeArgs places keyframes every GopSize frames and no closer than KeyintMin, when set.
+func keyframeArgs(o Options) []string {
+	var args []string
+	if o.GopSize > 0 {
+		args = append(args, "-g", strconv.Itoa(o.GopSize))
+	}
+	if o.KeyintMin > 0 {
+		args = append(args, "-keyint_min", strconv.Itoa(o.KeyintMin))
+	}
+	return args
+}
+
+// profileArgs returns the speed/quality flags an encoder's table lists for the job's quality
+// profile. Jobs without one, and profiles the table lacks, get the balanced entry.
+func profileArgs(table map[string][]string, o Options) []string {
+	if args, ok := table[o.QualityProfile]; ok {
+		return args
+	}
+	return table[contracts.QualityBalanced]
+}
+
+// passArgs makes FFmpeg write (pass 1) or read (pass 2) the statistics of a two-pass encode.
+func passArgs(o Options) []string {
+	return []string{"-pass", passNumber(o), "-passlogfile", o.PassLog}
+}
+
+func passNumber(o Options) string {
+	return strconv.Itoa(o.Pass)
+}
+
+// crf returns the requested CRF or the encoder's default.
+func crf(o Options, fallback int) string {
+	if o.CRF > 0 {
+		return strconv.Itoa(o.CRF)
+	}
+	return strconv.Itoa(fallback)
+}
+
+// libvpxRateArgs maps the modes the way libvpx-vp9 and libaom-av1 take them through FFmpeg:
+// equal minrate and maxrate select CBR, and -crf with -b:v is constrained quality capped at -b:v.
+func libvpxRateArgs(e Encoder, o Options, defaultCRF int) ([]string, error) {
+	switch o.RateControl {
+	case "":
+		return []string{"-b:v", o.Bitrate}, nil
+	case contracts.RateControlCBR:
+		return []string{"-b:v", o.Bitrate, "-minrate", o.Bitrate, "-maxrate", o.Bitrate, "-bufsize", o.Bufsize}, nil
+	case contracts.RateControlVBR:
+		return []string{"-b:v", o.Bitrate, "-maxrate", o.Maxrate, "-bufsize", o.Bufsize}, nil
+	case contracts.RateControlCRF:
+		return []string{"-crf", crf(o, defaultCRF), "-b:v", o.Maxrate, "-bufsize", o.Bufsize}, nil
+	case contracts.RateControlTwoPass:
+		return append([]string{"-b:v", o.Bitrate}, passArgs(o)...), nil
+	}
+	return nil, unsupportedRateControl(e, o)
+}
+
+// kbps converts an FFmpeg bitrate such as "3750k" or "4M" to the kbit/s some encoders' own
+// parameters take.
+func kbps(bitrate string) (string, error) {
+	value := strings.ToLower(bitrate)
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "k"):
+		value = strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		value, multiplier = strings.TrimSuffix(value, "m"), 1000
+	default:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid bitrate %q", bitrate)
+		}
+		return strconv.Itoa(n / 1000), nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid bitrate %q", bitrate)
+	}
+	return strconv.Itoa(n * multiplier), nil
+}
+
+func unsupportedRateControl(e Encoder, o Options) error {
+	return fmt.Errorf("%s does not support rate control %q", e.Name(), o.RateControl)
+}
+
+// fragmentedMP4 is the container layout for every codec except AV1.
+type fragmentedMP4 struct{}
+
+func (fragmentedMP4) ContainerArgs() []string {
+	return []string{"-f", "mp4", "-movflags", "+faststart+frag_keyframe+empty_moov+default_base_moof"}
+}
+
+// av1MP4 writes separate moof boxes, which MP4Box needs to segment AV1.
+type av1MP4 struct{}
+
+func (av1MP4) ContainerArgs() []string {
+	return []string{"-f", "mp4", "-movflags", "+faststart+frag_keyframe+separate_moof+omit_tfhd_offset"}
+}
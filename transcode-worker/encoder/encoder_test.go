@@ -0,0 +1,197 @@
+package encoder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/contracts"
+)
+
+// base is a 720p rung with the VBV the controller derives for it.
+var base = Options{
+	Width: 1280, Height: 720,
+	Bitrate: "2500k", Maxrate: "3750k", Bufsize: "7500k",
+	GopSize: 48, KeyintMin: 48,
+	Pass: 1, PassLog: "/segments/job_720p_passlog",
+}
+
+func with(mutate func(*Options)) Options {
+	o := base
+	mutate(&o)
+	return o
+}
+
+// TestEncodersMatchContracts checks that every encoder builds arguments for exactly the rate
+// control modes contracts.Encoders lists for it, which is what the controller validates against.
+func TestEncodersMatchContracts(t *testing.T) {
+	modes := []string{"", contracts.RateControlCBR, contracts.RateControlVBR, contracts.RateControlCRF, contracts.RateControlTwoPass}
+	if len(available) != len(contracts.Encoders) {
+		t.Fatalf("%d encoders registered, contracts lists %d", len(available), len(contracts.Encoders))
+	}
+	for name, e := range available {
+		caps, ok := contracts.Encoders[name]
+		if !ok {
+			t.Errorf("%s is not in contracts.Encoders", name)
+			continue
+		}
+		if caps.Codec != e.Codec() {
+			t.Errorf("%s produces %s, contracts says %s", name, e.Codec(), caps.Codec)
+		}
+		for _, mode := range modes {
+			args, err := e.Args(with(func(o *Options) { o.RateControl = mode }))
+			if want := caps.SupportsRateControl(mode); (err == nil) != want {
+				t.Errorf("%s with rate control %q: err = %v, want supported = %t", name, mode, err, want)
+				continue
+			}
+			if err == nil && (len(args) < 2 || args[0] != "-c:v" || args[1] != name) {
+				t.Errorf("%s with rate control %q: args %v do not start with -c:v %s", name, mode, args, name)
+			}
+		}
+	}
+	for codec, name := range contracts.DefaultEncoders {
+		if e, err := Lookup(codec); err != nil || e.Name() != name {
+			t.Errorf("Lookup(%q) = %v, %v, want %s", codec, e, err, name)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder Encoder
+		opts    Options
+		want    []string // must appear in this order, contiguously
+		absent  []string
+	}{
+		{"x264 average bitrate", x264{}, base,
+			[]string{"-c:v", "libx264", "-preset", "medium", "-profile:v", "high", "-level:v", "3.2", "-pix_fmt", "yuv420p",
+				"-b:v", "2500k", "-g", "48", "-keyint_min", "48", "-sc_threshold", "0"}, nil},
+		{"x264 archive", x264{}, with(func(o *Options) { o.QualityProfile = contracts.QualityArchive }),
+			[]string{"-preset", "slower", "-tune", "film"}, nil},
+		{"x264 cbr", x264{}, with(func(o *Options) { o.RateControl = contracts.RateControlCBR }),
+			[]string{"-b:v", "2500k", "-minrate", "2500k", "-maxrate", "2500k", "-bufsize", "7500k", "-x264-params", "nal-hrd=cbr"}, nil},
+		{"x264 capped crf", x264{}, with(func(o *Options) { o.RateControl = contracts.RateControlCRF; o.CRF = 20 }),
+			[]string{"-crf", "20", "-maxrate", "3750k", "-bufsize", "7500k"}, []string{"-b:v"}},
+		{"x264 2pass", x264{}, with(func(o *Options) { o.RateControl = contracts.RateControlTwoPass; o.Pass = 2 }),
+			[]string{"-b:v", "2500k", "-pass", "2", "-passlogfile", "/segments/job_720p_passlog"}, nil},
+		{"x264 1080p level", x264{}, with(func(o *Options) { o.Height = 1080 }),
+			[]string{"-level:v", "4.2"}, nil},
+		{"x265 cbr", x265{}, with(func(o *Options) { o.RateControl = contracts.RateControlCBR }),
+			[]string{"-x265-params", "scenecut=0:level-idc=3.1:strict-cbr=1"}, nil},
+		{"x265 2pass", x265{}, with(func(o *Options) { o.RateControl = contracts.RateControlTwoPass }),
+			[]string{"-x265-params", "scenecut=0:level-idc=3.1:pass=1:stats=/segments/job_720p_passlog.log"}, nil},
+		{"vvenc average bitrate", vvenc{}, base,
+			[]string{"-pix_fmt", "yuv420p10le", "-b:v", "2500k", "-g", "48"}, []string{"-keyint_min"}},
+		{"vvenc crf as qp", vvenc{}, with(func(o *Options) { o.RateControl = contracts.RateControlCRF; o.CRF = 27 }),
+			[]string{"-qp", "27"}, []string{"-b:v", "-maxrate"}},
+		{"vvenc default qp", vvenc{}, with(func(o *Options) { o.RateControl = contracts.RateControlCRF }),
+			[]string{"-qp", "32"}, nil},
+		{"vvenc 2pass", vvenc{}, with(func(o *Options) { o.RateControl = contracts.RateControlTwoPass; o.Pass = 2 }),
+			[]string{"-b:v", "2500k", "-passes", "2", "-pass", "2", "-vvenc-params", "rcstatsfile=/segments/job_720p_passlog.json"}, nil},
+		{"vp9 cbr", vp9{}, with(func(o *Options) { o.RateControl = contracts.RateControlCBR }),
+			[]string{"-b:v", "2500k", "-minrate", "2500k", "-maxrate", "2500k", "-bufsize", "7500k"}, nil},
+		{"libaom constrained quality", libaom{}, with(func(o *Options) { o.RateControl = contracts.RateControlCRF }),
+			[]string{"-crf", "32", "-b:v", "3750k", "-bufsize", "7500k"}, nil},
+		{"svt-av1 average bitrate", svtAV1{}, base,
+			[]string{"-b:v", "2500k", "-g", "48", "-svtav1-params", "scd=0"}, []string{"-keyint_min"}},
+		{"svt-av1 vbr", svtAV1{}, with(func(o *Options) { o.RateControl = contracts.RateControlVBR }),
+			[]string{"-svtav1-params", "scd=0:rc=1:mbr=3750"}, nil},
+		{"svt-av1 cbr", svtAV1{}, with(func(o *Options) { o.RateControl = contracts.RateControlCBR }),
+			[]string{"-svtav1-params", "scd=0:rc=2:pred-struct=1"}, nil},
+		{"rav1e 2pass", rav1e{}, with(func(o *Options) { o.RateControl = contracts.RateControlTwoPass }),
+			[]string{"-b:v", "2500k", "-pass", "1", "-passlogfile", "/segments/job_720p_passlog"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.encoder.Args(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containsRun(args, tt.want) {
+				t.Errorf("args %v do not contain %v", args, tt.want)
+			}
+			for _, flag := range tt.absent {
+				for _, arg := range args {
+					if arg == flag {
+						t.Errorf("args %v contain %s", args, flag)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSVTCappedCRFArchive(t *testing.T) {
+	args, err := svtAV1{}.Args(with(func(o *Options) {
+		o.RateControl = contracts.RateControlCRF
+		o.QualityProfile = contracts.QualityArchive
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-c:v", "libsvtav1", "-preset", "4", "-pix_fmt", "yuv420p", "-crf", "35", "-maxrate", "3750k",
+		"-g", "48", "-svtav1-params", "scd=0:tune=0"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestKbps(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      bool
+	}{
+		{"3750k", "3750", false},
+		{"3750K", "3750", false},
+		{"4M", "4000", false},
+		{"2500000", "2500", false},
+		{"", "", true},
+		{"fast", "", true},
+		{"1.5M", "", true},
+	}
+	for _, tt := range tests {
+		got, err := kbps(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("kbps(%q) = %q, %v, want %q (error %t)", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		codec, want string
+	}{
+		{"h264", "libx264"},
+		{"AVC", "libx264"},
+		{"h265", "libx265"},
+		{"H266", "libvvenc"},
+		{"vp9", "libvpx-vp9"},
+		{"av1", "libaom-av1"},
+	}
+	for _, tt := range tests {
+		e, err := Lookup(tt.codec)
+		if err != nil || e.Name() != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v, want %s", tt.codec, e, err, tt.want)
+		}
+	}
+
+	if _, err := Lookup("mpeg2"); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("Lookup(mpeg2) error = %v, want ErrUnknownCodec", err)
+	}
+	if err := Select("libx266"); err == nil || !strings.Contains(err.Error(), "libsvtav1") {
+		t.Errorf("Select(libx266) error = %v, want the available encoders listed", err)
+	}
+}
+
+// containsRun reports whether want appears in args as a contiguous run.
+func containsRun(args, want []string) bool {
+	for i := 0; i+len(want) <= len(args); i++ {
+		if reflect.DeepEqual(args[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package encoder
+
+import (
+	"strings"
+
+	"common/contracts"
+)
+
+// x264 encodes H.264 High profile, the most widely playable output.
+type x264 struct{ fragmentedMP4 }
+
+// x264Profiles only tunes archive, with film for high-quality live-action masters. The other
+// tunes either depend on the content (animation, grain, stillimage), trade visual quality for
+// metrics (psnr, ssim) or give up compression for decoding and latency (fastdecode, zerolatency).
+var x264Profiles = map[string][]string{
+	contracts.QualityFast:     {"-preset", "veryfast"},
+	contracts.QualityBalanced: {"-preset", "medium"},
+	contracts.QualityArchive:  {"-preset", "slower", "-tune", "film"},
+}
+
+func (x264) Codec() string { return "h264" }
+func (x264) Name() string  { return "libx264" }
+
+func (e x264) Args(o Options) ([]string, error) {
+	args := []string{"-c:v", "libx264"}
+	args = append(args, profileArgs(x264Profiles, o)...)
+	args = append(args,
+		"-profile:v", "high",
+		"-level:v", h264Level(o.Height),
+		"-pix_fmt", "yuv420p",
+	)
+
+	switch o.RateControl {
+	case "":
+		args = append(args, "-b:v", o.Bitrate)
+	case contracts.RateControlCBR:
+		// nal-hrd=cbr pads with filler data so the stream really is constant
+		args = append(args, "-b:v", o.Bitrate, "-minrate", o.Bitrate, "-maxrate", o.Bitrate, "-bufsize", o.Bufsize,
+			"-x264-params", "nal-hrd=cbr")
+	case contracts.RateControlVBR:
+		args = append(args, "-b:v", o.Bitrate, "-maxrate", o.Maxrate, "-bufsize", o.Bufsize)
+	case contracts.RateControlCRF:
+		args = append(args, "-crf", crf(o, 23), "-maxrate", o.Maxrate, "-bufsize", o.Bufsize)
+	case contracts.RateControlTwoPass:
+		args = append(args, "-b:v", o.Bitrate)
+		args = append(args, passArgs(o)...)
+	default:
+		return nil, unsupportedRateControl(e, o)
+	}
+
+	args = append(args, keyframeArgs(o)...)
+	return append(args, "-sc_threshold", "0"), nil
+}
+
+// h264Level is the lowest level that allows the frame size at up to 60 fps.
+func h264Level(height int) string {
+	switch {
+	case height <= 576:
+		return "3.1"
+	case height <= 720:
+		return "3.2"
+	case height <= 1080:
+		return "4.2"
+	case height <= 1440:
+		return "5.1"
+	default:
+		return "5.2"
+	}
+}
+
+// x265 encodes HEVC Main profile, tagged hvc1 so Apple players accept it.
+type x265 struct{ fragmentedMP4 }
+
+// x265Profiles sets no tune: x265 has no film tune, its default psy-rd already favours visual
+// quality, and its other tunes suit only particular content or metrics like x264's.
+var x265Profiles = map[string][]string{
+	contracts.QualityFast:     {"-preset", "fast"},
+	contracts.QualityBalanced: {"-preset", "medium"},
+	contracts.QualityArchive:  {"-preset", "slow"},
+}
+
+func (x265) Codec() string { return "hevc" }
+func (x265) Name() string  { return "libx265" }
+
+func (e x265) Args(o Options) ([]string, error) {
+	args := []string{"-c:v", "libx265"}
+	args = append(args, profileArgs(x265Profiles, o)...)
+	args = append(args,
+		"-profile:v", "main",
+		"-tag:v", "hvc1",
+		"-pix_fmt", "yuv420p",
+	)
+	// x265 ignores -sc_threshold and -pass; scene cuts, the level and two-pass statistics go
+	// through its own parameters
+	params := []string{"scenecut=0", "level-idc=" + hevcLevel(o.Height)}
+
+	switch o.RateControl {
+	case "":
+		args = append(args, "-b:v", o.Bitrate)
+	case contracts.RateControlCBR:
+		args = append(args, "-b:v", o.Bitrate, "-maxrate", o.Bitrate, "-bufsize", o.Bufsize)
+		params = append(params, "strict-cbr=1")
+	case contracts.RateControlVBR:
+		args = append(args, "-b:v", o.Bitrate, "-maxrate", o.Maxrate, "-bufsize", o.Bufsize)
+	case contracts.RateControlCRF:
+		args = append(args, "-crf", crf(o, 28), "-maxrate", o.Maxrate, "-bufsize", o.Bufsize)
+	case contracts.RateControlTwoPass:
+		args = append(args, "-b:v", o.Bitrate)
+		params = append(params, "pass="+passNumber(o), "stats="+o.PassLog+".log")
+	default:
+		return nil, unsupportedRateControl(e, o)
+	}
+
+	args = append(args, keyframeArgs(o)...)
+	return append(args, "-x265-params", strings.Join(params, ":")), nil
+}
+
+func hevcLevel(height int) string {
+	switch {
+	case height <= 720:
+		return "3.1"
+	case height <= 1080:
+		return "4.1"
+	case height <= 2160:
+		return "5.1"
+	default:
+		return "6.1"
+	}
+}
+
+// vvenc encodes VVC. It only accepts 10-bit input, so the source is converted on the way in.
+// Its rate control targets an average bitrate, in one or two passes, or holds a fixed QP; it has
+// no VBV, so neither CBR nor constrained VBR is available and CRF peaks are not capped.
+type vvenc struct{ fragmentedMP4 }
+
+var vvencProfiles = map[string][]string{
+	contracts.QualityFast:     {"-preset", "faster"},
+	contracts.QualityBalanced: {"-preset", "medium"},
+	contracts.QualityArchive:  {"-preset", "slow"},
+}
+
+func (vvenc) Codec() string { return "vvc" }
+func (vvenc) Name() string  { return "libvvenc" }
+
+func (e vvenc) Args(o Options) ([]string, error) {
+	args := []string{"-c:v", "libvvenc"}
+	args = append(args, profileArgs(vvencProfiles, o)...)
+	args = append(args, "-pix_fmt", "yuv420p10le")
+
+	switch o.RateControl {
+	case "":
+		args = append(args, "-b:v", o.Bitrate)
+	case contracts.RateControlCRF:
+		// A CRF on vvenc's 0-63 QP scale
+		args = append(args, "-qp", crf(o, 32))
+	case contracts.RateControlTwoPass:
+		args = append(args, "-b:v", o.Bitrate, "-passes", "2", "-pass", passNumber(o),
+			"-vvenc-params", "rcstatsfile="+o.PassLog+".json")
+	default:
+		return nil, unsupportedRateControl(e, o)
+	}
+
+	// vvenc takes the intra period from -g and has no minimum keyframe interval
+	return append(args, keyframeArgs(Options{GopSize: o.GopSize})...), nil
+}
@@ -0,0 +1,28 @@
+package encoder
+
+import "common/contracts"
+
+// vp9 encodes VP9 profile 0 with libvpx in its "good" quality mode.
+type vp9 struct{ fragmentedMP4 }
+
+// Tile columns are log2; fewer tiles compress slightly better but parallelize less
+var vp9Profiles = map[string][]string{
+	contracts.QualityFast:     {"-cpu-used", "4", "-tile-columns", "2", "-row-mt", "1"},
+	contracts.QualityBalanced: {"-cpu-used", "2", "-tile-columns", "2", "-row-mt", "1"},
+	contracts.QualityArchive:  {"-cpu-used", "1", "-tile-columns", "1", "-row-mt", "1"},
+}
+
+func (vp9) Codec() string { return "vp9" }
+func (vp9) Name() string  { return "libvpx-vp9" }
+
+func (e vp9) Args(o Options) ([]string, error) {
+	rate, err := libvpxRateArgs(e, o, 31)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-c:v", "libvpx-vp9", "-deadline", "good"}
+	args = append(args, profileArgs(vp9Profiles, o)...)
+	args = append(args, "-profile:v", "0", "-pix_fmt", "yuv420p")
+	args = append(args, rate...)
+	return append(args, keyframeArgs(o)...), nil
+}
@@ -0,0 +1,128 @@
+// Package ladder chooses a per-title encoding ladder. Every candidate resolution is probe-encoded
+// at several CRFs, and the rungs are read off the convex hull of quality against bitrate across
+// all of those encodes, so each rung is the resolution that looks best at its bitrate.
+package ladder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+)
+
+// Point is one probe encode: a candidate resolution at one CRF.
+type Point struct {
+	Representation string
+	Kbps           float64
+	Quality        float64 // PSNR in dB against the source, both at the largest candidate's size
+}
+
+// TransparentPSNR is the quality beyond which extra bits are not visible. The ladder stops at
+// the first hull point that reaches it.
+const TransparentPSNR = 45.0
+
+// minStep is the smallest bitrate ratio between neighbouring rungs; closer rungs would rarely be
+// switched between.
+const minStep = 1.3
+
+// maxOverStatic caps a rung at this multiple of the static ladder's bitrate for its resolution.
+const maxOverStatic = 2.0
+
+// efficiency converts the H.264 probe bitrates to each codec's bitrate at the same quality.
+var efficiency = map[string]float64{
+	"h264": 1,
+	"hevc": 0.6,
+	"vp9":  0.65,
+	"av1":  0.5,
+	"vvc":  0.45,
+}
+
+// Hull returns the upper convex hull of points, ordered by bitrate: each point improves quality
+// over the previous one, at a falling rate per extra kbit/s. Points below it are never the best
+// choice at any bitrate.
+func Hull(points []Point) []Point {
+	sorted := append([]Point(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Kbps != sorted[j].Kbps {
+			return sorted[i].Kbps < sorted[j].Kbps
+		}
+		return sorted[i].Quality > sorted[j].Quality
+	})
+
+	var hull []Point
+	for _, p := range sorted {
+		// More bits for no better quality is never worth it
+		if len(hull) > 0 && p.Quality <= hull[len(hull)-1].Quality {
+			continue
+		}
+		for len(hull) >= 2 && !above(hull[len(hull)-1], hull[len(hull)-2], p) {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull
+}
+
+// above reports whether b lies above the line from a to c.
+func above(b, a, c Point) bool {
+	return (b.Quality-a.Quality)*(c.Kbps-a.Kbps) > (c.Quality-a.Quality)*(b.Kbps-a.Kbps)
+}
+
+// Choose picks the rungs for codec among candidates (lowest first). A candidate on the hull
+// becomes a rung at the highest bitrate it stays on the hull for, which is where the next
+// resolution up starts to look better. Candidates off the hull, and those only needed beyond
+// TransparentPSNR, are left out.
+func Choose(points []Point, candidates []contracts.LadderRung, codec string) ([]contracts.LadderRung, error) {
+	hull := Hull(points)
+	for i, p := range hull {
+		if p.Quality >= TransparentPSNR {
+			hull = hull[:i+1]
+			break
+		}
+	}
+	top := make(map[string]float64)
+	for _, p := range hull {
+		top[p.Representation] = math.Max(top[p.Representation], p.Kbps)
+	}
+
+	factor, ok := efficiency[codec]
+	if !ok {
+		factor = 1
+	}
+
+	var rungs []contracts.LadderRung
+	var rates []float64
+	for _, c := range candidates {
+		kbps, ok := top[c.Representation]
+		if !ok {
+			continue
+		}
+		kbps *= factor
+		if static, err := strconv.ParseFloat(strings.TrimSuffix(c.Bitrate, "k"), 64); err == nil {
+			kbps = math.Min(kbps, maxOverStatic*static)
+		}
+		// Of two rungs too close to tell apart, keep the higher resolution
+		for len(rungs) > 0 && kbps < minStep*rates[len(rates)-1] {
+			rungs, rates = rungs[:len(rungs)-1], rates[:len(rates)-1]
+		}
+		rungs = append(rungs, contracts.LadderRung{
+			Representation: c.Representation,
+			Resolution:     c.Resolution,
+			Bitrate:        formatKbps(kbps),
+		})
+		rates = append(rates, kbps)
+	}
+	if len(rungs) == 0 {
+		return nil, errors.New("no candidate resolution is on the quality/bitrate hull")
+	}
+	return rungs, nil
+}
+
+// formatKbps rounds to 10 kbit/s, the precision the probes can tell apart.
+func formatKbps(kbps float64) string {
+	return fmt.Sprintf("%dk", int(math.Max(1, math.Round(kbps/10)))*10)
+}
@@ -0,0 +1,137 @@
+package ladder
+
+import (
+	"reflect"
+	"testing"
+
+	"common/contracts"
+)
+
+func TestHull(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   []Point
+	}{
+		{"empty", nil, nil},
+		{"single point", []Point{{"360p", 500, 35}}, []Point{{"360p", 500, 35}}},
+		{
+			"concave points are all kept, in bitrate order",
+			[]Point{{"1080p", 3000, 44}, {"360p", 500, 35}, {"720p", 1500, 42}},
+			[]Point{{"360p", 500, 35}, {"720p", 1500, 42}, {"1080p", 3000, 44}},
+		},
+		{
+			"a point below the line between its neighbours is dropped",
+			[]Point{{"360p", 100, 30}, {"480p", 200, 36}, {"720p", 300, 37}, {"1080p", 400, 41}},
+			[]Point{{"360p", 100, 30}, {"480p", 200, 36}, {"1080p", 400, 41}},
+		},
+		{
+			"more bits for no better quality is dropped",
+			[]Point{{"360p", 200, 36}, {"720p", 250, 35}, {"1080p", 300, 36}},
+			[]Point{{"360p", 200, 36}},
+		},
+		{
+			"of two points at one bitrate the better is kept",
+			[]Point{{"360p", 200, 33}, {"720p", 200, 36}},
+			[]Point{{"720p", 200, 36}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hull(tt.points); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Hull = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChoose(t *testing.T) {
+	candidates := []contracts.LadderRung{
+		{Representation: "360p", Resolution: "640x360", Bitrate: "800k"},
+		{Representation: "480p", Resolution: "854x480", Bitrate: "1200k"},
+		{Representation: "720p", Resolution: "1280x720", Bitrate: "2500k"},
+		{Representation: "1080p", Resolution: "1920x1080", Bitrate: "4500k"},
+	}
+	tests := []struct {
+		name   string
+		points []Point
+		codec  string
+		want   map[string]string // representation -> bitrate, lowest first
+		order  []string
+	}{
+		{
+			"every resolution on the hull",
+			[]Point{{"360p", 300, 31}, {"360p", 500, 35}, {"720p", 1500, 42}, {"1080p", 3000, 44}},
+			"h264",
+			map[string]string{"360p": "500k", "720p": "1500k", "1080p": "3000k"},
+			[]string{"360p", "720p", "1080p"},
+		},
+		{
+			"bitrates scaled to the codec's efficiency",
+			[]Point{{"360p", 500, 35}, {"720p", 1500, 42}, {"1080p", 3000, 44}},
+			"av1",
+			map[string]string{"360p": "250k", "720p": "750k", "1080p": "1500k"},
+			[]string{"360p", "720p", "1080p"},
+		},
+		{
+			"a resolution off the hull is left out",
+			[]Point{{"360p", 500, 35}, {"480p", 1000, 36}, {"720p", 1500, 42}},
+			"h264",
+			map[string]string{"360p": "500k", "720p": "1500k"},
+			[]string{"360p", "720p"},
+		},
+		{
+			"nothing beyond the first transparent point",
+			[]Point{{"360p", 500, 35}, {"720p", 1500, 45.5}, {"1080p", 3000, 46}},
+			"h264",
+			map[string]string{"360p": "500k", "720p": "1500k"},
+			[]string{"360p", "720p"},
+		},
+		{
+			"a rung is capped at twice its static bitrate",
+			[]Point{{"360p", 500, 30}, {"360p", 2000, 40}, {"720p", 4000, 43}},
+			"h264",
+			map[string]string{"360p": "1600k", "720p": "4000k"},
+			[]string{"360p", "720p"},
+		},
+		{
+			"of two rungs too close together the higher resolution is kept",
+			[]Point{{"360p", 1000, 38}, {"720p", 1200, 40}, {"1080p", 3000, 44}},
+			"h264",
+			map[string]string{"720p": "1200k", "1080p": "3000k"},
+			[]string{"720p", "1080p"},
+		},
+		{
+			"an unknown codec keeps the probe bitrates",
+			[]Point{{"360p", 504, 35}},
+			"mpeg2",
+			map[string]string{"360p": "500k"},
+			[]string{"360p"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rungs, err := Choose(tt.points, candidates, tt.codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []string
+			for _, rung := range rungs {
+				order = append(order, rung.Representation)
+				if rung.Bitrate != tt.want[rung.Representation] {
+					t.Errorf("%s at %s, want %s", rung.Representation, rung.Bitrate, tt.want[rung.Representation])
+				}
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("rungs %v, want %v", order, tt.order)
+			}
+		})
+	}
+
+	if _, err := Choose(nil, candidates, "h264"); err == nil {
+		t.Error("Choose without probe points succeeded")
+	}
+	if _, err := Choose([]Point{{"2160p", 8000, 44}}, candidates, "h264"); err == nil {
+		t.Error("Choose with no candidate on the hull succeeded")
+	}
+}
@@ -1,30 +1,29 @@
 package main
 
 import (
-    "context"
+	"context"
 	"log"
 	"os"
-    "os/signal"
-    "syscall"
+	"os/signal"
+	"syscall"
+
+	"common/bus"
+
+	"transcode-worker/worker"
 )
 
 func main() {
-    log.Println("🚀 Starting Transcoder Worker...")
+	log.Println("🚀 Starting Transcoder Worker...")
 
-    if err := InitKafka(); err != nil {
-        log.Fatalf("❌ Failed to initialize Kafka: %v", err)
-        os.Exit(1)
-    }
+	msgBus := bus.NewKafka(bus.Brokers("KAFKA_BROKERS"))
+	worker.Init(msgBus)
 
-    log.Println("📡 Subscribing to 'transcode-jobs' topic...")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-    defer stop()
+	// WORKER_INSTANCE_ID is optional: unique per worker instance
+	worker.New(os.Getenv("WORKER_INSTANCE_ID")).Run(ctx)
 
-    go ConsumeTranscodeJobs("transcode-jobs")
-
-    <-ctx.Done()
-    log.Println("🛑 Graceful shutdown signal received")
-    // TODO: Close Kafka producer/consumer if needed
+	log.Println("🛑 Graceful shutdown signal received")
+	msgBus.Close()
 }
-
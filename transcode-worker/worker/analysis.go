@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+	"common/failure"
+
+	"transcode-worker/ladder"
+)
+
+// probeCRFs are the x264 CRFs each candidate resolution is probe-encoded at, from
+// near-transparent to visibly degraded. x264 at a fast preset stands in for every codec: the
+// ladder package converts its bitrates.
+var probeCRFs = []int{18, 23, 28, 33, 38}
+
+// probeSeconds is how much of the input the probes encode, taken from the middle of the title
+// where intros and credits are least likely.
+const probeSeconds = 20.0
+
+// maxPSNR stands in for the infinite PSNR of a lossless probe.
+const maxPSNR = 100.0
+
+var psnrAverage = regexp.MustCompile(`PSNR .*average:(\S+)`)
+
+// HandleLadderAnalysis probe-encodes the input at every candidate resolution and publishes the
+// rungs chosen from the results. The caller already holds one of the worker's FFmpeg slots.
+func (w *Worker) HandleLadderAnalysis(job contracts.LadderAnalysisJob) {
+	if w.tracker.IsJobCancelled(job.JobID) {
+		log.Printf("🛑 [Job %s] Job was cancelled. Skipping ladder analysis.", job.JobID)
+		return
+	}
+	w.tracker.MarkJobAnalyzing(job.JobID, w.ID)
+
+	localInput, err := DownloadInput(job.InputURL, job.JobID, "analysis")
+	if err != nil {
+		log.Printf("❌ [Job %s] Download failed: %v", job.JobID, err)
+		w.tracker.MarkJobFailed(job.JobID, downloadFailure(err))
+		return
+	}
+	defer os.Remove(localInput)
+
+	points, err := probeLadder(job, localInput)
+	var rungs []contracts.LadderRung
+	if err == nil {
+		rungs, err = ladder.Choose(points, job.Candidates, job.Codec)
+	}
+	if err == nil {
+		err = PublishLadder(job.JobID, rungs)
+	}
+	if err != nil {
+		f := asFailure(err, failure.StageAnalyze)
+		log.Printf("❌ [Job %s] Ladder analysis failed: %v", job.JobID, f)
+		w.tracker.MarkJobFailed(job.JobID, f)
+		return
+	}
+	log.Printf("🪜 [Job %s] Ladder chosen from %d probes: %+v", job.JobID, len(points), rungs)
+}
+
+// probeLadder encodes a sample of the input at every candidate resolution and CRF. Quality is
+// measured at the largest candidate's size, the size a player would upscale every rung to.
+func probeLadder(job contracts.LadderAnalysisJob, input string) ([]ladder.Point, error) {
+	duration, err := ProbeDuration(input)
+	if err != nil {
+		return nil, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	start, length := 0.0, duration
+	if duration > probeSeconds {
+		start, length = (duration-probeSeconds)/2, probeSeconds
+	}
+	if length <= 0 {
+		return nil, failure.New(failure.StageProbe, failure.ReasonCorruptInput, fmt.Errorf("input has no duration"))
+	}
+	window := []string{"-ss", strconv.FormatFloat(start, 'f', 3, 64), "-t", strconv.FormatFloat(length, 'f', 3, 64)}
+
+	reference := largestResolution(job.Candidates)
+	var points []ladder.Point
+	for _, c := range job.Candidates {
+		for _, crf := range probeCRFs {
+			out := filepath.Join(outputDir, fmt.Sprintf("%s_probe_%s_%d.mp4", job.JobID, c.Representation, crf))
+			p, err := probe(input, out, window, length, reference, c, crf)
+			os.Remove(out)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
+
+// probe encodes the sample window to out at one resolution and CRF, then measures its bitrate
+// and its PSNR against the same window of the source.
+func probe(input, out string, window []string, length float64, reference string, c contracts.LadderRung, crf int) (ladder.Point, error) {
+	args := append(append([]string{}, window...),
+		"-i", input,
+		"-vf", "scale="+c.Resolution,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", strconv.Itoa(crf),
+		"-an", "-f", "mp4", "-y", out,
+	)
+	if output, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		return ladder.Point{}, failure.FromCommand(failure.StageAnalyze, err, output)
+	}
+	info, err := os.Stat(out)
+	if err != nil {
+		return ladder.Point{}, err
+	}
+
+	filter := fmt.Sprintf("[0:v]scale=%s:flags=bicubic[dist];[1:v]scale=%s:flags=bicubic[ref];[dist][ref]psnr", reference, reference)
+	args = append(append([]string{"-i", out}, window...), "-i", input, "-lavfi", filter, "-f", "null", "-")
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return ladder.Point{}, failure.FromCommand(failure.StageAnalyze, err, output)
+	}
+	m := psnrAverage.FindSubmatch(output)
+	if m == nil {
+		return ladder.Point{}, fmt.Errorf("no PSNR in the output of ffmpeg for %s at crf %d", c.Representation, crf)
+	}
+	psnr, err := strconv.ParseFloat(string(m[1]), 64)
+	if err != nil {
+		return ladder.Point{}, fmt.Errorf("invalid PSNR %q: %w", m[1], err)
+	}
+
+	return ladder.Point{
+		Representation: c.Representation,
+		Kbps:           float64(info.Size()) * 8 / length / 1000,
+		Quality:        math.Min(psnr, maxPSNR),
+	}, nil
+}
+
+// largestResolution returns the WIDTHxHEIGHT of the candidate with the most pixels.
+func largestResolution(candidates []contracts.LadderRung) string {
+	best, bestPixels := "", 0
+	for _, c := range candidates {
+		w, h, _ := strings.Cut(c.Resolution, "x")
+		width, _ := strconv.Atoi(w)
+		height, _ := strconv.Atoi(h)
+		if width*height > bestPixels {
+			best, bestPixels = c.Resolution, width*height
+		}
+	}
+	return best
+}
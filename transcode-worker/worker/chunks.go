@@ -0,0 +1,197 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+	"common/failure"
+
+	"transcode-worker/chunk"
+	"transcode-worker/encoder"
+)
+
+// HandleSplit cuts a chunked job's input at its keyframes and publishes the chunks. The input it
+// downloads stays in the shared segments directory, where the chunks are encoded from. The caller
+// already holds one of the worker's FFmpeg slots.
+func (w *Worker) HandleSplit(job contracts.SplitJob) {
+	if w.tracker.IsJobCancelled(job.JobID) {
+		log.Printf("🛑 [Job %s] Job was cancelled. Skipping split.", job.JobID)
+		return
+	}
+	w.tracker.MarkJobSplitting(job.JobID, w.ID)
+
+	localInput, err := DownloadInput(job.InputURL, job.JobID, "split")
+	if err != nil {
+		log.Printf("❌ [Job %s] Download failed: %v", job.JobID, err)
+		w.tracker.MarkJobFailed(job.JobID, downloadFailure(err))
+		return
+	}
+
+	var input string
+	chunks, err := planChunks(job, localInput)
+	if err == nil {
+		input, err = filepath.Abs(localInput)
+	}
+	if err == nil {
+		err = PublishChunkPlan(job.JobID, chunks, "file://"+input)
+	}
+	if err != nil {
+		os.Remove(localInput)
+		f := asFailure(err, failure.StageSplit)
+		log.Printf("❌ [Job %s] Split failed: %v", job.JobID, f)
+		w.tracker.MarkJobFailed(job.JobID, f)
+		return
+	}
+	log.Printf("🧩 [Job %s] Input cut into %d chunks: %+v", job.JobID, len(chunks), chunks)
+}
+
+// planChunks probes the input's duration and keyframes, and its frame rate if the cuts have to
+// follow the job's GOP.
+func planChunks(job contracts.SplitJob, input string) ([]contracts.Chunk, error) {
+	duration, err := ProbeDuration(input)
+	if err != nil {
+		return nil, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	keyframes, err := probeKeyframes(input)
+	if err != nil {
+		return nil, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	gopSeconds := 0.0
+	if job.GopSize > 0 {
+		fps, err := ProbeFrameRate(input)
+		if err != nil {
+			return nil, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+		}
+		gopSeconds = float64(job.GopSize) / fps
+	}
+	return chunk.Plan(keyframes, duration, job.ChunkSeconds, gopSeconds), nil
+}
+
+// probeKeyframes returns the presentation times of the keyframes of a file's first video stream.
+// It reads packet flags only, so nothing is decoded.
+func probeKeyframes(path string) ([]float64, error) {
+	return probePackets(path, "pts_time,flags", func(fields []string) bool {
+		return len(fields) > 1 && strings.HasPrefix(fields[1], "K")
+	})
+}
+
+// probeDecodeTimes returns the decode times of every packet of a file's first video stream.
+func probeDecodeTimes(path string) ([]float64, error) {
+	return probePackets(path, "dts_time", nil)
+}
+
+// probePackets lists the first entry of every video packet ffprobe shows, in seconds, keeping
+// only those keep accepts (all if nil). Packets without a timestamp are skipped.
+func probePackets(path, entries string, keep func(fields []string) bool) ([]float64, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet="+entries,
+		"-of", "csv=p=0",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var times []float64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if keep != nil && !keep(fields) {
+			continue
+		}
+		t, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue // N/A
+		}
+		times = append(times, t)
+	}
+	return times, scanner.Err()
+}
+
+// finishChunk records an encoded chunk. The worker that encodes the representation's last chunk
+// stitches them all.
+func (w *Worker) finishChunk(job TranscodeJob, enc encoder.Encoder, output string) {
+	done, err := w.tracker.CompleteChunk(job.JobID, job.Representation, job.Chunk.Index, output)
+	if err != nil {
+		log.Printf("❌ [Job %s] Failed to record chunk %d of %s: %v", job.JobID, job.Chunk.Index, job.Representation, err)
+		w.failJob(job, failure.New(failure.StageTranscode, failure.ReasonUnknown, err))
+		return
+	}
+	log.Printf("🧩 [Job %s] %s chunk %d encoded (%d of %d)", job.JobID, job.Representation, job.Chunk.Index, done, job.ChunkCount)
+	if done < job.ChunkCount {
+		return
+	}
+
+	outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s.mp4", job.JobID, job.Representation))
+	if err := w.stitch(job, enc, outputPath); err != nil {
+		f := asFailure(err, failure.StageStitch)
+		log.Printf("❌ [Job %s] Stitching %s failed: %v", job.JobID, job.Representation, f)
+		w.failJob(job, f)
+		return
+	}
+
+	var outputSize int64
+	if info, err := os.Stat(outputPath); err == nil {
+		outputSize = info.Size()
+	}
+	w.tracker.UpdateRepresentationStatus(job.JobID, job.Representation, "done", outputPath, outputSize)
+}
+
+// stitch concatenates the chunks of a representation without re-encoding them, then checks the
+// result's timestamps before the chunks are deleted.
+func (w *Worker) stitch(job TranscodeJob, enc encoder.Encoder, output string) error {
+	chunks, err := w.tracker.ChunkOutputs(job.JobID, job.Representation, job.ChunkCount)
+	if err != nil {
+		return err
+	}
+
+	durations := make([]float64, len(chunks))
+	var list strings.Builder
+	for i, c := range chunks {
+		if durations[i], err = ProbeDuration(c); err != nil {
+			return fmt.Errorf("chunk %d: %w", i, err)
+		}
+		fmt.Fprintf(&list, "file '%s'\n", c)
+	}
+	listPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s_chunks.txt", job.JobID, job.Representation))
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listPath)
+
+	args := []string{"-f", "concat", "-safe", "0", "-i", listPath, "-c", "copy"}
+	args = append(args, enc.ContainerArgs()...)
+	cmd := exec.Command("ffmpeg", append(args, "-y", output)...)
+	log.Printf("🧵 [Job %s] Stitching %d chunks: %s", job.JobID, len(chunks), strings.Join(cmd.Args, " "))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return failure.FromCommand(failure.StageStitch, err, out)
+	}
+
+	dts, err := probeDecodeTimes(output)
+	if err != nil {
+		return err
+	}
+	duration, err := ProbeDuration(output)
+	if err != nil {
+		return err
+	}
+	if err := chunk.Verify(dts, duration, durations); err != nil {
+		return failure.New(failure.StageStitch, failure.ReasonTimestamps, err)
+	}
+
+	for _, c := range chunks {
+		os.Remove(c)
+	}
+	log.Printf("✅ [Job %s] %s stitched from %d chunks: %s", job.JobID, job.Representation, len(chunks), output)
+	return nil
+}
@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+	"common/failure"
+
+	"transcode-worker/edit"
+)
+
+// HandleEdit renders a job's edit list into the single input its representations, thumbnails
+// and quality checks are then made from, and hands it to the controller. Rendering once keeps
+// every representation frame for frame the same cut. The caller already holds one of the
+// worker's FFmpeg slots.
+func (w *Worker) HandleEdit(job contracts.EditJob) {
+	if w.tracker.IsJobCancelled(job.JobID) {
+		log.Printf("🛑 [Job %s] Job was cancelled. Skipping edit.", job.JobID)
+		return
+	}
+	w.tracker.MarkJobEditing(job.JobID, w.ID)
+
+	output, err := filepath.Abs(filepath.Join(outputDir, fmt.Sprintf("%s_edit.mkv", job.JobID)))
+	if err != nil {
+		w.tracker.MarkJobFailed(job.JobID, failure.New(failure.StageEdit, failure.ReasonUnknown, err))
+		return
+	}
+	duration, err := renderEdits(job, output)
+	if err == nil {
+		err = PublishEditResult(job.JobID, "file://"+output, duration)
+	}
+	if err != nil {
+		f := asFailure(err, failure.StageEdit)
+		log.Printf("❌ [Job %s] Edit failed: %v", job.JobID, f)
+		w.tracker.MarkJobFailed(job.JobID, f)
+		return
+	}
+	log.Printf("✂️ [Job %s] %d clips rendered into %.3fs: %s", job.JobID, len(job.Edits), duration, output)
+}
+
+// renderEdits cuts every clip from its input, conforms it to the first clip's size and frame
+// rate, and concatenates them into output. Cuts are made on decoded frames, not at keyframes,
+// so each clip starts and ends on the requested frame. It returns the output's probed duration.
+func renderEdits(job contracts.EditJob, output string) (float64, error) {
+	// An input several clips are cut from is downloaded once
+	downloads := make(map[string]string)
+	defer func() {
+		for _, local := range downloads {
+			os.Remove(local)
+		}
+	}()
+
+	clips := make([]edit.Clip, len(job.Edits))
+	var args []string
+	for i, e := range job.Edits {
+		local, ok := downloads[e.InputURL]
+		if !ok {
+			var err error
+			local, err = DownloadInput(e.InputURL, job.JobID, fmt.Sprintf("edit%d", len(downloads)))
+			if err != nil {
+				return 0, downloadFailure(err)
+			}
+			downloads[e.InputURL] = local
+		}
+		clip, err := probeClip(e, local)
+		if err != nil {
+			return 0, fmt.Errorf("edits[%d]: %w", i, err)
+		}
+		clips[i] = clip
+
+		if e.In > 0 {
+			args = append(args, "-ss", strconv.FormatFloat(e.In, 'f', -1, 64))
+		}
+		if e.Out > 0 {
+			args = append(args, "-t", strconv.FormatFloat(e.Out-e.In, 'f', -1, 64))
+		}
+		args = append(args, "-i", local)
+	}
+
+	first := downloads[job.Edits[0].InputURL]
+	width, height, err := probeImageSize(first)
+	if err != nil {
+		return 0, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	fps, err := ProbeFrameRate(first)
+	if err != nil {
+		return 0, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+
+	args = append(args,
+		"-filter_complex", edit.Graph(clips, edit.Format{Width: width, Height: height, FPS: fps}),
+		"-map", "[v]")
+	if edit.HasAudio(clips) {
+		args = append(args, "-map", "[a]", "-c:a", "pcm_s16le")
+	}
+	// A near-lossless intermediate with a keyframe every second, which chunked jobs are cut at
+	args = append(args,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "10",
+		"-g", strconv.Itoa(max(1, int(fps+0.5))),
+		"-f", "matroska", "-y", output)
+
+	cmd := exec.Command("ffmpeg", args...)
+	log.Printf("✂️ [Job %s] Rendering %d clips: %s", job.JobID, len(clips), strings.Join(cmd.Args, " "))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return 0, failure.FromCommand(failure.StageEdit, err, out)
+	}
+
+	duration, err := ProbeDuration(output)
+	if err != nil {
+		return 0, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	if err := edit.Verify(duration, clips, fps); err != nil {
+		return 0, failure.New(failure.StageEdit, failure.ReasonTimestamps, err)
+	}
+	return duration, nil
+}
+
+// probeClip checks the edit's in and out points against its input and returns the clip's
+// duration and whether it has sound.
+func probeClip(e contracts.Edit, input string) (edit.Clip, error) {
+	duration, err := ProbeDuration(input)
+	if err != nil {
+		return edit.Clip{}, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	out := e.Out
+	if out == 0 {
+		out = duration
+	}
+	if e.In >= duration || out > duration {
+		return edit.Clip{}, failure.New(failure.StageEdit, failure.ReasonInvalidArguments,
+			fmt.Errorf("clip %gs to %gs is past the end of the %.3fs input", e.In, out, duration))
+	}
+	audio, err := probeHasAudio(input)
+	if err != nil {
+		return edit.Clip{}, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	return edit.Clip{Duration: out - e.In, Audio: audio}, nil
+}
+
+// probeHasAudio reports whether a media file has an audio stream.
+func probeHasAudio(path string) (bool, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=index",
+		"-of", "csv=p=0",
+		path,
+	).Output()
+	if err != nil {
+		return false, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
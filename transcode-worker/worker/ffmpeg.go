@@ -0,0 +1,381 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"common/bus"
+	"common/failure"
+
+	"transcode-worker/encoder"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const MaxConcurrentFFmpeg = 2
+
+var (
+	ctx         = context.Background()
+	outputDir   = "/segments"
+	redisClient *redis.Client
+)
+
+// Init connects to Redis (REDIS_ADDR), writes output under SEGMENTS_DIR (default /segments) and
+// receives jobs and publishes status events on b. Every Worker in the process shares these.
+func Init(b bus.Bus) {
+	msgBus = b
+	if dir := os.Getenv("SEGMENTS_DIR"); dir != "" {
+		outputDir = dir
+	}
+	// AV1_ENCODER picks the AV1 implementation: libaom-av1 (default), libsvtav1 or librav1e
+	if name := os.Getenv("AV1_ENCODER"); name != "" {
+		if err := encoder.Select(name); err != nil {
+			log.Fatalf("❌ Invalid AV1_ENCODER: %v", err)
+		}
+	}
+
+	redisClient = redis.NewClient(&redis.Options{
+		Addr: os.Getenv("REDIS_ADDR"),
+	})
+	if _, err := redisClient.Ping(ctx).Result(); err != nil {
+		log.Fatalf("❌ Failed to connect to Redis: %v", err)
+	}
+	log.Println("✅ Connected to Redis")
+}
+
+// Worker consumes transcode jobs and runs up to MaxConcurrentFFmpeg of them at once. Several
+// workers can run in one process; each competes for jobs like a separate instance would.
+type Worker struct {
+	ID      string
+	tracker *JobTracker
+	slots   chan struct{}
+}
+
+// New creates a worker that reports itself as id (which may be empty).
+func New(id string) *Worker {
+	return &Worker{
+		ID:      id,
+		tracker: NewJobTracker(redisClient, id),
+		slots:   make(chan struct{}, MaxConcurrentFFmpeg),
+	}
+}
+
+// Run consumes every priority topic, the edits, the ladder analyses, the splits, the thumbnails
+// and the subtitles until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	log.Printf("📡 Worker %q subscribing to priority job topics...", w.ID)
+	go w.ConsumeTranscodeJobs(ctx)
+	go w.ConsumeEdits(ctx)
+	go w.ConsumeLadderAnalysis(ctx)
+	go w.ConsumeSplits(ctx)
+	go w.ConsumeThumbnails(ctx)
+	go w.ConsumeSubtitles(ctx)
+	<-ctx.Done()
+}
+
+func DownloadInput(inputURL string, jobID, representation string) (string, error) {
+	log.Printf("🌐 Downloading input from: %s", inputURL)
+	// One copy per representation: several of a job's representations may be encoding at once
+	localPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s_input.mp4", jobID, representation))
+
+	outFile, err := os.Create(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer outFile.Close()
+
+	body, err := openInput(inputURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	_, err = io.Copy(outFile, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	log.Printf("📥 Downloaded to: %s", localPath)
+	return localPath, nil
+}
+
+// openInput fetches an input over HTTP, or opens a file:// input a worker rendered into the shared
+// segments directory, such as an edited input. Files anywhere else are refused.
+func openInput(inputURL string) (io.ReadCloser, error) {
+	if file, ok, err := sharedInput(inputURL); ok {
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input: %w", err)
+		}
+		return f, nil
+	}
+
+	resp, err := http.Get(inputURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch input: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// sharedInput returns the path of a file:// input, which must be in the shared segments
+// directory. ok is false for any other input.
+func sharedInput(inputURL string) (path string, ok bool, err error) {
+	file, ok := strings.CutPrefix(inputURL, "file://")
+	if !ok {
+		return "", false, nil
+	}
+	base, _ := filepath.Abs(outputDir)
+	rel, err := filepath.Rel(base, filepath.Clean(file))
+	if err != nil || !filepath.IsAbs(file) || strings.HasPrefix(rel, "..") {
+		return "", true, fmt.Errorf("input %s is outside %s", file, outputDir)
+	}
+	return file, true, nil
+}
+
+// HandleTranscodeJob runs a job; the scheduler has already reserved its FFmpeg slot. A job that
+// already failed or was cancelled is skipped, unless this is a retry of the representation that
+// failed it; a chunk is never retried on its own.
+func (w *Worker) HandleTranscodeJob(job TranscodeJob) {
+	retry := job.Chunk == nil && w.tracker.RepresentationStatus(job.JobID, job.Representation) == "failed"
+	if status, ok := w.tracker.MarkJobWaiting(job.JobID, w.ID, retry); !ok {
+		log.Printf("🛑 [Job %s] Job is %s. Skipping %s.", job.JobID, status, job.Representation)
+		w.tracker.MarkRepresentationCancelled(job.JobID, job.Representation, status)
+		return
+	}
+	w.runTranscode(job)
+}
+
+func (w *Worker) runTranscode(job TranscodeJob) {
+	if job.Codec == "" {
+		job.Codec = "h264"
+	}
+
+	log.Printf("📥 [Job %s] Processing Job | Codec=%s | Resolution=%s | Bitrate=%s | GOP=%d | KeyintMin=%d",
+		job.JobID, job.Codec, job.Resolution, job.Bitrate, job.GopSize, job.KeyintMin)
+
+	w.tracker.MarkJobProcessing(job.JobID)
+	// A chunk's files are named after it, since several chunks of a representation may be
+	// encoding on the same worker
+	name := job.Representation
+	if job.Chunk != nil {
+		name = fmt.Sprintf("%s_chunk%03d", job.Representation, job.Chunk.Index)
+		w.tracker.MarkChunkProcessing(job.JobID, job.Representation, w.ID, *job.Chunk, job.ChunkCount)
+	} else {
+		w.tracker.MarkRepresentationProcessing(job.JobID, job.Representation, w.ID)
+	}
+
+	enc, err := encoder.Lookup(job.Codec)
+	if err != nil {
+		log.Printf("❌ [Job %s] %v", job.JobID, err)
+		w.failJob(job, failure.New(failure.StageTranscode, failure.ReasonUnsupportedCodec, err))
+		return
+	}
+	opts, err := encoder.NewOptions(job)
+	if err == nil {
+		// Catch a rate control the encoder cannot do before downloading anything
+		_, err = enc.Args(opts)
+	}
+	if err != nil {
+		log.Printf("❌ [Job %s] %v", job.JobID, err)
+		w.failJob(job, failure.New(failure.StageTranscode, failure.ReasonInvalidArguments, err))
+		return
+	}
+
+	// An input already in the shared segments directory, such as the one a split left for its
+	// chunks, is read in place rather than copied for every chunk and representation
+	localInput, shared, err := sharedInput(job.InputURL)
+	if shared && err == nil {
+		_, err = os.Stat(localInput)
+	} else if !shared {
+		localInput, err = DownloadInput(job.InputURL, job.JobID, name)
+	}
+	if err != nil {
+		log.Printf("❌ [Job %s] Download failed: %v", job.JobID, err)
+		w.failJob(job, downloadFailure(err))
+		return
+	}
+	if !shared {
+		defer os.Remove(localInput)
+	}
+
+	outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s.mp4", job.JobID, name))
+
+	// A two-pass encode analyses the input first; both runs share the statistics under PassLog
+	if opts.Passes() == 2 {
+		opts.PassLog = filepath.Join(outputDir, fmt.Sprintf("%s_%s_passlog", job.JobID, name))
+		defer removeStatsFiles(opts.PassLog)
+	}
+	for pass := 1; pass <= opts.Passes(); pass++ {
+		if opts.Passes() == 2 {
+			opts.Pass = pass
+		}
+		args, err := buildFFmpegArgs(localInput, outputPath, job, enc, opts)
+		if err != nil {
+			w.failJob(job, failure.New(failure.StageTranscode, failure.ReasonInvalidArguments, err))
+			return
+		}
+
+		cmd := exec.Command("ffmpeg", args...)
+		log.Printf("⚙️ [Job %s] Running FFmpeg: %s", job.JobID, strings.Join(cmd.Args, " "))
+
+		stderr, err := cmd.CombinedOutput()
+		if err != nil {
+			f := failure.FromCommand(failure.StageTranscode, err, stderr)
+			log.Printf("❌ [Job %s] FFmpeg failed (%s, exit %d):\n%s", job.JobID, f.Reason, f.ExitCode, f.StderrTail)
+			w.failJob(job, f)
+			return
+		}
+	}
+
+	log.Printf("✅ [Job %s] Segment generated: %s", job.JobID, outputPath)
+
+	if duration, err := ProbeDuration(outputPath); err != nil {
+		log.Printf("⚠️ [Job %s] Could not probe output duration: %v", job.JobID, err)
+	} else {
+		w.tracker.AddEncodedMinutes(job.TenantID, duration/60)
+	}
+
+	if job.Chunk != nil {
+		w.finishChunk(job, enc, outputPath)
+		return
+	}
+
+	var outputSize int64
+	if info, err := os.Stat(outputPath); err == nil {
+		outputSize = info.Size()
+	}
+
+	if job.QualityCheck != nil {
+		report, err := measureQuality(outputPath, localInput, job)
+		if err != nil {
+			log.Printf("❌ [Job %s] Quality measurement of %s failed: %v", job.JobID, job.Representation, err)
+			w.failJob(job, asFailure(err, failure.StageQuality))
+			return
+		}
+		w.tracker.RecordRepresentationQuality(job.JobID, job.Representation, report)
+		if msg := belowThreshold(report, job.QualityCheck); msg != "" {
+			log.Printf("❌ [Job %s] %s failed its quality check: %s", job.JobID, job.Representation, msg)
+			w.failJob(job, failure.New(failure.StageQuality, failure.ReasonBelowThreshold, errors.New(msg)))
+			return
+		}
+	}
+
+	// ✅ Mark per-representation as done:
+	w.tracker.UpdateRepresentationStatus(job.JobID, job.Representation, "done", outputPath, outputSize)
+}
+
+// failJob records the failure on both the representation and its parent job.
+func (w *Worker) failJob(job TranscodeJob, f failure.Failure) {
+	w.tracker.MarkRepresentationFailed(job.JobID, job.Representation, f)
+	w.tracker.MarkJobFailed(job.JobID, f)
+}
+
+// downloadFailure classifies a failed download, which is most likely an unreachable input.
+func downloadFailure(err error) failure.Failure {
+	reason := failure.Classify(err.Error())
+	if reason == failure.ReasonUnknown {
+		reason = failure.ReasonInputUnavailable
+	}
+	return failure.New(failure.StageDownload, reason, err)
+}
+
+// asFailure keeps the classification of a failed command and files any other error under stage.
+func asFailure(err error, stage string) failure.Failure {
+	var f failure.Failure
+	if errors.As(err, &f) {
+		return f
+	}
+	return failure.New(stage, failure.ReasonUnknown, err)
+}
+
+// ProbeDuration returns the container duration of a media file in seconds.
+func ProbeDuration(path string) (float64, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
+
+// ProbeFrameRate returns the frame rate of a media file's first video stream.
+func ProbeFrameRate(path string) (float64, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=r_frame_rate",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	rate := strings.TrimSpace(string(out))
+	num, den, ok := strings.Cut(rate, "/")
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if !ok || err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("invalid frame rate %q", rate)
+	}
+	return n / d, nil
+}
+
+// buildFFmpegArgs scales the input to the representation's size and leaves the video and
+// container flags to its encoder. The first pass of a two-pass encode only writes statistics.
+// A chunk job reads only its chunk of the input.
+func buildFFmpegArgs(input, output string, job TranscodeJob, enc encoder.Encoder, opts encoder.Options) ([]string, error) {
+	video, err := enc.Args(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if job.Chunk != nil {
+		args = append(args,
+			"-ss", strconv.FormatFloat(job.Chunk.Start, 'f', 3, 64),
+			"-t", strconv.FormatFloat(job.Chunk.End-job.Chunk.Start, 'f', 3, 64),
+		)
+	}
+	args = append(args,
+		"-i", input,
+		"-vf", fmt.Sprintf("scale=%s", job.Resolution),
+	)
+	args = append(args, video...)
+	args = append(args, "-an")
+	if opts.Pass == 1 {
+		return append(args, "-f", "null", "-y", os.DevNull), nil
+	}
+	args = append(args, enc.ContainerArgs()...)
+	args = append(args, "-y", output)
+
+	return args, nil
+}
+
+// removeStatsFiles deletes the statistics files FFmpeg and the encoders wrote under prefix: the
+// logs of a two-pass encode or the per-frame quality scores.
+func removeStatsFiles(prefix string) {
+	files, _ := filepath.Glob(prefix + "*")
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
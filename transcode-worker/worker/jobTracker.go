@@ -0,0 +1,277 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"common/contracts"
+	"common/failure"
+	"common/jobhash"
+	"common/quality"
+	"common/subtitle"
+	"common/thumbnail"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type JobTracker struct {
+	hashes   *jobhash.Store
+	workerID string
+	ctx      context.Context
+}
+
+func NewJobTracker(client *redis.Client, workerID string) *JobTracker {
+	return &JobTracker{
+		hashes:   jobhash.New(client),
+		workerID: workerID,
+		ctx:      context.Background(),
+	}
+}
+
+func (jt *JobTracker) SetJobStatus(jobID, status string) {
+	jt.advanceJob(jobID, status, "")
+}
+
+// transition sets the job status (or a representation's status) along with any extra fields,
+// and publishes the change so the tracker can append it to the job's history.
+func (jt *JobTracker) transition(jobID, representation, to, message string, fields ...interface{}) {
+	jt.transitionUnless(jobID, representation, to, message, nil, fields...)
+}
+
+// advanceJob sets the job status like transition, unless the job already failed or was
+// cancelled. It returns the status it found and whether it replaced it.
+func (jt *JobTracker) advanceJob(jobID, to, message string, fields ...interface{}) (string, bool) {
+	return jt.transitionUnless(jobID, "", to, message, jobhash.FinalStatuses, fields...)
+}
+
+// transitionUnless is transition, except that a status holding one of the unless states is left
+// as it is. It returns the status it found and whether it replaced it.
+func (jt *JobTracker) transitionUnless(jobID, representation, to, message string, unless []string, fields ...interface{}) (string, bool) {
+	field := jobhash.FieldStatus
+	if representation != "" {
+		field = representation
+	}
+
+	from, ok, err := jt.hashes.TransitionUnless(jt.ctx, jobID, field, to, unless, fields...)
+	if err != nil {
+		log.Printf("⚠️ Failed to set %s=%s for job %s: %v", field, to, jobID, err)
+		return "", false
+	}
+	if ok && from != to {
+		PublishStatus(jt.workerID, jobID, representation, from, to, message)
+	}
+	return from, ok
+}
+
+func (jt *JobTracker) IsJobCancelled(jobID string) bool {
+	status, _ := jt.hashes.Status(jt.ctx, jobID)
+	return status == "cancelled"
+}
+
+// RepresentationStatus reads a representation's status, "" if it has none.
+func (jt *JobTracker) RepresentationStatus(jobID, representation string) string {
+	status, _ := jt.hashes.Field(jt.ctx, jobID, representation)
+	return status
+}
+
+// MarkJobWaiting moves the job to waiting before one of its representations is encoded. It returns
+// the status it found and false, leaving the job as it is, if the job already failed or was
+// cancelled: its representation must not be encoded then. A retry of the representation that
+// failed the job reopens it instead, and puts it back in the active index so it is synced and
+// packaged again.
+func (jt *JobTracker) MarkJobWaiting(jobID, workerID string, retry bool) (string, bool) {
+	unless := jobhash.FinalStatuses
+	if retry {
+		unless = []string{"cancelled"}
+	}
+	from, ok := jt.transitionUnless(jobID, "", "waiting", "", unless,
+		jobhash.FieldWorkerID, workerID,
+	)
+	if ok && from == "failed" {
+		if err := jt.hashes.Track(jt.ctx, jobID); err != nil {
+			log.Printf("⚠️ Failed to track job %s: %v", jobID, err)
+		}
+	}
+	return from, ok
+}
+
+// MarkJobAnalyzing records that the worker is probe-encoding the job's input to choose its ladder.
+func (jt *JobTracker) MarkJobAnalyzing(jobID, workerID string) {
+	jt.advanceJob(jobID, "analyzing", "choosing the ladder",
+		jobhash.FieldWorkerID, workerID,
+	)
+}
+
+// MarkJobSplitting records that the worker is cutting the job's input into chunks.
+func (jt *JobTracker) MarkJobSplitting(jobID, workerID string) {
+	jt.advanceJob(jobID, "splitting", "cutting the input into chunks",
+		jobhash.FieldWorkerID, workerID,
+	)
+}
+
+// MarkJobEditing records that the worker is rendering the job's edit list.
+func (jt *JobTracker) MarkJobEditing(jobID, workerID string) {
+	jt.advanceJob(jobID, "editing", "rendering the edit list",
+		jobhash.FieldWorkerID, workerID,
+	)
+}
+
+func (jt *JobTracker) MarkJobProcessing(jobID string) {
+	jt.advanceJob(jobID, "processing", "",
+		jobhash.FieldStartedAt, jobhash.Now(),
+	)
+}
+
+// MarkJobFailed fails the job with a structured reason the tracker copies into the job record. A
+// job that already failed keeps its first failure, and a cancelled job stays cancelled.
+func (jt *JobTracker) MarkJobFailed(jobID string, f failure.Failure) {
+	_, ok := jt.advanceJob(jobID, "failed", f.Error(),
+		jobhash.FieldCompletedAt, jobhash.Now(),
+		jobhash.FieldFailure, f.Encode(),
+	)
+	if ok {
+		jt.hashes.Finish(jt.ctx, jobID)
+	}
+}
+
+// MarkRepresentationProcessing starts a new attempt at one representation; the tracker copies
+// these <rep>_* fields into the job_representations table.
+func (jt *JobTracker) MarkRepresentationProcessing(jobID, resolution, workerID string) {
+	if err := jt.hashes.StartAttempt(jt.ctx, jobID, resolution); err != nil {
+		log.Printf("⚠️ Failed to start attempt at %s for job %s: %v", resolution, jobID, err)
+	}
+	jt.transition(jobID, resolution, "processing", "",
+		jobhash.RepField(resolution, jobhash.RepWorkerID), workerID,
+		jobhash.RepField(resolution, jobhash.RepStartedAt), jobhash.Now(),
+	)
+}
+
+// MarkChunkProcessing starts the encode of one chunk. The representation is processing from its
+// first chunk until the last one is stitched.
+func (jt *JobTracker) MarkChunkProcessing(jobID, resolution, workerID string, chunk contracts.Chunk, count int) {
+	if err := jt.hashes.StartChunk(jt.ctx, jobID, resolution); err != nil {
+		log.Printf("⚠️ Failed to start chunk %d of %s for job %s: %v", chunk.Index, resolution, jobID, err)
+	}
+	jt.transition(jobID, resolution, "processing", fmt.Sprintf("encoding chunk %d of %d", chunk.Index+1, count),
+		jobhash.RepField(resolution, jobhash.RepWorkerID), workerID,
+	)
+}
+
+// CompleteChunk records an encoded chunk's output and returns how many of the representation's
+// chunks are encoded.
+func (jt *JobTracker) CompleteChunk(jobID, resolution string, index int, output string) (int, error) {
+	return jt.hashes.CompleteChunk(jt.ctx, jobID, resolution, index, output)
+}
+
+// ChunkOutputs returns the outputs of every chunk of a representation, in order.
+func (jt *JobTracker) ChunkOutputs(jobID, resolution string, count int) ([]string, error) {
+	return jt.hashes.ChunkOutputs(jt.ctx, jobID, resolution, count)
+}
+
+// MarkRepresentationCancelled records that a representation was skipped because its job had
+// already failed or been cancelled, with the job's status. A representation that is already done
+// or failed, e.g. the one a skipped chunk belongs to, keeps its outcome.
+func (jt *JobTracker) MarkRepresentationCancelled(jobID, resolution, jobStatus string) {
+	jt.transitionUnless(jobID, resolution, "cancelled", fmt.Sprintf("job %s before encoding", jobStatus),
+		[]string{"done", "failed"})
+}
+
+// MarkRepresentationFailed records why an attempt at one representation failed.
+func (jt *JobTracker) MarkRepresentationFailed(jobID, resolution string, f failure.Failure) {
+	jt.transition(jobID, resolution, "failed", f.Error(),
+		jobhash.RepField(resolution, jobhash.RepError), f.Message,
+		jobhash.RepField(resolution, jobhash.RepFailure), f.Encode(),
+		jobhash.RepField(resolution, jobhash.RepFinishedAt), jobhash.Now(),
+	)
+}
+
+// RecordRepresentationQuality stores the scores measured on a representation's output.
+func (jt *JobTracker) RecordRepresentationQuality(jobID, resolution string, report *quality.Report) {
+	err := jt.hashes.Set(jt.ctx, jobID, jobhash.RepField(resolution, jobhash.RepQuality), report.Encode())
+	if err != nil {
+		log.Printf("⚠️ Failed to store %s quality for job %s: %v", resolution, jobID, err)
+	}
+}
+
+// MarkThumbnailsProcessing records that the worker is generating the job's thumbnails. Their
+// status is kept and reported like a representation's.
+func (jt *JobTracker) MarkThumbnailsProcessing(jobID string) {
+	jt.transition(jobID, jobhash.FieldThumbnails, "processing", "generating the poster and thumbnails")
+}
+
+// MarkThumbnailsFailed records that the job's thumbnails could not be generated.
+func (jt *JobTracker) MarkThumbnailsFailed(jobID string, f failure.Failure) {
+	jt.transition(jobID, jobhash.FieldThumbnails, "failed", f.Error())
+}
+
+// CompleteThumbnails stores the generated thumbnails, which may be the last thing the job was
+// waiting for.
+func (jt *JobTracker) CompleteThumbnails(jobID string, set thumbnail.Set) {
+	jt.transition(jobID, jobhash.FieldThumbnails, "done", fmt.Sprintf("%d thumbnails on %d sprite sheets", set.Count, set.Sheets),
+		jobhash.FieldThumbnailSet, set.Encode(),
+	)
+	jt.checkIfJobCompleted(jobID)
+}
+
+// MarkSubtitlesProcessing records that the worker is converting the job's subtitles. Their
+// status is kept and reported like a representation's.
+func (jt *JobTracker) MarkSubtitlesProcessing(jobID string) {
+	jt.transition(jobID, jobhash.FieldSubtitles, "processing", "converting subtitle tracks")
+}
+
+// MarkSubtitlesFailed records that the job's subtitles could not be converted.
+func (jt *JobTracker) MarkSubtitlesFailed(jobID string, f failure.Failure) {
+	jt.transition(jobID, jobhash.FieldSubtitles, "failed", f.Error())
+}
+
+// CompleteSubtitles stores the converted tracks, which may be the last thing the job was
+// waiting for.
+func (jt *JobTracker) CompleteSubtitles(jobID string, set subtitle.Set) {
+	jt.transition(jobID, jobhash.FieldSubtitles, "done", fmt.Sprintf("%d %s subtitle tracks", len(set.Tracks), set.Format),
+		jobhash.FieldSubtitleSet, set.Encode(),
+	)
+	jt.checkIfJobCompleted(jobID)
+}
+
+// ✅ New: Track per-representation status and output
+func (jt *JobTracker) UpdateRepresentationStatus(jobID, resolution, status, outputPath string, outputSize int64) {
+	// Example:
+	// 360p = done
+	// 360p_output = /segments/jobID_360p.mp4
+	jt.transition(jobID, resolution, status, "",
+		jobhash.RepField(resolution, jobhash.RepOutput), outputPath,
+		jobhash.RepField(resolution, jobhash.RepOutputSize), outputSize,
+		jobhash.RepField(resolution, jobhash.RepFinishedAt), jobhash.Now(),
+	)
+
+	// Check if parent job can be marked done
+	jt.checkIfJobCompleted(jobID)
+}
+
+// ✅ Helper to check completion across all required representations
+func (jt *JobTracker) checkIfJobCompleted(jobID string) {
+	job, err := jt.hashes.Get(jt.ctx, jobID)
+	if err != nil || job == nil || len(job.RequiredResolutions) == 0 {
+		return // Cannot verify completeness without required_resolutions
+	}
+
+	// The job stays in the active index: the tracker still has to hand it to the mpd-generator
+	if job.ReadyToPackage() {
+		jt.advanceJob(jobID, "done", "all representations encoded",
+			jobhash.FieldCompletedAt, jobhash.Now(),
+		)
+	}
+}
+
+// AddEncodedMinutes charges encoded output minutes to the tenant's daily quota counter read by the controller.
+func (jt *JobTracker) AddEncodedMinutes(tenantID string, minutes float64) {
+	if tenantID == "" {
+		tenantID = "default"
+	}
+	key := jt.hashes.Namespaced(fmt.Sprintf("quota:%s:encoded_minutes:%s", tenantID, time.Now().UTC().Format("2006-01-02")))
+	rdb := jt.hashes.Client()
+	rdb.IncrByFloat(jt.ctx, key, minutes)
+	rdb.Expire(jt.ctx, key, 48*time.Hour)
+}
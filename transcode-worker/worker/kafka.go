@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"common/bus"
+	"common/contracts"
+)
+
+// msgBus delivers transcode jobs and carries status events; Kafka in deployments, in-memory when embedded.
+var msgBus bus.Bus
+
+const kafkaStatusTopic = contracts.TopicStatus
+
+// PublishStatus publishes a job (or representation, if set) state transition to Kafka
+func PublishStatus(workerID, jobID, representation, from, to, message string) {
+	payload, err := contracts.Encode(&contracts.StatusEvent{
+		JobID:          jobID,
+		Representation: representation,
+		Service:        "transcode-worker",
+		FromState:      from,
+		ToState:        to,
+		WorkerID:       workerID,
+		Message:        message,
+		Timestamp:      time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to encode status message: %v", err)
+		return
+	}
+
+	if err := msgBus.Publish(ctx, kafkaStatusTopic, []byte(jobID), payload); err != nil {
+		log.Printf("❌ Failed to publish status to Kafka: %v", err)
+		return
+	}
+
+	log.Printf("📤 Published status to Kafka: jobID=%s, rep=%s, %s → %s", jobID, representation, from, to)
+}
+
+// ConsumeTranscodeJobs reads from every priority topic and lets the scheduler decide which job runs next
+func (w *Worker) ConsumeTranscodeJobs(ctx context.Context) {
+	queues := make(map[string]<-chan TranscodeJob)
+	for _, priority := range priorityOrder {
+		queue := make(chan TranscodeJob)
+		queues[priority] = queue
+		go consumeTopic(ctx, contracts.PriorityTopics[priority], priority, queue)
+	}
+
+	NewJobScheduler(w, queues).Run(ctx)
+}
+
+// ConsumeLadderAnalysis runs the ladder analyses of automatic ladders.
+func (w *Worker) ConsumeLadderAnalysis(ctx context.Context) {
+	consume(ctx, w, contracts.TopicLadderAnalysis, "ladder analyses", w.HandleLadderAnalysis)
+}
+
+// ConsumeSplits cuts the inputs of chunked jobs.
+func (w *Worker) ConsumeSplits(ctx context.Context) {
+	consume(ctx, w, contracts.TopicSplit, "splits", w.HandleSplit)
+}
+
+// ConsumeThumbnails generates the thumbnails of jobs that asked for them.
+func (w *Worker) ConsumeThumbnails(ctx context.Context) {
+	consume(ctx, w, contracts.TopicThumbnails, "thumbnails", w.HandleThumbnails)
+}
+
+// ConsumeSubtitles converts the subtitles of jobs that asked for them.
+func (w *Worker) ConsumeSubtitles(ctx context.Context) {
+	consume(ctx, w, contracts.TopicSubtitles, "subtitles", w.HandleSubtitles)
+}
+
+// ConsumeEdits renders the edit lists of jobs submitted with one.
+func (w *Worker) ConsumeEdits(ctx context.Context) {
+	consume(ctx, w, contracts.TopicEdits, "edits", w.HandleEdit)
+}
+
+// consume decodes every message of a stage's topic and hands it to handle in one of the worker's
+// FFmpeg slots, so the stage shares the worker's capacity with encodes. what names the messages
+// in the logs.
+func consume[T any, M interface {
+	*T
+	contracts.Message
+}](ctx context.Context, w *Worker, topic, what string, handle func(T)) {
+	log.Printf("🎧 Listening for %s on topic: %s", what, topic)
+
+	err := msgBus.Subscribe(ctx, topic, "transcode-worker-group", func(msg bus.Message) {
+		var job T
+		if err := contracts.Decode(msg.Value, M(&job)); err != nil {
+			log.Printf("❌ Rejected message on %s: %v: %s", topic, err, string(msg.Value))
+			return
+		}
+
+		select {
+		case w.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-w.slots }()
+		handle(job)
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to consume topic %s: %v", topic, err)
+	}
+}
+
+// PublishEditResult hands the rendered edit list of a job to the controller, which dispatches
+// the job with it as its input.
+func PublishEditResult(jobID, inputURL string, duration float64) error {
+	payload, err := contracts.Encode(&contracts.EditResult{JobID: jobID, InputURL: inputURL, Duration: duration})
+	if err != nil {
+		return err
+	}
+	return msgBus.Publish(ctx, contracts.TopicEditResults, []byte(jobID), payload)
+}
+
+// PublishChunkPlan hands the chunks a job's input was cut into, and where that input was left, to
+// the controller, which dispatches them.
+func PublishChunkPlan(jobID string, chunks []contracts.Chunk, inputURL string) error {
+	payload, err := contracts.Encode(&contracts.ChunkPlan{JobID: jobID, Chunks: chunks, InputURL: inputURL})
+	if err != nil {
+		return err
+	}
+	return msgBus.Publish(ctx, contracts.TopicChunkPlans, []byte(jobID), payload)
+}
+
+// PublishLadder hands the rungs chosen for a job to the controller, which dispatches them.
+func PublishLadder(jobID string, rungs []contracts.LadderRung) error {
+	payload, err := contracts.Encode(&contracts.LadderResult{JobID: jobID, Rungs: rungs})
+	if err != nil {
+		return err
+	}
+	return msgBus.Publish(ctx, contracts.TopicLadders, []byte(jobID), payload)
+}
+
+// consumeTopic reads jobs from one topic. The queue is unbuffered, so a job is only read from the bus
+// once the previous one from the same topic has been taken by the scheduler.
+func consumeTopic(ctx context.Context, topic, priority string, queue chan<- TranscodeJob) {
+	log.Printf("🎧 Listening for %s priority jobs on topic: %s", priority, topic)
+
+	err := msgBus.Subscribe(ctx, topic, "transcode-worker-group", func(msg bus.Message) {
+		var job TranscodeJob
+		if err := contracts.Decode(msg.Value, &job); err != nil {
+			log.Printf("❌ Rejected job message on %s: %v: %s", topic, err, string(msg.Value))
+			return
+		}
+		if job.Priority == "" {
+			job.Priority = priority
+		}
+
+		log.Printf("🆕 Received job: %+v", job)
+		select {
+		case queue <- job:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to consume topic %s: %v", topic, err)
+	}
+}
@@ -0,0 +1,6 @@
+package worker
+
+import "common/contracts"
+
+// TranscodeJob represents a single video transcoding task
+type TranscodeJob = contracts.TranscodeJob
@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"common/contracts"
+	"common/failure"
+	"common/quality"
+)
+
+var (
+	vmafOnce      sync.Once
+	vmafAvailable bool
+
+	psnrFrame = regexp.MustCompile(`psnr_avg:(\S+)`)
+	ssimFrame = regexp.MustCompile(`All:(\S+)`)
+)
+
+// hasVMAF reports whether the installed FFmpeg was built with libvmaf.
+func hasVMAF() bool {
+	vmafOnce.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-filters").Output()
+		vmafAvailable = err == nil && bytes.Contains(out, []byte("libvmaf"))
+		log.Printf("📏 libvmaf available: %v", vmafAvailable)
+	})
+	return vmafAvailable
+}
+
+// measureQuality scores a rendition against the source scaled to the rendition's size: VMAF when
+// FFmpeg has libvmaf, PSNR and SSIM otherwise. The per-frame scores are written to stats files
+// next to the output and summarized.
+func measureQuality(output, input string, job TranscodeJob) (*quality.Report, error) {
+	stats := filepath.Join(outputDir, fmt.Sprintf("%s_%s_quality", job.JobID, job.Representation))
+	reference := fmt.Sprintf("[1:v]scale=%s:flags=bicubic[ref]", job.Resolution)
+
+	var filter string
+	if hasVMAF() {
+		filter = fmt.Sprintf("%s;[0:v][ref]libvmaf=log_fmt=json:log_path=%s_vmaf.json", reference, stats)
+	} else {
+		filter = fmt.Sprintf("%s;[0:v]split[d1][d2];[ref]split[r1][r2];[d1][r1]psnr=stats_file=%s_psnr.log;[d2][r2]ssim=stats_file=%s_ssim.log",
+			reference, stats, stats)
+	}
+	defer removeStatsFiles(stats)
+
+	cmd := exec.Command("ffmpeg", "-i", output, "-i", input, "-lavfi", filter, "-f", "null", "-")
+	log.Printf("📏 [Job %s] Measuring %s: %s", job.JobID, job.Representation, strings.Join(cmd.Args, " "))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, failure.FromCommand(failure.StageQuality, err, out)
+	}
+
+	var report quality.Report
+	if hasVMAF() {
+		frames, err := vmafFrames(stats + "_vmaf.json")
+		if err != nil {
+			return nil, err
+		}
+		report.VMAF = quality.Summarize(frames)
+	} else {
+		psnr, err := statsFrames(stats+"_psnr.log", psnrFrame)
+		if err != nil {
+			return nil, err
+		}
+		ssim, err := statsFrames(stats+"_ssim.log", ssimFrame)
+		if err != nil {
+			return nil, err
+		}
+		report.PSNR = quality.Summarize(psnr)
+		report.SSIM = quality.Summarize(ssim)
+	}
+	if report.VMAF == nil && report.PSNR == nil {
+		return nil, fmt.Errorf("no frames were scored")
+	}
+	return &report, nil
+}
+
+// vmafFrames reads the per-frame scores of a libvmaf JSON log.
+func vmafFrames(path string) ([]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vmafLog struct {
+		Frames []struct {
+			Metrics struct {
+				VMAF float64 `json:"vmaf"`
+			} `json:"metrics"`
+		} `json:"frames"`
+	}
+	if err := json.Unmarshal(data, &vmafLog); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	values := make([]float64, len(vmafLog.Frames))
+	for i, f := range vmafLog.Frames {
+		values[i] = f.Metrics.VMAF
+	}
+	return values, nil
+}
+
+// statsFrames reads one value per line of a psnr or ssim stats file. Identical frames have an
+// infinite PSNR, which is capped so the mean stays finite.
+func statsFrames(path string, value *regexp.Regexp) ([]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values []float64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := value.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		values = append(values, math.Min(v, maxPSNR))
+	}
+	return values, scanner.Err()
+}
+
+// belowThreshold describes the first measured metric whose mean misses its threshold, or
+// returns "" if the rendition passes.
+func belowThreshold(r *quality.Report, check *contracts.QualityCheck) string {
+	for _, t := range []struct {
+		name  string
+		score *quality.Score
+		min   float64
+	}{
+		{"VMAF", r.VMAF, check.MinVMAF},
+		{"PSNR", r.PSNR, check.MinPSNR},
+		{"SSIM", r.SSIM, check.MinSSIM},
+	} {
+		if t.min > 0 && t.score != nil && t.score.Mean < t.min {
+			return fmt.Sprintf("mean %s %g is below the minimum %g", t.name, t.score.Mean, t.min)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"log"
+)
+
+// priorityOrder lists job priorities from most to least urgent.
+var priorityOrder = []string{"high", "normal", "low"}
+
+// priorityWeights sets the share of FFmpeg slots each priority gets while all queues are busy.
+// With 6:3:1, low priority still receives one slot in ten, so it is never starved.
+var priorityWeights = map[string]int{
+	"high":   6,
+	"normal": 3,
+	"low":    1,
+}
+
+// JobScheduler picks the next job across the priority queues using smooth weighted round-robin.
+type JobScheduler struct {
+	worker  *Worker
+	queues  map[string]<-chan TranscodeJob
+	pending map[string]*TranscodeJob
+	current map[string]int
+}
+
+func NewJobScheduler(worker *Worker, queues map[string]<-chan TranscodeJob) *JobScheduler {
+	return &JobScheduler{
+		worker:  worker,
+		queues:  queues,
+		pending: make(map[string]*TranscodeJob),
+		current: make(map[string]int),
+	}
+}
+
+// Run hands jobs to HandleTranscodeJob, taking a job off a queue only once an FFmpeg slot is
+// free, until ctx is cancelled. Jobs already handed over run to completion.
+func (s *JobScheduler) Run(ctx context.Context) {
+	for {
+		select {
+		case s.worker.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		job, ok := s.next(ctx)
+		if !ok {
+			<-s.worker.slots
+			return
+		}
+		log.Printf("🚦 [Job %s] FFmpeg slot acquired for %s (priority=%s)", job.JobID, job.Representation, job.Priority)
+
+		go func(job TranscodeJob) {
+			defer func() {
+				<-s.worker.slots
+				log.Printf("🔓 [Job %s] FFmpeg slot released.", job.JobID)
+			}()
+			s.worker.HandleTranscodeJob(job)
+		}(job)
+	}
+}
+
+// next blocks until at least one queue has a job, then picks among the ready priorities by
+// weight. It returns false if ctx is cancelled first.
+func (s *JobScheduler) next(ctx context.Context) (TranscodeJob, bool) {
+	s.fillPending()
+	if len(s.pending) == 0 {
+		if !s.waitForAny(ctx) {
+			return TranscodeJob{}, false
+		}
+		s.fillPending()
+	}
+
+	total := 0
+	best := ""
+	for _, p := range priorityOrder {
+		if s.pending[p] == nil {
+			continue
+		}
+		s.current[p] += priorityWeights[p]
+		total += priorityWeights[p]
+		if best == "" || s.current[p] > s.current[best] {
+			best = p
+		}
+	}
+	s.current[best] -= total
+
+	job := *s.pending[best]
+	delete(s.pending, best)
+	return job, true
+}
+
+// fillPending takes at most one job from each queue without blocking.
+func (s *JobScheduler) fillPending() {
+	for _, p := range priorityOrder {
+		if s.pending[p] != nil {
+			continue
+		}
+		select {
+		case job := <-s.queues[p]:
+			s.pending[p] = &job
+		default:
+		}
+	}
+}
+
+// waitForAny blocks until one of the queues delivers a job, or returns false once ctx is cancelled.
+func (s *JobScheduler) waitForAny(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case job := <-s.queues["high"]:
+		s.pending["high"] = &job
+	case job := <-s.queues["normal"]:
+		s.pending["normal"] = &job
+	case job := <-s.queues["low"]:
+		s.pending["low"] = &job
+	}
+	return true
+}
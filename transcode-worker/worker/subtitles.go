@@ -0,0 +1,219 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+	"common/failure"
+	"common/subtitle"
+	"transcode-worker/cue"
+)
+
+// bitmapSubtitleCodecs are subtitle streams that are pictures, not text: they cannot become
+// WebVTT or TTML and are skipped when extracting embedded subtitles.
+var bitmapSubtitleCodecs = map[string]bool{
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"hdmv_pgs_subtitle": true,
+	"xsub":              true,
+}
+
+// HandleSubtitles converts a job's sidecar files and embedded text subtitle streams into one
+// track per language in the job's directory, next to its manifest. The caller already holds one
+// of the worker's FFmpeg slots.
+func (w *Worker) HandleSubtitles(job contracts.SubtitleJob) {
+	if w.tracker.IsJobCancelled(job.JobID) {
+		log.Printf("🛑 [Job %s] Job was cancelled. Skipping subtitles.", job.JobID)
+		return
+	}
+	w.tracker.MarkSubtitlesProcessing(job.JobID)
+
+	localInput, err := DownloadInput(job.InputURL, job.JobID, "subtitles")
+	if err != nil {
+		log.Printf("❌ [Job %s] Download failed: %v", job.JobID, err)
+		w.failSubtitles(job.JobID, downloadFailure(err))
+		return
+	}
+	defer os.Remove(localInput)
+
+	set, err := convertSubtitles(job, localInput)
+	if err != nil {
+		f := asFailure(err, failure.StageSubtitles)
+		log.Printf("❌ [Job %s] Subtitles failed: %v", job.JobID, f)
+		w.failSubtitles(job.JobID, f)
+		return
+	}
+	log.Printf("💬 [Job %s] %d %s subtitle tracks", job.JobID, len(set.Tracks), set.Format)
+	w.tracker.CompleteSubtitles(job.JobID, set)
+}
+
+// failSubtitles fails the job too: a manifest missing a requested language is not delivered.
+func (w *Worker) failSubtitles(jobID string, f failure.Failure) {
+	w.tracker.MarkSubtitlesFailed(jobID, f)
+	w.tracker.MarkJobFailed(jobID, f)
+}
+
+// convertSubtitles writes every sidecar, then every embedded text stream, as a WebVTT file, or
+// as TTML for stpp, which MP4Box can only segment from TTML.
+func convertSubtitles(job contracts.SubtitleJob, input string) (subtitle.Set, error) {
+	set := subtitle.Set{Format: job.Subtitles.Format}
+
+	duration, err := ProbeDuration(input)
+	if err != nil || duration <= 0 {
+		return set, failure.New(failure.StageProbe, failure.ReasonCorruptInput, fmt.Errorf("input duration: %v", err))
+	}
+	jobDir := filepath.Join(outputDir, job.JobID)
+	if err := os.MkdirAll(filepath.Join(jobDir, subtitle.Dir), 0755); err != nil {
+		return set, err
+	}
+
+	write := func(cues []cue.Cue, lang, label, source string) error {
+		data, ext := cue.WebVTT(cues), "vtt"
+		if set.Format == contracts.SubtitleSTPP {
+			data, ext = cue.TTML(cues, lang), "ttml"
+		}
+		track := subtitle.Track{
+			Path:      path.Join(subtitle.Dir, fmt.Sprintf("%d_%s.%s", len(set.Tracks), lang, ext)),
+			Language:  lang,
+			Label:     label,
+			Source:    source,
+			Bandwidth: int(math.Ceil(float64(len(data)*8) / duration)),
+		}
+		if err := os.WriteFile(filepath.Join(jobDir, track.Path), data, 0644); err != nil {
+			return err
+		}
+		set.Tracks = append(set.Tracks, track)
+		return nil
+	}
+
+	for i, sc := range job.Subtitles.Sidecars {
+		cues, err := fetchSidecar(job.JobID, i, sc)
+		if err != nil {
+			return set, err
+		}
+		if err := write(cues, sc.Language, sc.Label, subtitle.SourceSidecar); err != nil {
+			return set, err
+		}
+	}
+
+	if job.Subtitles.Embedded {
+		streams, err := probeTextSubtitles(input)
+		if err != nil {
+			return set, err
+		}
+		if len(streams) == 0 {
+			log.Printf("⚠️ [Job %s] Input has no text subtitle streams to extract", job.JobID)
+		}
+		for _, s := range streams {
+			cues, err := extractSubtitleStream(job.JobID, input, s)
+			if err != nil {
+				return set, err
+			}
+			if err := write(cues, s.language, "", subtitle.SourceEmbedded); err != nil {
+				return set, err
+			}
+		}
+	}
+
+	if len(set.Tracks) == 0 {
+		return set, failure.New(failure.StageSubtitles, failure.ReasonCorruptInput, fmt.Errorf("no subtitle tracks found"))
+	}
+	return set, nil
+}
+
+// fetchSidecar downloads and parses one sidecar file.
+func fetchSidecar(jobID string, i int, sc contracts.SubtitleSidecar) ([]cue.Cue, error) {
+	local, err := DownloadInput(sc.URL, jobID, fmt.Sprintf("subtitle_%d", i))
+	if err != nil {
+		return nil, downloadFailure(fmt.Errorf("sidecar %d (%s): %w", i, sc.Language, err))
+	}
+	defer os.Remove(local)
+
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return nil, err
+	}
+	cues, err := cue.Parse(data)
+	if err != nil {
+		return nil, failure.New(failure.StageSubtitles, failure.ReasonCorruptInput, fmt.Errorf("sidecar %d (%s): %w", i, sc.Language, err))
+	}
+	return cues, nil
+}
+
+// subtitleStream is one text subtitle stream of the input.
+type subtitleStream struct {
+	index    int
+	codec    string
+	language string
+}
+
+// probeTextSubtitles lists the input's subtitle streams that can be converted to text, in order.
+// Streams without a language tag are "und".
+func probeTextSubtitles(input string) ([]subtitleStream, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "s",
+		"-show_entries", "stream=index,codec_name:stream_tags=language",
+		"-of", "csv=p=0",
+		input,
+	).Output()
+	if err != nil {
+		return nil, failure.New(failure.StageProbe, failure.ReasonCorruptInput, fmt.Errorf("ffprobe failed: %w", err))
+	}
+
+	var streams []subtitleStream
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 2 {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		s := subtitleStream{index: index, codec: fields[1], language: "und"}
+		if len(fields) > 2 && fields[2] != "" {
+			s.language = fields[2]
+		}
+		if bitmapSubtitleCodecs[s.codec] {
+			log.Printf("⚠️ Skipping bitmap subtitle stream %d (%s, %s)", s.index, s.codec, s.language)
+			continue
+		}
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+// extractSubtitleStream has FFmpeg convert one embedded stream to WebVTT, which is then parsed
+// like a sidecar.
+func extractSubtitleStream(jobID, input string, s subtitleStream) ([]cue.Cue, error) {
+	out := filepath.Join(outputDir, fmt.Sprintf("%s_subtitle_stream_%d.vtt", jobID, s.index))
+	defer os.Remove(out)
+
+	cmd := exec.Command("ffmpeg",
+		"-i", input,
+		"-map", fmt.Sprintf("0:%d", s.index),
+		"-c:s", "webvtt", "-f", "webvtt", "-y", out)
+	log.Printf("💬 [Job %s] Extracting subtitle stream %d (%s): %s", jobID, s.index, s.language, strings.Join(cmd.Args, " "))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, failure.FromCommand(failure.StageSubtitles, err, output)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return nil, err
+	}
+	cues, err := cue.Parse(data)
+	if err != nil {
+		return nil, failure.New(failure.StageSubtitles, failure.ReasonCorruptInput, fmt.Errorf("stream %d (%s): %w", s.index, s.language, err))
+	}
+	return cues, nil
+}
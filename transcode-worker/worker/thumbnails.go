@@ -0,0 +1,221 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+	"common/failure"
+	"common/thumbnail"
+)
+
+// posterOffset is where an automatic poster is taken, or a tenth of the way into shorter inputs:
+// past any slate or fade-in, but still on the opening shot.
+const posterOffset = 5.0
+
+// blackScanSeconds is how much of the input is searched for black frames when choosing a poster.
+const blackScanSeconds = 60
+
+var blackInterval = regexp.MustCompile(`black_start:(\S+) black_end:(\S+)`)
+
+// HandleThumbnails generates a job's poster, interval thumbnails, sprite sheets and thumbnail
+// track in the job's directory, next to its manifest. The caller already holds one of the
+// worker's FFmpeg slots.
+func (w *Worker) HandleThumbnails(job contracts.ThumbnailJob) {
+	if w.tracker.IsJobCancelled(job.JobID) {
+		log.Printf("🛑 [Job %s] Job was cancelled. Skipping thumbnails.", job.JobID)
+		return
+	}
+	w.tracker.MarkThumbnailsProcessing(job.JobID)
+
+	localInput, err := DownloadInput(job.InputURL, job.JobID, "thumbnails")
+	if err != nil {
+		log.Printf("❌ [Job %s] Download failed: %v", job.JobID, err)
+		w.failThumbnails(job.JobID, downloadFailure(err))
+		return
+	}
+	defer os.Remove(localInput)
+
+	set, err := generateThumbnails(job, localInput)
+	if err != nil {
+		f := asFailure(err, failure.StageThumbnails)
+		log.Printf("❌ [Job %s] Thumbnails failed: %v", job.JobID, f)
+		w.failThumbnails(job.JobID, f)
+		return
+	}
+	log.Printf("🖼️ [Job %s] %d thumbnails on %d sprite sheets, poster %s", job.JobID, set.Count, set.Sheets, set.Poster)
+	w.tracker.CompleteThumbnails(job.JobID, set)
+}
+
+// failThumbnails fails the job too: the player cannot be given a manifest without them.
+func (w *Worker) failThumbnails(jobID string, f failure.Failure) {
+	w.tracker.MarkThumbnailsFailed(jobID, f)
+	w.tracker.MarkJobFailed(jobID, f)
+}
+
+// generateThumbnails writes the poster, one thumbnail every interval, the sprite sheets tiled
+// from them and the WebVTT track indexing the tiles. Only the sheets, the poster and the track
+// are kept; the single thumbnails are an intermediate step.
+func generateThumbnails(job contracts.ThumbnailJob, input string) (thumbnail.Set, error) {
+	opts := job.Thumbnails
+	set := thumbnail.Set{
+		Poster:   path.Join(thumbnail.Dir, "poster.jpg"),
+		Track:    path.Join(thumbnail.Dir, "thumbnails.vtt"),
+		Sprites:  path.Join(thumbnail.Dir, "sprite_$Number$.jpg"),
+		Interval: opts.Interval,
+		Columns:  opts.Columns,
+		Rows:     opts.Rows,
+	}
+
+	duration, err := ProbeDuration(input)
+	if err != nil {
+		return set, failure.New(failure.StageProbe, failure.ReasonCorruptInput, err)
+	}
+	jobDir := filepath.Join(outputDir, job.JobID)
+	dir := filepath.Join(jobDir, thumbnail.Dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return set, err
+	}
+
+	posterAt, err := posterTime(input, opts.PosterAt, duration)
+	if err != nil {
+		return set, err
+	}
+	err = runThumbnailStep(job.JobID, "poster",
+		"-ss", strconv.FormatFloat(posterAt, 'f', 3, 64), "-i", input,
+		"-frames:v", "1", "-q:v", "2", "-y", filepath.Join(jobDir, set.Poster))
+	if err != nil {
+		return set, err
+	}
+
+	thumbs := filepath.Join(dir, "thumb_%04d.jpg")
+	err = runThumbnailStep(job.JobID, "thumbnails",
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=1/%g,scale=%d:-2", opts.Interval, opts.Width),
+		"-q:v", "5", "-y", thumbs)
+	if err != nil {
+		return set, err
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "thumb_*.jpg"))
+	defer func() {
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}()
+	// The fps filter may emit one frame past the last interval, which the sheets must not show
+	set.Count = int(math.Min(float64(len(files)), math.Ceil(duration/opts.Interval)))
+	for _, extra := range files[set.Count:] {
+		os.Remove(extra)
+	}
+	if set.Count == 0 {
+		return set, failure.New(failure.StageThumbnails, failure.ReasonCorruptInput, fmt.Errorf("no thumbnails extracted from %.3fs of input", duration))
+	}
+	if set.Width, set.Height, err = probeImageSize(files[0]); err != nil {
+		return set, err
+	}
+
+	err = runThumbnailStep(job.JobID, "sprite sheets",
+		"-framerate", "1", "-i", thumbs,
+		"-vf", fmt.Sprintf("tile=%dx%d", opts.Columns, opts.Rows),
+		"-q:v", "5", "-y", filepath.Join(jobDir, strings.ReplaceAll(set.Sprites, "$Number$", "%d")))
+	if err != nil {
+		return set, err
+	}
+	perSheet := opts.Columns * opts.Rows
+	set.Sheets = (set.Count + perSheet - 1) / perSheet
+
+	if err := os.WriteFile(filepath.Join(jobDir, set.Track), []byte(set.WebVTT(duration)), 0644); err != nil {
+		return set, err
+	}
+	return set, nil
+}
+
+// posterTime returns when the poster is taken: at the requested time, or at posterOffset moved
+// past any black frames there, so a fade-in or slate does not become the poster.
+func posterTime(input string, requested *float64, duration float64) (float64, error) {
+	if requested != nil {
+		if *requested >= duration {
+			return 0, failure.New(failure.StageThumbnails, failure.ReasonInvalidArguments,
+				fmt.Errorf("poster_at %gs is past the end of the %.3fs input", *requested, duration))
+		}
+		return *requested, nil
+	}
+
+	at := math.Min(posterOffset, duration/10)
+	black, err := detectBlack(input)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range black {
+		if at >= b[0] && at < b[1] {
+			at = b[1] + 0.5 // into the shot, past the end of the fade
+		}
+	}
+	if at >= duration {
+		at = math.Min(posterOffset, duration/10) // black to the end: any frame will do
+	}
+	return at, nil
+}
+
+// detectBlack returns the start and end of every black stretch in the first blackScanSeconds of
+// the input, in order.
+func detectBlack(input string) ([][2]float64, error) {
+	cmd := exec.Command("ffmpeg",
+		"-t", strconv.Itoa(blackScanSeconds), "-i", input,
+		"-vf", "blackdetect=d=0.1:pix_th=0.10",
+		"-an", "-f", "null", "-")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, failure.FromCommand(failure.StageThumbnails, err, out)
+	}
+
+	var black [][2]float64
+	for _, m := range blackInterval.FindAllStringSubmatch(string(out), -1) {
+		start, err1 := strconv.ParseFloat(m[1], 64)
+		end, err2 := strconv.ParseFloat(m[2], 64)
+		if err1 == nil && err2 == nil {
+			black = append(black, [2]float64{start, end})
+		}
+	}
+	return black, nil
+}
+
+// probeImageSize returns the width and height of an image.
+func probeImageSize(file string) (int, int, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=p=0:s=x",
+		file,
+	).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	size := strings.TrimSpace(string(out))
+	w, h, _ := strings.Cut(size, "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("invalid image size %q", size)
+	}
+	return width, height, nil
+}
+
+// runThumbnailStep runs one step and classifies its failure.
+func runThumbnailStep(jobID, step string, args ...string) error {
+	cmd := exec.Command("ffmpeg", args...)
+	log.Printf("🖼️ [Job %s] Generating %s: %s", jobID, step, strings.Join(cmd.Args, " "))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return failure.FromCommand(failure.StageThumbnails, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"mpd-generator/mpdgen"
+	"tracker/tracker"
+	"transcode-worker/worker"
+	"transcoding-controller/controller"
+)
+
+// runDev starts every service in this process: the in-memory bus replaces Kafka, an embedded
+// Redis-compatible server replaces Redis, SQLite holds the job store and a static file server
+// replaces nginx. Only ffmpeg, ffprobe and MP4Box need to be installed.
+func runDev(args []string) {
+	fs := flag.NewFlagSet("dev", flag.ExitOnError)
+	workers := fs.Int("workers", 2, "number of transcode workers")
+	dataDir := fs.String("data", ".transcoder-dev", "directory for the SQLite database and segments")
+	apiAddr := fs.String("api", ":8080", "controller API address")
+	trackerAddr := fs.String("tracker", ":9000", "tracker API address")
+	segmentsAddr := fs.String("segments", ":8081", "segments file server address (nginx in docker compose)")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	p, err := startPipeline(ctx, *dataDir, "http://localhost"+*segmentsAddr)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer p.Close()
+
+	fail := make(chan error, 3)
+	go func() { fail <- controller.Run(ctx, *apiAddr) }()
+	go func() { fail <- tracker.Run(ctx, *trackerAddr) }()
+	go func() { fail <- mpdgen.Run(ctx) }()
+	for i := 1; i <= *workers; i++ {
+		go worker.New(fmt.Sprintf("dev-worker-%d", i)).Run(ctx)
+	}
+	go func() { fail <- serveSegments(ctx, *segmentsAddr, p.segmentsDir) }()
+
+	log.Printf("🧪 Dev mode: API %s, tracker %s, segments %s (%s), %d workers, Redis %s",
+		*apiAddr, *trackerAddr, *segmentsAddr, p.segmentsDir, *workers, p.redis.Addr())
+
+	select {
+	case <-ctx.Done():
+		log.Println("🛑 Shutting down")
+	case err := <-fail:
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+}
+
+// serveSegments stands in for nginx: it serves the segments directory with the same CORS headers
+// so a browser player can load the manifest.
+func serveSegments(ctx context.Context, addr, dir string) error {
+	files := http.FileServer(http.Dir(dir))
+	server := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Range")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range")
+		if r.Method == http.MethodOptions {
+			return
+		}
+		files.ServeHTTP(w, r)
+	})}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,812 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"common/contracts"
+	"common/failure"
+	"common/quality"
+
+	"transcode-worker/worker"
+	"transcoding-controller/controller"
+)
+
+// scenario is one end-to-end run through the pipeline, with env set before the services are
+// initialized. It returns the first expectation that did not hold.
+type scenario struct {
+	name string
+	run  func(h *harness) error
+	env  map[string]string
+}
+
+var scenarios = []scenario{
+	{"happy path", scenarioHappyPath, nil},
+	{"failure", scenarioFailure, nil},
+	{"partial failure", scenarioPartialFailure, nil},
+	{"retry", scenarioRetry, nil},
+	{"two-pass", scenarioTwoPass, nil},
+	{"per-title ladder", scenarioAutoLadder, nil},
+	{"quality check", scenarioQualityCheck, nil},
+	{"chunked encode", scenarioChunked, nil},
+	{"keyframe alignment", scenarioKeyframeAlignment, nil},
+	{"thumbnails", scenarioThumbnails, nil},
+	{"subtitles", scenarioSubtitles, nil},
+	{"edit list", scenarioEditList, nil},
+	{"cancellation", scenarioCancellation, nil},
+	{"batch queue", scenarioBatchQueue, map[string]string{"TENANT_MAX_ACTIVE_JOBS": "2"}},
+}
+
+// TestE2E runs each scenario against its own pipeline, with fake ffmpeg, ffprobe and MP4Box
+// binaries, so none depends on what another left behind. It needs nothing but Go and a POSIX
+// shell; -short skips it.
+func TestE2E(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end scenarios skipped in short mode")
+	}
+	if err := installFakeTools(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			for k, v := range s.env {
+				t.Setenv(k, v)
+			}
+			h := startHarness(t, 2, 30*time.Second)
+			if err := s.run(h); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// scenarioHappyPath encodes two representations and expects a done job with a manifest that
+// lists both, a representation row per rendition and the job's full history.
+func scenarioHappyPath(h *harness) error {
+	h.startWorkers()
+
+	jobID, err := h.submit(h.request(inputOK, "360p", "720p"))
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+
+	if want := "http://segments.e2e/" + jobID + "/manifest.mpd"; detail.Job.MPDURL != want {
+		return fmt.Errorf("mpd_url = %q, want %q", detail.Job.MPDURL, want)
+	}
+	if detail.Job.Failure != nil {
+		return fmt.Errorf("done job has failure %+v", detail.Job.Failure)
+	}
+	if detail.Job.QualityProfile != contracts.QualityBalanced {
+		return fmt.Errorf("quality_profile = %q, want the %q default", detail.Job.QualityProfile, contracts.QualityBalanced)
+	}
+	if err := expectRepresentations(detail, "done", 1, "360p", "720p"); err != nil {
+		return err
+	}
+	for _, rep := range detail.Representations {
+		if rep.OutputSize == 0 || rep.WorkerID == "" || rep.StartedAt == nil || rep.FinishedAt == nil {
+			return fmt.Errorf("representation %s is missing its output, worker or timings: %+v", rep.Representation, rep)
+		}
+	}
+
+	if err := h.expectManifest(jobID, "360p", "720p"); err != nil {
+		return err
+	}
+
+	events, err := h.history(jobID)
+	if err != nil {
+		return err
+	}
+	return expectJobStates(events, "waiting", "processing", "done", "ready_for_mpd", "done")
+}
+
+// scenarioFailure feeds a corrupt input and expects the classified ffmpeg failure on both the
+// job and the representation, and no manifest.
+func scenarioFailure(h *harness) error {
+	h.startWorkers()
+
+	jobID, err := h.submit(h.request(inputCorrupt, "360p"))
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		return d.Job.Failure != nil && len(d.Representations) == 1 && d.Representations[0].Status == "failed"
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := expectFailure("job", detail.Job.Failure, failure.StageTranscode, failure.ReasonCorruptInput); err != nil {
+		return err
+	}
+	rep := detail.Representations[0]
+	if err := expectFailure("representation", rep.Failure, failure.StageTranscode, failure.ReasonCorruptInput); err != nil {
+		return err
+	}
+	if rep.Attempt != 1 {
+		return fmt.Errorf("attempt = %d, want 1", rep.Attempt)
+	}
+	return h.expectNoManifest(jobID)
+}
+
+// scenarioPartialFailure encodes more representations than the workers have slots, one of which
+// fails straight away while the others take a while. The job must stay failed: representations
+// that start after the failure are skipped, and those still encoding finish without reopening it.
+func scenarioPartialFailure(h *harness) error {
+	h.startWorkers()
+
+	reps := []string{"240p", "144p", "360p", "480p", "720p", "1080p"}
+	jobID, err := h.submit(h.request(inputPartial, reps...))
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		if len(d.Representations) != len(reps) {
+			return false
+		}
+		for _, rep := range d.Representations {
+			if rep.Status == "queued" || rep.Status == "processing" {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := expectFailure("job", detail.Job.Failure, failure.StageTranscode, failure.ReasonCorruptInput); err != nil {
+		return err
+	}
+	skipped := 0
+	for _, rep := range detail.Representations {
+		switch {
+		case rep.Representation == "240p" && rep.Status != "failed":
+			return fmt.Errorf("240p is %s, want failed", rep.Status)
+		case rep.Representation != "240p" && rep.Status == "cancelled":
+			skipped++
+		case rep.Representation != "240p" && rep.Status != "done":
+			return fmt.Errorf("%s is %s, want done or cancelled", rep.Representation, rep.Status)
+		}
+	}
+	if slots := h.workers * worker.MaxConcurrentFFmpeg; skipped < len(reps)-slots {
+		return fmt.Errorf("%d representations were skipped, want at least the %d that had to wait for a slot", skipped, len(reps)-slots)
+	}
+
+	events, err := h.history(jobID)
+	if err != nil {
+		return err
+	}
+	// Concurrent workers may publish their events out of order, but each event's from_state is
+	// read in the same atomic transition that wrote its to_state.
+	for _, ev := range events {
+		if ev.Representation == "" && ev.FromState == "failed" {
+			return fmt.Errorf("job moved from failed to %s (%s)", ev.ToState, ev.Service)
+		}
+	}
+	return h.expectNoManifest(jobID)
+}
+
+// scenarioRetry fails the first attempt, redelivers the representation's job as a retry would,
+// and expects the second attempt to finish and package the job.
+func scenarioRetry(h *harness) error {
+	h.startWorkers()
+
+	jobID, err := h.submit(h.request(inputFlaky, "360p"))
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		return len(d.Representations) == 1 && d.Representations[0].Status == "failed"
+	})
+	if err != nil {
+		return err
+	}
+
+	rep := detail.Representations[0]
+	if err := h.redeliver(detail.Job, rep); err != nil {
+		return err
+	}
+
+	detail, err = h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectRepresentations(detail, "done", 2, "360p"); err != nil {
+		return err
+	}
+	if detail.Representations[0].Failure != nil {
+		return fmt.Errorf("retried representation kept the first attempt's failure %+v", detail.Representations[0].Failure)
+	}
+	return h.expectManifest(jobID, "360p")
+}
+
+// scenarioTwoPass runs a two-pass encode and expects both passes to run and their statistics
+// to be cleaned up.
+func scenarioTwoPass(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputOK, "360p")
+	req.RateControl = contracts.RateControlTwoPass
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectRepresentations(detail, "done", 1, "360p"); err != nil {
+		return err
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(h.pipeline.segmentsDir, jobID+"_*passlog*"))
+	if len(logs) > 0 {
+		return fmt.Errorf("two-pass statistics left behind: %v", logs)
+	}
+	return h.expectManifest(jobID, "360p")
+}
+
+// scenarioAutoLadder lets a worker choose the ladder. The fake probes level off in quality below
+// 720p and only pass TransparentPSNR at 720p, so 1080p is left out and every chosen rung gets the
+// bitrate where its resolution stops being the best.
+func scenarioAutoLadder(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputOK)
+	req.Ladder = controller.LadderAuto
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+
+	// Ordered by name, as GET /jobs/{id} returns them
+	want := map[string]string{"240p": "130k", "360p": "300k", "480p": "740k", "720p": "2580k"}
+	if err := expectRepresentations(detail, "done", 1, "240p", "360p", "480p", "720p"); err != nil {
+		return err
+	}
+	for _, rep := range detail.Representations {
+		if rep.Bitrate != want[rep.Representation] {
+			return fmt.Errorf("%s bitrate = %s, want %s", rep.Representation, rep.Bitrate, want[rep.Representation])
+		}
+	}
+	if detail.Job.Representations != "240p,360p,480p,720p" {
+		return fmt.Errorf("job representations = %q, want the chosen rungs", detail.Job.Representations)
+	}
+
+	probes, _ := filepath.Glob(filepath.Join(h.pipeline.segmentsDir, jobID+"_probe_*"))
+	if len(probes) > 0 {
+		return fmt.Errorf("probe encodes left behind: %v", probes)
+	}
+
+	events, err := h.history(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectJobStates(events, "waiting", "analyzing", "waiting", "processing", "done"); err != nil {
+		return err
+	}
+	return h.expectManifest(jobID, "240p", "360p", "480p", "720p")
+}
+
+// scenarioQualityCheck measures a rendition twice: against a minimum PSNR it meets, and one it
+// misses. The fake ffmpeg has no libvmaf, so both runs fall back to PSNR and SSIM.
+func scenarioQualityCheck(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputOK, "360p")
+	req.QualityCheck = &contracts.QualityCheck{MinPSNR: 40}
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectRepresentations(detail, "done", 1, "360p"); err != nil {
+		return err
+	}
+	q := detail.Representations[0].Quality
+	if q == nil || q.PSNR == nil || q.SSIM == nil {
+		return fmt.Errorf("quality = %+v, want PSNR and SSIM scores", q)
+	}
+	if *q.PSNR != (quality.Score{Mean: 42, Min: 40, P5: 40}) {
+		return fmt.Errorf("PSNR = %+v, want mean 42, min 40, p5 40", *q.PSNR)
+	}
+	if q.VMAF != nil {
+		return fmt.Errorf("VMAF = %+v without libvmaf", *q.VMAF)
+	}
+	if err := h.expectManifest(jobID, "360p"); err != nil {
+		return err
+	}
+
+	req.QualityCheck = &contracts.QualityCheck{MinPSNR: 45}
+	jobID, err = h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err = h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		return d.Job.Failure != nil && len(d.Representations) == 1 && d.Representations[0].Status == "failed"
+	})
+	if err != nil {
+		return err
+	}
+	if err := expectFailure("job", detail.Job.Failure, failure.StageQuality, failure.ReasonBelowThreshold); err != nil {
+		return err
+	}
+	rep := detail.Representations[0]
+	if err := expectFailure("representation", rep.Failure, failure.StageQuality, failure.ReasonBelowThreshold); err != nil {
+		return err
+	}
+	if rep.Quality == nil || rep.Quality.PSNR == nil {
+		return fmt.Errorf("failed representation has no scores")
+	}
+	return h.expectNoManifest(jobID)
+}
+
+// scenarioChunked cuts a 60s input into 20s chunks on the 2s keyframe grid, and expects every
+// representation to be stitched from all three chunks and the chunks to be cleaned up.
+func scenarioChunked(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputLong, "360p", "720p")
+	req.ChunkSeconds = 20
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectRepresentations(detail, "done", 1, "360p", "720p"); err != nil {
+		return err
+	}
+	for _, rep := range detail.Representations {
+		out, err := os.ReadFile(rep.OutputPath)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(out), "fake stitch") || !strings.Contains(string(out), "duration 60\n") {
+			return fmt.Errorf("%s output is not the 60s stitch of its chunks: %q", rep.Representation, out)
+		}
+	}
+
+	chunks, _ := filepath.Glob(filepath.Join(h.pipeline.segmentsDir, jobID+"_*chunk*"))
+	if len(chunks) > 0 {
+		return fmt.Errorf("chunks left behind: %v", chunks)
+	}
+	// Downloaded once, by the split, and encoded in place by every chunk
+	inputs, _ := filepath.Glob(filepath.Join(h.pipeline.segmentsDir, jobID+"_*input*"))
+	if len(inputs) != 1 || !strings.HasSuffix(inputs[0], "_split_input.mp4") {
+		return fmt.Errorf("want only the split's input, found %v", inputs)
+	}
+
+	events, err := h.history(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectJobStates(events, "waiting", "splitting", "waiting", "processing", "done"); err != nil {
+		return err
+	}
+	return h.expectManifest(jobID, "360p", "720p")
+}
+
+// scenarioKeyframeAlignment leaves the GOP to the controller, which derives 48 frames (2s) from
+// the input's 24 fps and the 4s segments, then packages a job whose renditions place their
+// keyframes differently and expects it to fail instead.
+func scenarioKeyframeAlignment(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputOK, "360p", "720p")
+	req.GopSize, req.KeyintMin = 0, 0
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	for _, rep := range detail.Representations {
+		out, err := os.ReadFile(rep.OutputPath)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(out), "keyint 2\n") {
+			return fmt.Errorf("%s was not encoded with the default 2s GOP: %q", rep.Representation, out)
+		}
+	}
+	if err := h.expectManifest(jobID, "360p", "720p"); err != nil {
+		return err
+	}
+
+	jobID, err = h.submit(h.request(inputDrift, "360p", "720p"))
+	if err != nil {
+		return err
+	}
+	detail, err = h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		return d.Job.Failure != nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := expectFailure("job", detail.Job.Failure, failure.StagePackage, failure.ReasonKeyframesMisaligned); err != nil {
+		return err
+	}
+	if err := expectRepresentations(detail, "done", 1, "360p", "720p"); err != nil {
+		return err
+	}
+	return h.expectNoManifest(jobID)
+}
+
+// scenarioThumbnails asks for a thumbnail every 5s of a 60s input on 3x2 sprite sheets, and
+// expects the poster past the input's fade-in, two sheets indexed by the WebVTT track, a
+// thumbnail AdaptationSet in the manifest and the URLs on the job. A poster requested past the
+// end of the input fails the job.
+func scenarioThumbnails(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputLong, "360p")
+	req.Thumbnails = &contracts.Thumbnails{Interval: 5, Columns: 3, Rows: 2}
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	public := "http://segments.e2e/" + jobID + "/thumbnails/"
+	if detail.Job.PosterURL != public+"poster.jpg" || detail.Job.ThumbnailsURL != public+"thumbnails.vtt" {
+		return fmt.Errorf("poster_url %q and thumbnails_url %q, want them under %s", detail.Job.PosterURL, detail.Job.ThumbnailsURL, public)
+	}
+
+	dir := filepath.Join(h.pipeline.segmentsDir, jobID, "thumbnails")
+	poster, err := os.ReadFile(filepath.Join(dir, "poster.jpg"))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(poster), "frame at 6.000 ") {
+		return fmt.Errorf("poster was not taken just past the fade-in: %q", poster)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	if want := []string{"poster.jpg", "sprite_1.jpg", "sprite_2.jpg", "thumbnails.vtt"}; strings.Join(files, " ") != strings.Join(want, " ") {
+		return fmt.Errorf("thumbnail files %v, want %v", files, want)
+	}
+	track, err := os.ReadFile(filepath.Join(dir, "thumbnails.vtt"))
+	if err != nil {
+		return err
+	}
+	if cues := strings.Count(string(track), " --> "); cues != 12 {
+		return fmt.Errorf("thumbnail track has %d cues, want 12", cues)
+	}
+	if !strings.HasSuffix(string(track), "\n00:00:55.000 --> 00:01:00.000\nsprite_2.jpg#xywh=320,90,160,90\n") {
+		return fmt.Errorf("last cue is not the last tile of the second sheet:\n%s", track)
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(h.pipeline.segmentsDir, jobID, "manifest.mpd"))
+	if err != nil {
+		return err
+	}
+	for _, want := range []string{`contentType="image"`, `media="thumbnails/sprite_$Number$.jpg"`, `duration="30000"`, `value="3x2"`} {
+		if !strings.Contains(string(manifest), want) {
+			return fmt.Errorf("manifest has no thumbnail AdaptationSet with %s:\n%s", want, manifest)
+		}
+	}
+	if err := h.expectManifest(jobID, "360p"); err != nil {
+		return err
+	}
+
+	late := 90.0
+	req = h.request(inputOK, "360p")
+	req.Thumbnails = &contracts.Thumbnails{PosterAt: &late}
+	jobID, err = h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err = h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		return d.Job.Failure != nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := expectFailure("job", detail.Job.Failure, failure.StageThumbnails, failure.ReasonInvalidArguments); err != nil {
+		return err
+	}
+	return h.expectNoManifest(jobID)
+}
+
+// scenarioSubtitles converts an SRT and a TTML sidecar and the input's text subtitle stream to
+// side-loaded WebVTT, and expects one text AdaptationSet per track added to the manifest; the
+// bitmap stream is skipped. The same SRT as stpp is converted to TTML and handed to MP4Box. A
+// sidecar language that is not a BCP 47 tag is rejected.
+func scenarioSubtitles(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputCaption, "360p")
+	req.Subtitles = &contracts.Subtitles{
+		Sidecars: []contracts.SubtitleSidecar{
+			{URL: h.inputs.URL + "/en.srt", Language: "en", Label: "English"},
+			{URL: h.inputs.URL + "/fr.ttml", Language: "fr"},
+		},
+		Embedded: true,
+	}
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	if _, err := h.waitForPackaged(jobID); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(h.pipeline.segmentsDir, jobID)
+	want := map[string]string{
+		"subtitles/0_en.vtt":  "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nHello\n\n00:00:03.000 --> 00:00:04.000\nTwo\nlines\n",
+		"subtitles/1_fr.vtt":  "WEBVTT\n\n00:00:03.000 --> 00:00:04.500\nBonjour\nle monde\n",
+		"subtitles/2_eng.vtt": "WEBVTT\n\n00:00:01.000 --> 00:00:03.000\nembedded stream 2\n",
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "subtitles", "*"))
+	if len(files) != len(want) {
+		return fmt.Errorf("subtitle files %v, want %d", files, len(want))
+	}
+	for track, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, track))
+		if err != nil {
+			return err
+		}
+		if string(data) != content {
+			return fmt.Errorf("%s is\n%s\nwant\n%s", track, data, content)
+		}
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, "manifest.mpd"))
+	if err != nil {
+		return err
+	}
+	for _, want := range []string{
+		`<AdaptationSet contentType="text" mimeType="text/vtt" lang="en"><Label>English</Label>`,
+		`<BaseURL>subtitles/1_fr.vtt</BaseURL>`,
+		`lang="eng"`,
+		`value="subtitle"`,
+	} {
+		if !strings.Contains(string(manifest), want) {
+			return fmt.Errorf("manifest has no %s:\n%s", want, manifest)
+		}
+	}
+	if err := h.expectManifest(jobID, "360p"); err != nil {
+		return err
+	}
+
+	req = h.request(inputOK, "360p")
+	req.Subtitles = &contracts.Subtitles{
+		Sidecars: []contracts.SubtitleSidecar{{URL: h.inputs.URL + "/en.srt", Language: "en"}},
+		Format:   contracts.SubtitleSTPP,
+	}
+	jobID, err = h.submit(req)
+	if err != nil {
+		return err
+	}
+	if _, err := h.waitForPackaged(jobID); err != nil {
+		return err
+	}
+	dir = filepath.Join(h.pipeline.segmentsDir, jobID)
+	ttml, err := os.ReadFile(filepath.Join(dir, "subtitles", "0_en.ttml"))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(ttml), `<p begin="00:00:03.000" end="00:00:04.000">Two<br/>lines</p>`) {
+		return fmt.Errorf("TTML track does not have the second cue:\n%s", ttml)
+	}
+	manifest, err = os.ReadFile(filepath.Join(dir, "manifest.mpd"))
+	if err != nil {
+		return err
+	}
+	if want := `<AdaptationSet contentType="text" lang="en"><Representation id="0_en.ttml">`; !strings.Contains(string(manifest), want) {
+		return fmt.Errorf("manifest has no packaged TTML track %s:\n%s", want, manifest)
+	}
+
+	req.Subtitles = &contracts.Subtitles{
+		Sidecars: []contracts.SubtitleSidecar{{URL: h.inputs.URL + "/en.srt", Language: "English"}},
+	}
+	return h.call(http.MethodPost, "/transcode", req, http.StatusBadRequest, nil)
+}
+
+// scenarioEditList renders three clips of two inputs, 16.5s in all, and expects its duration on
+// the job, thumbnails made from the rendered edit rather than the source, and the rendered file
+// removed once the job is packaged. A clip past the end of its input fails the job, and a clip
+// ending before it starts is rejected.
+func scenarioEditList(h *harness) error {
+	h.startWorkers()
+
+	req := h.request(inputLong, "360p", "720p")
+	req.Edits = []contracts.Edit{
+		{In: 12.5, Out: 20},
+		{InputURL: h.inputs.URL + "/" + inputOK + ".mp4", Out: 4},
+		{In: 55},
+	}
+	req.Thumbnails = &contracts.Thumbnails{Interval: 5}
+	jobID, err := h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err := h.waitForPackaged(jobID)
+	if err != nil {
+		return err
+	}
+	if detail.Job.Duration != 16.5 {
+		return fmt.Errorf("job duration %gs, want the 16.5s of its clips", detail.Job.Duration)
+	}
+	if err := expectRepresentations(detail, "done", 1, "360p", "720p"); err != nil {
+		return err
+	}
+	track, err := os.ReadFile(filepath.Join(h.pipeline.segmentsDir, jobID, "thumbnails", "thumbnails.vtt"))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(track), "\n00:00:15.000 --> 00:00:16.500\n") {
+		return fmt.Errorf("thumbnail track does not end with the edit:\n%s", track)
+	}
+	if edited, _ := filepath.Glob(filepath.Join(h.pipeline.segmentsDir, jobID+"_edit*")); len(edited) > 0 {
+		return fmt.Errorf("edited input left behind: %v", edited)
+	}
+
+	events, err := h.history(jobID)
+	if err != nil {
+		return err
+	}
+	if err := expectJobStates(events, "waiting", "editing", "waiting", "processing", "done"); err != nil {
+		return err
+	}
+	if err := h.expectManifest(jobID, "360p", "720p"); err != nil {
+		return err
+	}
+
+	req = h.request(inputOK, "360p")
+	req.Edits = []contracts.Edit{{In: 5, Out: 30}}
+	jobID, err = h.submit(req)
+	if err != nil {
+		return err
+	}
+	detail, err = h.waitForJob(jobID, "failed", func(d *controller.JobDetail) bool {
+		return d.Job.Failure != nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := expectFailure("job", detail.Job.Failure, failure.StageEdit, failure.ReasonInvalidArguments); err != nil {
+		return err
+	}
+	if err := h.expectNoManifest(jobID); err != nil {
+		return err
+	}
+
+	req.Edits = []contracts.Edit{{In: 8, Out: 3}}
+	return h.call(http.MethodPost, "/transcode", req, http.StatusBadRequest, nil)
+}
+
+// scenarioCancellation cancels a batch before starting the workers, and expects them to skip every representation instead of encoding it.
+func scenarioCancellation(h *harness) error {
+	var batch struct {
+		BatchID string   `json:"batch_id"`
+		JobIDs  []string `json:"job_ids"`
+	}
+	req := controller.BatchRequest{Jobs: []controller.TranscodeRequest{h.request(inputOK, "360p", "720p")}}
+	if err := h.call(http.MethodPost, "/batches", req, http.StatusAccepted, &batch); err != nil {
+		return err
+	}
+	if len(batch.JobIDs) != 1 {
+		return fmt.Errorf("batch returned %d job IDs, want 1", len(batch.JobIDs))
+	}
+	jobID := batch.JobIDs[0]
+
+	if err := h.call(http.MethodPost, "/batches/"+batch.BatchID+"/cancel", nil, http.StatusOK, nil); err != nil {
+		return err
+	}
+	h.startWorkers()
+
+	if _, err := h.waitForJob(jobID, "cancelled", nil); err != nil {
+		return err
+	}
+	err := h.waitFor("both representations skipped", func() (bool, error) {
+		events, err := h.history(jobID)
+		if err != nil {
+			return false, err
+		}
+		skipped := 0
+		for _, ev := range events {
+			if ev.Representation != "" && ev.Service == "transcode-worker" && ev.ToState == "cancelled" {
+				skipped++
+			}
+		}
+		return skipped == 2, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	detail, err := h.job(jobID)
+	if err != nil {
+		return err
+	}
+	for _, rep := range detail.Representations {
+		if rep.Attempt != 0 || rep.Status == "processing" || rep.Status == "done" {
+			return fmt.Errorf("cancelled representation %s was encoded: %+v", rep.Representation, rep)
+		}
+	}
+	for _, rep := range []string{"360p", "720p"} {
+		if _, err := os.Stat(filepath.Join(h.pipeline.segmentsDir, jobID+"_"+rep+".mp4")); err == nil {
+			return fmt.Errorf("cancelled representation %s has an output file", rep)
+		}
+	}
+	return h.expectNoManifest(jobID)
+}
+
+// scenarioBatchQueue submits a batch of more jobs than the tenant may have active. Only as many
+// as the quota allows are dispatched; the rest wait in the batch's queue and are dispatched as
+// the first ones finish, until the whole batch completes.
+func scenarioBatchQueue(h *harness) error {
+	var batch struct {
+		BatchID string   `json:"batch_id"`
+		JobIDs  []string `json:"job_ids"`
+	}
+	req := controller.BatchRequest{}
+	for i := 0; i < 5; i++ {
+		req.Jobs = append(req.Jobs, h.request(inputOK, "360p"))
+	}
+	if err := h.call(http.MethodPost, "/batches", req, http.StatusAccepted, &batch); err != nil {
+		return err
+	}
+	if len(batch.JobIDs) != len(req.Jobs) {
+		return fmt.Errorf("batch returned %d job IDs, want %d", len(batch.JobIDs), len(req.Jobs))
+	}
+
+	// Without workers nothing finishes, so only the first two may have been dispatched
+	for _, jobID := range batch.JobIDs[:2] {
+		if _, err := h.waitForJob(jobID, "waiting", func(d *controller.JobDetail) bool {
+			return len(d.Representations) == 1
+		}); err != nil {
+			return err
+		}
+	}
+	for _, jobID := range batch.JobIDs[2:] {
+		detail, err := h.job(jobID)
+		if err != nil {
+			return err
+		}
+		if detail.Job.Status != "waiting" || len(detail.Representations) != 0 {
+			return fmt.Errorf("job %s beyond the active job quota was dispatched: %s with %d representations",
+				jobID, detail.Job.Status, len(detail.Representations))
+		}
+	}
+
+	h.startWorkers()
+	for _, jobID := range batch.JobIDs {
+		if _, err := h.waitForPackaged(jobID); err != nil {
+			return err
+		}
+	}
+	return h.waitFor("batch "+batch.BatchID+" to complete", func() (bool, error) {
+		var summary controller.BatchSummary
+		if err := h.call(http.MethodGet, "/batches/"+batch.BatchID, nil, http.StatusOK, &summary); err != nil {
+			return false, err
+		}
+		return summary.Status == "completed" && summary.Counts["done"] == len(batch.JobIDs), nil
+	})
+}
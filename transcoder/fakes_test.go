@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Inputs served by the e2e harness. Their contents tell the fake ffmpeg how to behave.
+const (
+	inputOK      = "ok"
+	inputCorrupt = "corrupt"
+	inputFlaky   = "flaky"
+	inputLong    = "long"
+	inputDrift   = "drifting"
+	inputCaption = "captioned"
+	inputPartial = "partial"
+)
+
+// fakeTools stand in for the encoder and packager binaries the worker and mpd-generator exec.
+var fakeTools = map[string]string{
+	// ffmpeg writes a small file at the output path (its last argument). An input containing
+	// "corrupt" fails like a damaged upload; "flaky" fails the first attempt at each output;
+	// "partial" fails its 240p encode straight away and takes half a second over the others.
+	// Pass 1 of a two-pass encode writes its statistics file, which pass 2 requires.
+	// Ladder probes (-crf) write "<crf> <height>" padded to a size that grows with the frame size
+	// and falls with the CRF; the PSNR run reads it back and reports a quality that falls with
+	// the CRF and is capped by the height. The build has no libvmaf, so quality checks write
+	// three frames of PSNR (44, 40, 42 dB) and SSIM to their stats files. A chunk encode (-t)
+	// records its duration, and a stitch (-f concat) records the sum of its chunks' durations.
+	// Encodes record their keyframe interval: -g frames at 24 fps, 10s without -g, and one that
+	// grows with the height for the "drifting" input, whose renditions never line up.
+	// Every input fades in from black over its first 5.5s. A single frame (-frames:v 1) records
+	// its -ss, interval thumbnails write one more file than the input has intervals, as the fps
+	// filter can, and tiling writes one sprite sheet per columns x rows thumbnails. Extracting a
+	// subtitle stream (-c:s webvtt) writes a one-cue WebVTT file naming the stream. Rendering an
+	// edit list (-filter_complex concat) records the sum of its clips' durations, each from its
+	// -ss to its -t or to the end of its input.
+	"ffmpeg": `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -filters) exit 0 ;;
+    -i) [ -z "$input" ] && input="$2"; clips="$clips ${clipss:-0},$clipt,$2"; clipss=""; clipt=""; shift ;;
+    -pass) pass="$2"; shift ;;
+    -passlogfile) passlog="$2"; shift ;;
+    -crf) crf="$2"; shift ;;
+    -vf) vf="$2"; shift ;;
+    -lavfi) lavfi="$2"; shift ;;
+    -t) t="$2"; clipt="$2"; shift ;;
+    -g) g="$2"; shift ;;
+    -f) [ "$2" = concat ] && concat=1; shift ;;
+    -ss) ss="$2"; clipss="$2"; shift ;;
+    -filter_complex) fc="$2"; shift ;;
+    -frames:v) frames="$2"; shift ;;
+    -map) map="$2"; shift ;;
+    -c:s) cs="$2"; shift ;;
+  esac
+  out="$1"
+  shift
+done
+case "$lavfi" in
+*stats_file=*)
+  psnrlog=$(echo "$lavfi" | sed 's/.*psnr=stats_file=\([^;]*\).*/\1/')
+  ssimlog=$(echo "$lavfi" | sed 's/.*ssim=stats_file=\([^;]*\).*/\1/')
+  n=0
+  for q in 44 40 42; do
+    n=$((n + 1))
+    echo "n:$n mse_avg:2.50 mse_y:2.50 psnr_avg:$q.00 psnr_y:$q.00" >> "$psnrlog"
+  done
+  n=0
+  for q in 0.97 0.95 0.96; do
+    n=$((n + 1))
+    echo "n:$n Y:$q U:$q V:$q All:$q (15.0)" >> "$ssimlog"
+  done
+  exit 0 ;;
+*psnr*)
+  read crf h < "$input"
+  q=$((60 - crf / 2)); cap=$((30 + h / 40))
+  [ $q -gt $cap ] && q=$cap
+  echo "[Parsed_psnr_2 @ 0x0] PSNR y:$q.000000 u:$q.000000 v:$q.000000 average:$q.000000 min:$q.000000 max:$q.000000" >&2
+  exit 0 ;;
+esac
+case "$vf" in
+blackdetect*)
+  echo "[blackdetect @ 0x0] black_start:0 black_end:5.5 black_duration:5.5" >&2
+  exit 0 ;;
+fps=*)
+  i=${vf#fps=1/}; i=${i%%,*}
+  d=$(sed -n 's/^duration //p' "$input")
+  [ "$(head -n 1 "$input")" = ` + inputLong + ` ] && d=60
+  d=${d:-10}
+  n=$(awk -v d="$d" -v i="$i" 'BEGIN { n = d / i; if (n > int(n)) n = int(n) + 1; print n + 1 }')
+  for k in $(seq 1 "$n"); do echo "thumbnail $k" > "$(printf "$out" "$k")"; done
+  exit 0 ;;
+tile=*)
+  size=${vf#tile=}; per=$((${size%x*} * ${size#*x}))
+  n=$(ls "$(dirname "$input")" | grep -c '^thumb_')
+  for k in $(seq 1 $(((n + per - 1) / per))); do echo "sprite sheet $k" > "$(printf "$out" "$k")"; done
+  exit 0 ;;
+esac
+case "$fc" in
+*concat=*)
+  total=0
+  for c in $clips; do
+    in=${c%%,*}; rest=${c#*,}; len=${rest%%,*}; f=${rest#*,}
+    if [ -z "$len" ]; then
+      d=10; [ "$(head -n 1 "$f")" = ` + inputLong + ` ] && d=60
+      len=$(awk -v d="$d" -v i="$in" 'BEGIN { print d - i }')
+    fi
+    total=$(awk -v a="$total" -v b="$len" 'BEGIN { print a + b }')
+  done
+  printf 'fake edit of%s\nduration %s\n' "$clips" "$total" > "$out"
+  exit 0 ;;
+esac
+if [ "$cs" = webvtt ]; then
+  printf 'WEBVTT\n\n00:00:01.000 --> 00:00:03.000\nembedded stream %s\n' "${map#0:}" > "$out"
+  exit 0
+fi
+if [ "$frames" = 1 ]; then
+  echo "frame at $ss of $input" > "$out"
+  exit 0
+fi
+if [ -n "$concat" ]; then
+  total=0
+  for f in $(sed "s/^file '\\(.*\\)'$/\\1/" "$input"); do
+    total=$(awk -v a="$total" -v b="$(sed -n 's/^duration //p' "$f")" 'BEGIN { print a + b }')
+  done
+  printf 'fake stitch of %s\nduration %s\n' "$input" "$total" > "$out"
+  exit 0
+fi
+case "$pass" in
+1) echo "fake stats" > "$passlog-0.log"; exit 0 ;;
+2) [ -e "$passlog-0.log" ] || { echo "$passlog-0.log: No such file or directory" >&2; exit 1; } ;;
+esac
+case "$(cat "$input")" in
+` + inputCorrupt + `)
+  echo "$input: Invalid data found when processing input" >&2
+  exit 1 ;;
+` + inputPartial + `)
+  if [ "$vf" = scale=426x240 ]; then
+    echo "$input: Invalid data found when processing input" >&2
+    exit 1
+  fi
+  sleep 0.5 ;;
+` + inputFlaky + `)
+  if [ ! -e "$out.attempted" ]; then
+    : > "$out.attempted"
+    echo "Conversion failed!" >&2
+    exit 1
+  fi ;;
+esac
+if [ -n "$crf" ]; then
+  size=${vf#scale=}; w=${size%x*}; h=${size#*x}
+  echo "$crf $h" > "$out"
+  head -c $((w * h * (51 - crf) / 8)) /dev/zero >> "$out"
+else
+  echo "fake encode of $input" > "$out"
+  if [ -n "$t" ]; then echo "duration $t" >> "$out"; fi
+  keyint=10
+  if [ -n "$g" ]; then keyint=$(awk -v g="$g" 'BEGIN { print g / 24 }'); fi
+  if [ "$(cat "$input")" = ` + inputDrift + ` ]; then
+    size=${vf#scale=}
+    keyint=$(awk -v h="${size#*x}" 'BEGIN { print h / 300 }')
+  fi
+  echo "keyint $keyint" >> "$out"
+fi
+`,
+
+	// ffprobe reports a duration of 10s, 60s for the "long" input, or the duration an encoded
+	// chunk or stitch recorded. Everything runs at 24 fps with a frame every 0.5s and a keyframe
+	// every 2s, or at the interval an encode recorded. Images are 160x90. The "captioned" input
+	// has an English SubRip stream and a French bitmap one; the others have no subtitles. Every
+	// input has an audio stream but the "long" one.
+	"ffprobe": `#!/bin/sh
+for f; do :; done
+d=$(sed -n 's/^duration //p' "$f" 2>/dev/null)
+[ "$(head -n 1 "$f" 2>/dev/null)" = ` + inputLong + ` ] && d=60
+d=${d:-10}
+k=$(sed -n 's/^keyint //p' "$f" 2>/dev/null)
+k=${k:-2}
+case "$*" in
+*r_frame_rate*) echo 24/1 ;;
+*stream=width,height*) echo 160x90 ;;
+*"stream=index -of"*) [ "$(head -n 1 "$f")" = ` + inputLong + ` ] || echo 1 ;;
+*stream=index,codec_name*)
+  if [ "$(head -n 1 "$f")" = ` + inputCaption + ` ]; then
+    echo 2,subrip,eng
+    echo 3,hdmv_pgs_subtitle,fre
+  fi ;;
+*packet=pts_time,flags*) awk -v d="$d" -v k="$k" 'BEGIN { for (t = 0; t < d; t += k) printf "%.6f,K__\n", t }' ;;
+*packet=dts_time*) awk -v d="$d" 'BEGIN { for (t = 0; t < d; t += 0.5) printf "%.6f\n", t }' ;;
+*) awk -v d="$d" 'BEGIN { printf "%.6f\n", d }' ;;
+esac
+`,
+
+	// MP4Box writes a manifest with one Representation per input file at its -out path. Subtitle
+	// inputs (.vtt or .ttml, with :lang= and :role= options) each get a text AdaptationSet.
+	"MP4Box": `#!/bin/sh
+inputs=""
+texts=""
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -out) out="$2"; shift ;;
+    -dash|-profile) shift ;;
+    -*) ;;
+    *.vtt:*|*.ttml:*) texts="$texts $1" ;;
+    *) inputs="$inputs $1" ;;
+  esac
+  shift
+done
+{
+  echo '<?xml version="1.0"?>'
+  echo '<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static"><Period><AdaptationSet>'
+  for f in $inputs; do
+    echo "<Representation id=\"$(basename "$f" .mp4)\"><BaseURL>$(basename "$f")</BaseURL></Representation>"
+  done
+  echo '</AdaptationSet>'
+  for t in $texts; do
+    f=${t%%:*}; lang=${t#*:lang=}; lang=${lang%%:*}
+    echo "<AdaptationSet contentType=\"text\" lang=\"$lang\"><Representation id=\"$(basename "$f")\"><BaseURL>$(basename "$f")</BaseURL></Representation></AdaptationSet>"
+  done
+  echo '</Period></MPD>'
+} > "$out"
+`,
+}
+
+// installFakeTools writes the fake binaries into dir and puts dir first on PATH.
+func installFakeTools(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, script := range fakeTools {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			return fmt.Errorf("install fake %s: %w", name, err)
+		}
+	}
+	return os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"common/contracts"
+	"common/failure"
+	"common/jobstore"
+
+	"mpd-generator/mpdgen"
+	"tracker/tracker"
+	"transcode-worker/worker"
+	"transcoding-controller/controller"
+)
+
+var keep = flag.Bool("keep", false, "keep each scenario's data directory (database and segments) for inspection")
+
+// harness drives the services through their public API and the bus, as a client and Kafka would.
+type harness struct {
+	ctx      context.Context
+	pipeline *pipeline
+	api      *httptest.Server
+	inputs   *httptest.Server
+	timeout  time.Duration
+	workers  int
+}
+
+// startHarness starts a fresh pipeline and every service but the workers, which the scenario
+// starts with startWorkers. The fake tools must already be on PATH. Everything is shut down when
+// t finishes, and the data directory removed unless -keep is set.
+func startHarness(t *testing.T, workers int, timeout time.Duration) *harness {
+	t.Helper()
+
+	dataDir := t.TempDir()
+	if *keep {
+		var err error
+		if dataDir, err = os.MkdirTemp("", "transcoder-e2e-"); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("🗂️ Keeping %s", dataDir)
+	}
+	// Jobs would otherwise wait up to 5s between every tracker step, and queued batch jobs up to 10s
+	for name, interval := range map[string]string{"TRACKER_POLL_INTERVAL": "100ms", "BATCH_POLL_INTERVAL": "100ms"} {
+		if os.Getenv(name) == "" {
+			t.Setenv(name, interval)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := startPipeline(ctx, dataDir, "http://segments.e2e")
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+
+	h := &harness{
+		ctx:      ctx,
+		pipeline: p,
+		api:      httptest.NewServer(controller.Handler()),
+		inputs:   httptest.NewServer(http.HandlerFunc(serveInput)),
+		timeout:  timeout,
+		workers:  workers,
+	}
+	t.Cleanup(func() {
+		h.api.Close()
+		h.inputs.Close()
+		cancel()
+		p.Close()
+	})
+
+	go controller.ConsumeLadders(ctx)
+	go controller.ConsumeChunkPlans(ctx)
+	go controller.ConsumeEditResults(ctx)
+	go controller.WatchBatches(ctx)
+	go tracker.Run(ctx, "127.0.0.1:0")
+	go mpdgen.Run(ctx)
+	return h
+}
+
+// Subtitle sidecars served by the e2e harness: SubRip with Windows line endings and a font tag,
+// and TTML timed in ticks and clock times relative to its <div>.
+const (
+	sidecarSRT = "1\r\n00:00:01,000 --> 00:00:02,500\r\n<font color=\"#ffffff\">Hello</font>\r\n\r\n" +
+		"2\r\n00:00:03,000 --> 00:00:04,000\r\nTwo\r\nlines\r\n"
+	sidecarTTML = `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:tickRate="10000000" xml:lang="fr">
+<body><div begin="2s">
+<p begin="10000000t" end="00:00:02.500">Bonjour<br/>
+  le   monde</p>
+</div></body></tt>
+`
+)
+
+// serveInput serves /<name>.mp4 with the body <name>, which the fake ffmpeg acts on, and the
+// subtitle sidecars at /en.srt and /fr.ttml.
+func serveInput(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/en.srt":
+		fmt.Fprint(w, sidecarSRT)
+		return
+	case "/fr.ttml":
+		fmt.Fprint(w, sidecarTTML)
+		return
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".mp4")
+	switch name {
+	case inputOK, inputCorrupt, inputFlaky, inputLong, inputDrift, inputCaption, inputPartial:
+		fmt.Fprint(w, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// startWorkers starts the scenario's transcode workers. Scenarios start them once they have
+// set up whatever must happen before any job is picked up.
+func (h *harness) startWorkers() {
+	for i := 1; i <= h.workers; i++ {
+		go worker.New(fmt.Sprintf("e2e-worker-%d", i)).Run(h.ctx)
+	}
+}
+
+func (h *harness) request(input string, resolutions ...string) controller.TranscodeRequest {
+	return controller.TranscodeRequest{
+		StreamName:  "e2e-" + input,
+		InputURL:    h.inputs.URL + "/" + input + ".mp4",
+		Resolutions: resolutions,
+		Codec:       "h264",
+		GopSize:     48,
+		KeyintMin:   48,
+	}
+}
+
+// submit posts a job to the controller and returns its ID.
+func (h *harness) submit(req controller.TranscodeRequest) (string, error) {
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	if err := h.call(http.MethodPost, "/transcode", req, http.StatusAccepted, &resp); err != nil {
+		return "", err
+	}
+	return resp.JobID, nil
+}
+
+// redeliver publishes the representation's transcode job again, rebuilt from its database row,
+// the way Kafka redelivers an uncommitted message.
+func (h *harness) redeliver(job jobstore.Job, rep jobstore.Representation) error {
+	payload, err := contracts.Encode(&contracts.TranscodeJob{
+		JobID:          job.JobID,
+		InputURL:       job.InputURL,
+		Representation: rep.Representation,
+		Resolution:     rep.Resolution,
+		Bitrate:        rep.Bitrate,
+		Codec:          job.Codec,
+		QualityProfile: job.QualityProfile,
+		GopSize:        48,
+		KeyintMin:      48,
+	})
+	if err != nil {
+		return err
+	}
+	return h.pipeline.bus.Publish(h.ctx, contracts.TopicJobs, []byte(job.JobID), payload)
+}
+
+func (h *harness) job(jobID string) (*controller.JobDetail, error) {
+	var detail controller.JobDetail
+	if err := h.call(http.MethodGet, "/jobs/"+jobID, nil, http.StatusOK, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (h *harness) history(jobID string) ([]jobstore.JobEvent, error) {
+	var history controller.JobHistory
+	if err := h.call(http.MethodGet, "/jobs/"+jobID+"/history", nil, http.StatusOK, &history); err != nil {
+		return nil, err
+	}
+	return history.Events, nil
+}
+
+// waitForJob polls GET /jobs/{id} until the job has status and, if given, ready also holds.
+func (h *harness) waitForJob(jobID, status string, ready func(*controller.JobDetail) bool) (*controller.JobDetail, error) {
+	var detail *controller.JobDetail
+	err := h.waitFor("job "+jobID+" to be "+status, func() (bool, error) {
+		var err error
+		detail, err = h.job(jobID)
+		if err != nil {
+			return false, err
+		}
+		return detail.Job.Status == status && (ready == nil || ready(detail)), nil
+	})
+	return detail, err
+}
+
+// waitForPackaged waits until the mpd-generator has published the job's manifest and the
+// tracker has synced the final state.
+func (h *harness) waitForPackaged(jobID string) (*controller.JobDetail, error) {
+	err := h.waitFor("job "+jobID+" to be packaged", func() (bool, error) {
+		events, err := h.history(jobID)
+		if err != nil {
+			return false, err
+		}
+		for _, ev := range events {
+			if ev.Service == "mpd-generator" && ev.ToState == "done" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.waitForJob(jobID, "done", func(d *controller.JobDetail) bool {
+		return d.Job.MPDURL != ""
+	})
+}
+
+// waitFor polls cond until it holds, fails or the scenario timeout passes.
+func (h *harness) waitFor(what string, cond func() (bool, error)) error {
+	deadline := time.Now().Add(h.timeout)
+	for {
+		ok, err := cond()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s", h.timeout, what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// call sends a JSON request to the controller and decodes the response into out, if not nil.
+func (h *harness) call(method, path string, body interface{}, wantStatus int, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, h.api.URL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("%s %s: status %d, want %d: %s", method, path, resp.StatusCode, wantStatus, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// expectManifest checks that the job's manifest has exactly one video representation per
+// rendition.
+func (h *harness) expectManifest(jobID string, reps ...string) error {
+	path := filepath.Join(h.pipeline.segmentsDir, jobID, "manifest.mpd")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+
+	var mpd struct {
+		AdaptationSets []struct {
+			ContentType     string `xml:"contentType,attr"`
+			Representations []struct {
+				ID      string `xml:"id,attr"`
+				BaseURL string `xml:"BaseURL"`
+			} `xml:"Representation"`
+		} `xml:"Period>AdaptationSet"`
+	}
+	if err := xml.Unmarshal(data, &mpd); err != nil {
+		return fmt.Errorf("parse manifest: %w", err)
+	}
+	var videos []string
+	for _, set := range mpd.AdaptationSets {
+		if set.ContentType != "" && set.ContentType != "video" {
+			continue
+		}
+		for _, rep := range set.Representations {
+			videos = append(videos, rep.BaseURL)
+		}
+	}
+	if len(videos) != len(reps) {
+		return fmt.Errorf("manifest has %d representations, want %d", len(videos), len(reps))
+	}
+	for i, rep := range reps {
+		if want := jobID + "_" + rep + ".mp4"; videos[i] != want {
+			return fmt.Errorf("manifest representation %d is %q, want %q", i, videos[i], want)
+		}
+	}
+	return nil
+}
+
+func (h *harness) expectNoManifest(jobID string) error {
+	if _, err := os.Stat(filepath.Join(h.pipeline.segmentsDir, jobID, "manifest.mpd")); err == nil {
+		return fmt.Errorf("job %s has a manifest", jobID)
+	}
+	return nil
+}
+
+// expectRepresentations checks that the job has exactly the named representation rows, all with
+// status and attempt.
+func expectRepresentations(detail *controller.JobDetail, status string, attempt int, names ...string) error {
+	if len(detail.Representations) != len(names) {
+		return fmt.Errorf("job has %d representation rows, want %d", len(detail.Representations), len(names))
+	}
+	// GET /jobs/{id} returns them ordered by name
+	for i, rep := range detail.Representations {
+		if rep.Representation != names[i] || rep.Status != status || rep.Attempt != attempt {
+			return fmt.Errorf("representation %d is %s/%s attempt %d, want %s/%s attempt %d",
+				i, rep.Representation, rep.Status, rep.Attempt, names[i], status, attempt)
+		}
+	}
+	return nil
+}
+
+func expectFailure(what string, f *failure.Failure, stage, reason string) error {
+	if f == nil {
+		return fmt.Errorf("%s has no failure recorded", what)
+	}
+	if f.Stage != stage || f.Reason != reason {
+		return fmt.Errorf("%s failure is %s/%s, want %s/%s", what, f.Stage, f.Reason, stage, reason)
+	}
+	return nil
+}
+
+// expectJobStates checks that the job-level transitions in the history include want, in order.
+func expectJobStates(events []jobstore.JobEvent, want ...string) error {
+	var got []string
+	for _, ev := range events {
+		if ev.Representation == "" {
+			got = append(got, ev.ToState)
+		}
+	}
+
+	i := 0
+	for _, state := range got {
+		if i < len(want) && state == want[i] {
+			i++
+		}
+	}
+	if i < len(want) {
+		return fmt.Errorf("job history %v does not contain the transitions %v", got, want)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Command transcoder bundles the services into one binary. `transcoder dev` runs the whole
+// pipeline in a single process for local development; its tests run end-to-end scenarios
+// against the same pipeline with fake encoder and packager binaries.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: transcoder <command> [flags]
+
+Commands:
+  dev    run the controller, workers, tracker and mpd-generator in one process`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "dev":
+		runDev(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
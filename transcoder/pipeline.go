@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"common/bus"
+
+	"mpd-generator/mpdgen"
+	"tracker/tracker"
+	"transcode-worker/worker"
+	"transcoding-controller/controller"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// pipeline is the infrastructure the services share when they run in one process: an embedded
+// Redis-compatible server, the in-memory bus, a SQLite job store and a segments directory.
+type pipeline struct {
+	redis       *miniredis.Miniredis
+	bus         *bus.Memory
+	segmentsDir string
+}
+
+// startPipeline starts the embedded infrastructure with its data under dataDir, points the
+// services' environment at it and initializes every service. The Redis clock runs until ctx is
+// cancelled.
+func startPipeline(ctx context.Context, dataDir, publicHost string) (*pipeline, error) {
+	segmentsDir, err := filepath.Abs(filepath.Join(dataDir, "segments"))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(segmentsDir, 0755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", segmentsDir, err)
+	}
+
+	redisServer, err := miniredis.Run()
+	if err != nil {
+		return nil, fmt.Errorf("start embedded Redis: %w", err)
+	}
+
+	// The services read their configuration from the environment, exactly as in their containers
+	os.Setenv("REDIS_ADDR", redisServer.Addr())
+	os.Setenv("DB_DRIVER", "sqlite")
+	os.Setenv("SQLITE_DB_PATH", filepath.Join(dataDir, "jobs.db"))
+	os.Setenv("SEGMENTS_DIR", segmentsDir)
+	if os.Getenv("PUBLIC_HOST") == "" {
+		os.Setenv("PUBLIC_HOST", publicHost)
+	}
+
+	// miniredis only expires keys when its clock is advanced
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				redisServer.FastForward(time.Second)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	p := &pipeline{redis: redisServer, bus: bus.NewMemory(), segmentsDir: segmentsDir}
+
+	// The tracker owns the schema, so it must migrate before the others wait for it
+	tracker.Init(p.bus)
+	controller.Init(p.bus)
+	worker.Init(p.bus)
+	mpdgen.Init(p.bus)
+	return p, nil
+}
+
+// Close stops the bus and the embedded Redis.
+func (p *pipeline) Close() {
+	p.bus.Close()
+	p.redis.Close()
+}
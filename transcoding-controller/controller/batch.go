@@ -0,0 +1,468 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"common/contracts"
+	"common/failure"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// BatchRequest is the body of POST /batches. Fields left empty on a job are taken from Defaults.
+type BatchRequest struct {
+	Defaults   TranscodeRequest   `json:"defaults"`
+	Jobs       []TranscodeRequest `json:"jobs"`
+	WebhookURL string             `json:"webhook_url"`
+}
+
+// batchPollInterval is how often WatchBatches dispatches queued jobs and finalizes batches.
+var batchPollInterval = 10 * time.Second
+
+// InitBatches reads BATCH_POLL_INTERVAL (a Go duration, default 10s).
+func InitBatches() {
+	if v := os.Getenv("BATCH_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("❌ Invalid BATCH_POLL_INTERVAL %q", v)
+		}
+		batchPollInterval = d
+	}
+}
+
+// BatchSummary is returned by GET /batches/{id} and posted to the batch webhook.
+type BatchSummary struct {
+	Batch
+	Counts map[string]int  `json:"counts"`
+	Jobs   []TranscodedJob `json:"jobs,omitempty"`
+}
+
+var terminalJobStatuses = map[string]bool{
+	"done":      true,
+	"failed":    true,
+	"cancelled": true,
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// applyBatchDefaults fills unset fields of req from the batch defaults.
+func applyBatchDefaults(req *TranscodeRequest, defaults TranscodeRequest) {
+	if req.StreamName == "" {
+		req.StreamName = defaults.StreamName
+	}
+	if req.InputURL == "" {
+		req.InputURL = defaults.InputURL
+	}
+	if len(req.Resolutions) == 0 {
+		req.Resolutions = append([]string(nil), defaults.Resolutions...)
+	}
+	if req.Codec == "" {
+		req.Codec = defaults.Codec
+	}
+	if req.GopSize == 0 {
+		req.GopSize = defaults.GopSize
+	}
+	if req.KeyintMin == 0 {
+		req.KeyintMin = defaults.KeyintMin
+	}
+	if req.Priority == "" {
+		req.Priority = defaults.Priority
+	}
+	if req.RateControl == "" {
+		req.RateControl = defaults.RateControl
+	}
+	if req.QualityProfile == "" {
+		req.QualityProfile = defaults.QualityProfile
+	}
+	if req.Ladder == "" {
+		req.Ladder = defaults.Ladder
+	}
+	if req.QualityCheck == nil {
+		req.QualityCheck = defaults.QualityCheck
+	}
+	if req.ChunkSeconds == 0 {
+		req.ChunkSeconds = defaults.ChunkSeconds
+	}
+	if req.Thumbnails == nil && defaults.Thumbnails != nil {
+		thumbnails := *defaults.Thumbnails
+		req.Thumbnails = &thumbnails
+	}
+	if req.Subtitles == nil && defaults.Subtitles != nil {
+		subtitles := *defaults.Subtitles
+		req.Subtitles = &subtitles
+	}
+	// Copied, since validation fills in each clip's input from the job's own
+	if len(req.Edits) == 0 {
+		req.Edits = append([]contracts.Edit(nil), defaults.Edits...)
+	}
+	// A default CRF only applies along with the default rate control mode
+	if req.CRF == 0 && req.RateControl == defaults.RateControl {
+		req.CRF = defaults.CRF
+	}
+}
+
+// handleBatches serves POST /batches.
+func handleBatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant, ok := tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&req); err != nil {
+		if bodyTooLarge(w, err) {
+			return
+		}
+		log.Printf("❌ JSON decode error: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.WebhookURL != "" && !validInputURL(req.WebhookURL) {
+		http.Error(w, "webhook_url must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	maxJobs := envInt("BATCH_MAX_JOBS", 500)
+	if len(req.Jobs) == 0 || len(req.Jobs) > maxJobs {
+		http.Error(w, fmt.Sprintf("A batch must contain between 1 and %d jobs", maxJobs), http.StatusBadRequest)
+		return
+	}
+
+	// Batches are backfills by default, so they yield to interactive submissions
+	if req.Defaults.Priority == "" {
+		req.Defaults.Priority = "low"
+	}
+
+	jobs := make([]BatchJob, len(req.Jobs))
+	for i := range req.Jobs {
+		applyBatchDefaults(&req.Jobs[i], req.Defaults)
+		if msg := validateTranscodeRequest(&req.Jobs[i]); msg != "" {
+			http.Error(w, fmt.Sprintf("jobs[%d]: %s", i, msg), http.StatusBadRequest)
+			return
+		}
+		jobs[i] = BatchJob{JobID: uuid.New().String(), Request: req.Jobs[i]}
+	}
+
+	// The submission takes one request from the rate limit; each job takes another, and an active
+	// job slot, only once it is dispatched
+	if !checkQuota(w, CheckRateLimit(tenant, 1)) {
+		return
+	}
+	if !checkQuota(w, CheckDailyMinutes(tenant)) {
+		return
+	}
+
+	batch := Batch{BatchID: uuid.New().String(), TenantID: tenant, WebhookURL: req.WebhookURL, Status: "running"}
+	log.Printf("🆕 New batch %s with %d jobs (tenant=%s)", batch.BatchID, len(jobs), tenant)
+
+	if err := InsertBatchToDB(batch.BatchID, tenant, req.WebhookURL, jobs); err != nil {
+		http.Error(w, "Failed to create batch", http.StatusInternalServerError)
+		log.Printf("❌ Failed to create batch %s: %v", batch.BatchID, err)
+		return
+	}
+
+	jobIDs := make([]string, len(jobs))
+	for i := range jobs {
+		job := &jobs[i]
+		jobIDs[i] = job.JobID
+		PublishStatusEvent(job.JobID, "", "waiting", "submitted in batch "+batch.BatchID)
+		applyDefaultGOP(&job.Request)
+		err := StoreJobMetadata(job.JobID, tenant, job.Request)
+		if err == nil {
+			err = queueBatchJob(batch.BatchID, *job)
+		}
+		if err != nil {
+			// The job is never dispatched; failing it lets the batch complete
+			log.Printf("❌ Failed to queue job %s in batch %s: %v", job.JobID, batch.BatchID, err)
+			failUndispatchedJob(job.JobID, tenant, failure.New(failure.StageSubmit, failure.ReasonUnknown, err))
+		}
+	}
+	dispatchQueuedJobs(batch)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch_id": batch.BatchID,
+		"job_ids":  jobIDs,
+		"status":   "submitted",
+	})
+}
+
+// batchQueueKey lists the jobs of a batch that have not been dispatched yet, in order.
+func batchQueueKey(batchID string) string {
+	return jobHashes.Namespaced(fmt.Sprintf("batch:%s:queued", batchID))
+}
+
+func queueBatchJob(batchID string, job BatchJob) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return redisClient.RPush(ctx, batchQueueKey(batchID), raw).Err()
+}
+
+// dispatchQueuedJobs dispatches a batch's queued jobs in order for as long as the tenant's quotas
+// allow, each taking a request from the rate limit and an active job slot like a job submitted on
+// its own. The rest stay queued until WatchBatches tries again. Jobs cancelled while queued are
+// dropped.
+func dispatchQueuedJobs(batch Batch) {
+	key := batchQueueKey(batch.BatchID)
+	for {
+		raw, err := redisClient.LPop(ctx, key).Result()
+		if err == redis.Nil {
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Failed to read the queue of batch %s: %v", batch.BatchID, err)
+			return
+		}
+		var job BatchJob
+		if err := json.Unmarshal([]byte(raw), &job); err != nil {
+			log.Printf("❌ Dropping unreadable job queued in batch %s: %v", batch.BatchID, err)
+			continue
+		}
+
+		status, err := jobHashes.Status(ctx, job.JobID)
+		if err == nil && status == "" {
+			failUndispatchedJob(job.JobID, batch.TenantID, failure.New(failure.StageSubmit, failure.ReasonUnknown,
+				errors.New("job metadata expired while queued")))
+			continue
+		}
+		if err == nil && status != "waiting" {
+			log.Printf("⏭️ Job %s in batch %s is %s, not dispatching it", job.JobID, batch.BatchID, status)
+			continue
+		}
+		if err == nil {
+			err = admitBatchJob(batch.TenantID, job.JobID)
+		}
+		if err != nil {
+			// Back at the head of the queue, to be retried on the next tick
+			redisClient.LPush(ctx, key, raw)
+			if _, ok := err.(*QuotaExceededError); !ok {
+				log.Printf("❌ Failed to dispatch job %s in batch %s: %v", job.JobID, batch.BatchID, err)
+			}
+			return
+		}
+		DispatchRepresentations(job.JobID, batch.TenantID, job.Request)
+	}
+}
+
+// admitBatchJob charges a queued job to the tenant's quotas, leaving nothing charged if any of
+// them is exhausted.
+func admitBatchJob(tenant, jobID string) error {
+	if err := CheckDailyMinutes(tenant); err != nil {
+		return err
+	}
+	if err := ReserveActiveJob(tenant, jobID); err != nil {
+		return err
+	}
+	if err := CheckRateLimit(tenant, 1); err != nil {
+		ReleaseActiveJob(tenant, jobID)
+		return err
+	}
+	return nil
+}
+
+// failUndispatchedJob fails a job the controller could not dispatch and frees its active job slot.
+func failUndispatchedJob(jobID, tenant string, f failure.Failure) {
+	ReleaseActiveJob(tenant, jobID)
+	if err := MarkJobFailedInDB(jobID, f); err != nil {
+		log.Printf("⚠️ Failed to mark job %s failed in DB: %v", jobID, err)
+	}
+	PublishStatusEvent(jobID, "waiting", "failed", f.Error())
+}
+
+// handleBatchByID serves GET /batches/{id} and POST /batches/{id}/cancel.
+func handleBatchByID(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/batches/"), "/")
+	batchID, action, _ := strings.Cut(path, "/")
+
+	batch, err := GetBatch(batchID)
+	if err != nil {
+		http.Error(w, "Failed to fetch batch", http.StatusInternalServerError)
+		log.Printf("❌ Failed to fetch batch %s: %v", batchID, err)
+		return
+	}
+	// Another tenant's batch is reported as missing, so its IDs cannot be probed
+	if batch == nil || batch.TenantID != tenant {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeBatchSummary(w, batch)
+	case action == "cancel" && r.Method == http.MethodPost:
+		cancelBatch(w, batch)
+	case action == "" || action == "cancel":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeBatchSummary(w http.ResponseWriter, batch *Batch) {
+	summary, err := summarizeBatch(*batch, true)
+	if err != nil {
+		http.Error(w, "Failed to fetch batch jobs", http.StatusInternalServerError)
+		log.Printf("❌ Failed to fetch jobs for batch %s: %v", batch.BatchID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+func cancelBatch(w http.ResponseWriter, batch *Batch) {
+	if batch.Status != "running" {
+		http.Error(w, fmt.Sprintf("Batch is already %s", batch.Status), http.StatusConflict)
+		return
+	}
+
+	// Queued jobs are skipped once cancelled anyway; this only drops them early
+	redisClient.Del(ctx, batchQueueKey(batch.BatchID))
+	jobIDs, err := CancelBatchInDB(batch.BatchID)
+	if err != nil {
+		http.Error(w, "Failed to cancel batch", http.StatusInternalServerError)
+		log.Printf("❌ Failed to cancel batch %s: %v", batch.BatchID, err)
+		return
+	}
+	for _, jobID := range jobIDs {
+		MarkJobCancelled(jobID, "batch "+batch.BatchID+" cancelled")
+	}
+	log.Printf("🛑 Cancelled batch %s (%d jobs)", batch.BatchID, len(jobIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch_id":       batch.BatchID,
+		"status":         "cancelled",
+		"cancelled_jobs": len(jobIDs),
+	})
+}
+
+// summarizeBatch counts the batch's jobs by status and derives the batch status from them.
+func summarizeBatch(batch Batch, includeJobs bool) (BatchSummary, error) {
+	jobs, err := GetBatchJobs(batch.BatchID)
+	if err != nil {
+		return BatchSummary{}, err
+	}
+
+	summary := BatchSummary{Batch: batch, Counts: make(map[string]int)}
+	finished := 0
+	for _, job := range jobs {
+		summary.Counts[job.Status]++
+		if terminalJobStatuses[job.Status] {
+			finished++
+		}
+	}
+	if batch.Status == "running" && finished == batch.JobCount {
+		summary.Status = "completed"
+	}
+	if includeJobs {
+		summary.Jobs = jobs
+	}
+	return summary, nil
+}
+
+// WatchBatches periodically dispatches the queued jobs of running batches, finalizes batches
+// whose jobs have all finished and fires their webhooks, until ctx is cancelled.
+func WatchBatches(ctx context.Context) {
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+	for {
+		checkBatches()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func checkBatches() {
+	batches, err := GetUnnotifiedBatches()
+	if err != nil {
+		log.Printf("❌ Failed to list open batches: %v", err)
+		return
+	}
+
+	for _, batch := range batches {
+		if batch.Status == "running" {
+			dispatchQueuedJobs(batch)
+		}
+		summary, err := summarizeBatch(batch, false)
+		if err != nil {
+			log.Printf("❌ Failed to summarize batch %s: %v", batch.BatchID, err)
+			continue
+		}
+
+		finished := 0
+		for status, n := range summary.Counts {
+			if terminalJobStatuses[status] {
+				finished += n
+			}
+		}
+		if finished < batch.JobCount {
+			continue
+		}
+
+		status := batch.Status
+		if status == "running" {
+			status = "completed"
+		}
+		if err := MarkBatchFinished(batch.BatchID, status); err != nil {
+			log.Printf("❌ Failed to mark batch %s finished: %v", batch.BatchID, err)
+			continue
+		}
+		summary.Status = status
+		log.Printf("🏁 Batch %s %s: %v", batch.BatchID, status, summary.Counts)
+
+		if batch.WebhookURL != "" {
+			go sendBatchWebhook(batch.WebhookURL, summary)
+		}
+	}
+}
+
+// sendBatchWebhook posts the batch summary, retrying a few times on failure.
+func sendBatchWebhook(url string, summary BatchSummary) {
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		log.Printf("❌ Failed to marshal webhook for batch %s: %v", summary.BatchID, err)
+		return
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(payload))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				log.Printf("📤 Batch webhook delivered: batch_id=%s", summary.BatchID)
+				return
+			}
+			err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		log.Printf("⚠️ Batch webhook attempt %d failed for batch %s: %v", attempt, summary.BatchID, err)
+		time.Sleep(time.Duration(attempt) * 5 * time.Second)
+	}
+	log.Printf("❌ Giving up on batch webhook for batch %s", summary.BatchID)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"common/bus"
+	"common/contracts"
+	"common/jobhash"
+)
+
+// minChunkSeconds keeps chunks long enough that the encoder's rate control settles and the
+// per-chunk startup cost stays small.
+const minChunkSeconds = 10
+
+// validateChunking checks the chunk length. An automatic ladder's rungs are only known after its
+// analysis, and a quality check scores whole representations, so neither can be combined with
+// chunking yet.
+func validateChunking(req *TranscodeRequest) string {
+	switch {
+	case req.ChunkSeconds == 0:
+		return ""
+	case req.ChunkSeconds < minChunkSeconds:
+		return fmt.Sprintf("chunk_seconds must be at least %d", minChunkSeconds)
+	case req.Ladder == LadderAuto:
+		return "chunk_seconds cannot be combined with ladder auto"
+	case req.QualityCheck != nil:
+		return "chunk_seconds cannot be combined with quality_check"
+	}
+	return ""
+}
+
+// DispatchSplit asks a worker to cut the job's input into chunks.
+func DispatchSplit(jobID string, req TranscodeRequest) {
+	payload, err := contracts.Encode(&contracts.SplitJob{
+		JobID:        jobID,
+		InputURL:     req.InputURL,
+		ChunkSeconds: req.ChunkSeconds,
+		GopSize:      req.GopSize,
+	})
+	if err == nil {
+		err = msgBus.Publish(ctx, contracts.TopicSplit, []byte(jobID), payload)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to publish split for job %s: %v", jobID, err)
+		return
+	}
+	log.Printf("✅ Published split for job %s (chunks of %gs)", jobID, req.ChunkSeconds)
+}
+
+// ConsumeChunkPlans dispatches the chunks of chunked jobs once a worker has cut their input,
+// until ctx is cancelled.
+func ConsumeChunkPlans(ctx context.Context) error {
+	return msgBus.Subscribe(ctx, contracts.TopicChunkPlans, "transcoding-controller", handleChunkPlan)
+}
+
+func handleChunkPlan(m bus.Message) {
+	var msg contracts.ChunkPlan
+	if err := contracts.Decode(m.Value, &msg); err != nil {
+		log.Printf("❌ Rejected chunk plan (%v): %s", err, string(m.Value))
+		return
+	}
+
+	job, err := jobHashes.Get(ctx, msg.JobID)
+	if err == nil && job == nil {
+		err = fmt.Errorf("job hash %s not found", jobHashes.Key(msg.JobID))
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read job %s for its chunks: %v", msg.JobID, err)
+		return
+	}
+	if job.Status == "cancelled" {
+		log.Printf("🛑 Job %s was cancelled during the split, not dispatching its chunks", msg.JobID)
+		return
+	}
+	if dispatched, err := jobHashes.Field(ctx, msg.JobID, jobhash.FieldChunks); err != nil || dispatched != "" {
+		log.Printf("⚠️ Job %s chunks already dispatched, ignoring redelivered plan", msg.JobID)
+		return
+	}
+
+	raw, err := jobHashes.Field(ctx, msg.JobID, jobhash.FieldRequest)
+	var req TranscodeRequest
+	if err == nil {
+		err = json.Unmarshal([]byte(raw), &req)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read the request of job %s: %v", msg.JobID, err)
+		return
+	}
+
+	if err := jobHashes.Set(ctx, msg.JobID, jobhash.FieldChunks, len(msg.Chunks)); err != nil {
+		log.Printf("❌ Failed to store the chunks of job %s: %v", msg.JobID, err)
+		return
+	}
+	// The chunks are encoded from the copy the split left in the shared segments directory
+	if msg.InputURL != "" {
+		req.InputURL = msg.InputURL
+	}
+	log.Printf("🧩 Job %s input cut into %d chunks", msg.JobID, len(msg.Chunks))
+	dispatchRungs(msg.JobID, job.TenantID, req, staticLadder(req.Resolutions), msg.Chunks)
+}
+
+// publishRung publishes a rung's job, or one job per chunk if the input was cut into several.
+func publishRung(topic string, job TranscodeJob, chunks []contracts.Chunk) error {
+	if len(chunks) < 2 {
+		return PublishJob(topic, job)
+	}
+	for i := range chunks {
+		job.Chunk = &chunks[i]
+		job.ChunkCount = len(chunks)
+		if err := PublishJob(topic, job); err != nil {
+			return fmt.Errorf("chunk %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,399 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"common/bus"
+	"common/contracts"
+
+	"github.com/google/uuid"
+)
+
+var resolutionMap = map[string]struct {
+	Resolution string
+	Bitrate    string
+}{
+	"144p":  {"256x144", "200k"},
+	"240p":  {"426x240", "300k"},
+	"360p":  {"640x360", "800k"},
+	"480p":  {"854x480", "1200k"},
+	"720p":  {"1280x720", "2500k"},
+	"1080p": {"1920x1080", "4500k"},
+}
+
+var validCodecs = map[string]bool{
+	"h264": true,
+	"hevc": true,
+	"vvc":  true,
+	"vp9":  true,
+	"av1":  true,
+}
+
+// Init connects the controller to Redis and the job store, and publishes jobs and status events on b.
+func Init(b bus.Bus) {
+	msgBus = b
+	InitRedis()
+	InitDB()
+	InitQuotas()
+	InitIdempotency()
+	InitBatches()
+	InitEncoders()
+}
+
+// Handler returns the controller's HTTP API.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "Transcoding Controller is up")
+	})
+
+	mux.HandleFunc("/transcode", handleTranscodeRequest)
+	mux.HandleFunc("/jobs", handleListJobs)
+	mux.HandleFunc("/jobs/", handleJobByID)
+	mux.HandleFunc("/usage", handleUsage)
+	mux.HandleFunc("/batches", handleBatches)
+	mux.HandleFunc("/batches/", handleBatchByID)
+	return mux
+}
+
+// Run serves the API on addr and watches batches until ctx is cancelled.
+func Run(ctx context.Context, addr string) error {
+	go WatchBatches(ctx)
+	go func() {
+		if err := ConsumeLadders(ctx); err != nil {
+			log.Printf("❌ Stopped consuming ladders: %v", err)
+		}
+	}()
+	go func() {
+		if err := ConsumeChunkPlans(ctx); err != nil {
+			log.Printf("❌ Stopped consuming chunk plans: %v", err)
+		}
+	}()
+	go func() {
+		if err := ConsumeEditResults(ctx); err != nil {
+			log.Printf("❌ Stopped consuming edit results: %v", err)
+		}
+	}()
+
+	server := &http.Server{Addr: addr, Handler: Handler()}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("🚀 Controller running on %s", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Request bodies are read up to these sizes; a batch holds up to BATCH_MAX_JOBS requests.
+const (
+	maxRequestBytes = 1 << 20
+	maxBatchBytes   = 16 << 20
+)
+
+// bodyTooLarge answers 413 if err is from reading past a body's size limit.
+func bodyTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+	return true
+}
+
+func handleTranscodeRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant, ok := tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		if !bodyTooLarge(w, err) {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		}
+		return
+	}
+	var req TranscodeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("❌ JSON decode error: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Retries carrying the same Idempotency-Key resolve to the original job, without using up
+	// another request of the tenant's rate limit
+	idemKey := idempotencyKeyFromRequest(r, req)
+	var reqHash string
+	if idemKey != "" {
+		if reqHash, err = RequestHash(body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rec, err := LookupIdempotencyKey(tenant, idemKey)
+		if err != nil {
+			http.Error(w, "Failed to check idempotency key", http.StatusInternalServerError)
+			log.Printf("❌ Idempotency lookup failed: %v", err)
+			return
+		}
+		if rec != nil {
+			writeIdempotentReplay(w, rec, reqHash)
+			return
+		}
+	}
+
+	if !checkQuota(w, CheckRateLimit(tenant, 1)) {
+		return
+	}
+
+	log.Printf("📥 Received transcode request: %+v", req)
+
+	if msg := validateTranscodeRequest(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if !checkQuota(w, CheckDailyMinutes(tenant)) {
+		return
+	}
+
+	jobID := uuid.New().String()
+	if !checkQuota(w, ReserveActiveJob(tenant, jobID)) {
+		return
+	}
+
+	if idemKey != "" {
+		rec, err := ClaimIdempotencyKey(tenant, idemKey, jobID, reqHash)
+		if err != nil || rec != nil {
+			ReleaseActiveJob(tenant, jobID)
+		}
+		if err != nil {
+			http.Error(w, "Failed to store idempotency key", http.StatusInternalServerError)
+			log.Printf("❌ Idempotency claim failed: %v", err)
+			return
+		}
+		if rec != nil {
+			writeIdempotentReplay(w, rec, reqHash)
+			return
+		}
+	}
+	log.Printf("🆕 New transcode job: %s (tenant=%s)", jobID, tenant)
+	applyDefaultGOP(&req)
+
+	// Store metadata in Redis
+	if err := StoreJobMetadata(jobID, tenant, req); err != nil {
+		ReleaseActiveJob(tenant, jobID)
+		if idemKey != "" {
+			ReleaseIdempotencyKey(tenant, idemKey)
+		}
+		http.Error(w, "Failed to store metadata", http.StatusInternalServerError)
+		log.Printf("❌ Failed to store metadata: %v", err)
+		return
+	}
+
+	// Write job to DB immediately with "waiting" status
+	if err := InsertJobToDB(jobID, req, "waiting"); err != nil {
+		// Without a row the job could never be looked up, so it is not dispatched
+		if err := jobHashes.Delete(ctx, jobID); err != nil {
+			log.Printf("⚠️ Failed to delete job hash %s: %v", jobID, err)
+		}
+		ReleaseActiveJob(tenant, jobID)
+		if idemKey != "" {
+			ReleaseIdempotencyKey(tenant, idemKey)
+		}
+		http.Error(w, "Failed to store job", http.StatusInternalServerError)
+		log.Printf("❌ Failed to insert job to DB: %v", err)
+		return
+	}
+	PublishStatusEvent(jobID, "", "waiting", "job submitted")
+
+	// Dispatch transcoding jobs to Kafka
+	DispatchRepresentations(jobID, tenant, req)
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, `{"job_id": "%s", "status": "submitted"}`, jobID)
+}
+
+// validateTranscodeRequest checks required fields and fills in defaults. It returns an error message, or "" if valid.
+func validateTranscodeRequest(req *TranscodeRequest) string {
+	if msg := validateLadder(req); msg != "" {
+		return msg
+	}
+	if msg := validateEdits(req); msg != "" {
+		return msg
+	}
+	if req.StreamName == "" || req.InputURL == "" || len(req.Resolutions) == 0 || req.Codec == "" {
+		return "Missing required fields"
+	}
+	if !validInputURL(req.InputURL) {
+		return "input_url must be an http or https URL"
+	}
+	if !validCodecs[req.Codec] {
+		return "Unsupported codec"
+	}
+	if req.Priority == "" {
+		req.Priority = "normal"
+	}
+	if _, ok := contracts.PriorityTopics[req.Priority]; !ok {
+		return "Unsupported priority"
+	}
+	if req.RateControl != "" && !contracts.RateControlModes[req.RateControl] {
+		return "Unsupported rate_control (use cbr, vbr, crf or 2pass)"
+	}
+	if msg := validateRateControl(req.Codec, req.RateControl); msg != "" {
+		return msg
+	}
+	if req.CRF != 0 && req.RateControl != contracts.RateControlCRF {
+		return "crf requires rate_control crf"
+	}
+	if req.CRF < 0 || req.CRF > maxCRF[req.Codec] {
+		return fmt.Sprintf("crf must be between 0 and %d for %s", maxCRF[req.Codec], req.Codec)
+	}
+	if req.QualityProfile == "" {
+		req.QualityProfile = contracts.QualityBalanced
+	}
+	if !contracts.QualityProfiles[req.QualityProfile] {
+		return "Unsupported quality_profile (use fast, balanced or archive)"
+	}
+	if req.QualityCheck != nil {
+		if err := req.QualityCheck.Validate(); err != nil {
+			return "Invalid quality_check: " + err.Error()
+		}
+	}
+	if msg := validateThumbnails(req); msg != "" {
+		return msg
+	}
+	if msg := validateSubtitles(req); msg != "" {
+		return msg
+	}
+	return validateChunking(req)
+}
+
+// DispatchRepresentations publishes one Kafka job per requested resolution. Jobs with an automatic
+// ladder are sent for analysis instead, and their rungs dispatched once a worker has chosen them;
+// chunked jobs are sent to be split, and dispatched once a worker has cut their input. A job's
+// thumbnails and subtitles are dispatched along with it. A job with an edit list is only sent to
+// have it rendered, and dispatched again once it has been.
+func DispatchRepresentations(jobID, tenant string, req TranscodeRequest) {
+	if len(req.Edits) > 0 {
+		DispatchEdit(jobID, req)
+		return
+	}
+	if req.Thumbnails != nil {
+		DispatchThumbnails(jobID, req)
+	}
+	if req.Subtitles != nil {
+		DispatchSubtitles(jobID, req)
+	}
+	switch {
+	case req.Ladder == LadderAuto:
+		DispatchLadderAnalysis(jobID, req)
+	case req.ChunkSeconds > 0:
+		DispatchSplit(jobID, req)
+	default:
+		dispatchRungs(jobID, tenant, req, staticLadder(req.Resolutions), nil)
+	}
+}
+
+// dispatchRungs publishes one Kafka job per ladder rung, or one per chunk of every rung when the
+// input was cut into chunks.
+func dispatchRungs(jobID, tenant string, req TranscodeRequest, rungs []contracts.LadderRung, chunks []contracts.Chunk) {
+	topic := contracts.TopicForPriority(req.Priority)
+	for _, rung := range rungs {
+		rep := rung.Representation
+		job := TranscodeJob{
+			JobID:          jobID,
+			InputURL:       req.InputURL,
+			Representation: rep,
+			Resolution:     rung.Resolution,
+			Bitrate:        rung.Bitrate,
+			Codec:          req.Codec,
+			OutputPath:     fmt.Sprintf("s3://output/%s/video_%s.mp4", jobID, rep),
+			GopSize:        req.GopSize,
+			KeyintMin:      req.KeyintMin,
+			TenantID:       tenant,
+			Priority:       req.Priority,
+			RateControl:    req.RateControl,
+			CRF:            req.CRF,
+			QualityProfile: req.QualityProfile,
+			QualityCheck:   req.QualityCheck,
+		}
+		job.Maxrate, job.Bufsize = rungVBV(req.RateControl, rung.Bitrate)
+
+		if err := publishRung(topic, job, chunks); err != nil {
+			log.Printf("❌ Failed to publish job %s: %v", rep, err)
+		} else {
+			log.Printf("✅ Published job for resolution: %s (%s)", rep, topic)
+			MarkRepresentationQueued(jobID, rep, rung.Resolution, rung.Bitrate)
+		}
+	}
+}
+
+func handleListJobs(w http.ResponseWriter, r *http.Request) {
+	q, err := parseJobListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := ListJobs(q)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		log.Printf("❌ Failed to fetch jobs: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// checkQuota writes the error response for a failed quota check and reports whether the request may proceed.
+func checkQuota(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return true
+	}
+	if qe, ok := err.(*QuotaExceededError); ok {
+		log.Printf("🚫 %v", qe)
+		writeQuotaExceeded(w, qe)
+		return false
+	}
+	log.Printf("❌ Quota check failed: %v", err)
+	http.Error(w, "Failed to check quota", http.StatusInternalServerError)
+	return false
+}
+
+func handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant, ok := tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+	usage, err := GetTenantUsage(tenant)
+	if err != nil {
+		http.Error(w, "Failed to fetch usage", http.StatusInternalServerError)
+		log.Printf("❌ Failed to fetch usage: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"common/failure"
+	"common/jobstore"
+)
+
+var store jobstore.Store
+
+const schemaWaitTimeout = 2 * time.Minute
+
+// InitDB opens the job store selected by DB_DRIVER (SQLite by default, or PostgreSQL).
+func InitDB() {
+	var err error
+	store, err = jobstore.OpenFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to open DB: %v", err)
+	}
+	log.Println("✅ Connected to job store")
+
+	// The tracker owns the schema; refuse to serve until it has been migrated to the version we expect
+	if err = store.WaitForSchema(schemaWaitTimeout); err != nil {
+		log.Fatalf("❌ DB schema not ready: %v", err)
+	}
+}
+
+type TranscodedJob = jobstore.Job
+
+type Batch = jobstore.Batch
+
+// JobListQuery holds the filters, sort and page position for ListJobs.
+type JobListQuery = jobstore.ListQuery
+
+// JobPage is one page of ListJobs results.
+type JobPage = jobstore.Page
+
+// InsertJobToDB inserts a job immediately upon submission with status like "waiting"
+func InsertJobToDB(jobID string, req TranscodeRequest, status string) error {
+	err := store.InsertJob(TranscodedJob{
+		JobID:           jobID,
+		StreamName:      req.StreamName,
+		InputURL:        req.InputURL,
+		Codec:           req.Codec,
+		QualityProfile:  req.QualityProfile,
+		Representations: strings.Join(dispatchedResolutions(req), ","),
+		Status:          status,
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to insert job to DB (job_id=%s): %v", jobID, err)
+	} else {
+		log.Printf("✅ Inserted job to DB: job_id=%s, status=%s", jobID, status)
+	}
+	return err
+}
+
+// UpdateJobDuration records the probed duration of the input the job is encoded from.
+func UpdateJobDuration(jobID string, seconds float64) error {
+	return store.UpdateDuration(jobID, seconds)
+}
+
+// MarkJobFailedInDB fails a job and records why.
+func MarkJobFailedInDB(jobID string, f failure.Failure) error {
+	return store.MarkJobFailed(jobID, f)
+}
+
+// ListJobs returns one page of jobs matching q plus the total number of matches.
+func ListJobs(q JobListQuery) (JobPage, error) {
+	return store.ListJobs(q)
+}
+
+// GetJobDetail returns the job with its per-representation records, or nil if it does not exist.
+func GetJobDetail(jobID string) (*JobDetail, error) {
+	job, err := store.GetJob(jobID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+	reps, err := store.GetRepresentations(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return &JobDetail{Job: *job, Representations: reps}, nil
+}
+
+// GetJobHistory returns the job's state transitions, oldest first.
+func GetJobHistory(jobID string) ([]jobstore.JobEvent, error) {
+	return store.GetJobEvents(jobID)
+}
+
+// BatchJob pairs a generated job ID with its resolved request.
+type BatchJob struct {
+	JobID   string           `json:"job_id"`
+	Request TranscodeRequest `json:"request"`
+}
+
+// InsertBatchToDB creates the batch and all of its jobs in a single transaction.
+func InsertBatchToDB(batchID, tenantID, webhookURL string, jobs []BatchJob) error {
+	records := make([]TranscodedJob, len(jobs))
+	for i, job := range jobs {
+		req := job.Request
+		records[i] = TranscodedJob{
+			JobID:           job.JobID,
+			StreamName:      req.StreamName,
+			InputURL:        req.InputURL,
+			Codec:           req.Codec,
+			QualityProfile:  req.QualityProfile,
+			Representations: strings.Join(dispatchedResolutions(req), ","),
+		}
+	}
+
+	if err := store.InsertBatch(Batch{BatchID: batchID, TenantID: tenantID, WebhookURL: webhookURL}, records); err != nil {
+		return err
+	}
+	log.Printf("✅ Inserted batch to DB: batch_id=%s, jobs=%d", batchID, len(jobs))
+	return nil
+}
+
+// GetBatch returns the batch record, or nil if it does not exist.
+func GetBatch(batchID string) (*Batch, error) {
+	return store.GetBatch(batchID)
+}
+
+// GetBatchJobs lists every job in a batch.
+func GetBatchJobs(batchID string) ([]TranscodedJob, error) {
+	return store.GetBatchJobs(batchID)
+}
+
+// CancelBatchInDB marks the batch and its unfinished jobs cancelled, returning the cancelled job IDs.
+func CancelBatchInDB(batchID string) ([]string, error) {
+	return store.CancelBatch(batchID)
+}
+
+// GetUnnotifiedBatches returns running or cancelled batches whose completion has not been reported yet.
+func GetUnnotifiedBatches() ([]Batch, error) {
+	return store.GetUnnotifiedBatches()
+}
+
+// MarkBatchFinished records that every job in the batch reached a terminal state.
+func MarkBatchFinished(batchID, status string) error {
+	return store.MarkBatchFinished(batchID, status)
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+
+	"common/bus"
+	"common/contracts"
+	"common/jobhash"
+)
+
+// maxEdits caps the clips of one edit list, each of which is an FFmpeg input of the render.
+const maxEdits = 50
+
+// validateEdits checks the edit list and fills in each clip's input, the job's input_url by
+// default; a job with an edit list may leave input_url out, and gets its first clip's input.
+// Subtitles are timed against the original input, so they cannot be combined with an edit list.
+func validateEdits(req *TranscodeRequest) string {
+	if len(req.Edits) == 0 {
+		return ""
+	}
+	if len(req.Edits) > maxEdits {
+		return fmt.Sprintf("edits may have at most %d clips", maxEdits)
+	}
+	if req.InputURL == "" {
+		req.InputURL = req.Edits[0].InputURL
+	}
+	for i := range req.Edits {
+		e := &req.Edits[i]
+		if e.InputURL == "" {
+			e.InputURL = req.InputURL
+		}
+		if e.InputURL == "" {
+			return fmt.Sprintf("edits[%d]: missing input_url", i)
+		}
+		if !validInputURL(e.InputURL) {
+			return fmt.Sprintf("edits[%d]: input_url must be an http or https URL", i)
+		}
+		if err := e.Validate(); err != nil {
+			return fmt.Sprintf("edits[%d]: %v", i, err)
+		}
+	}
+	if req.Subtitles != nil {
+		return "edits cannot be combined with subtitles"
+	}
+	return ""
+}
+
+// validInputURL reports whether u can be fetched by a worker. Other schemes, such as the
+// file:// URLs of edited inputs, are reserved for inputs the workers render themselves.
+func validInputURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// DispatchEdit asks a worker to render the job's edit list. Everything else about the job is
+// dispatched once it has been, with the rendered file as its input.
+func DispatchEdit(jobID string, req TranscodeRequest) {
+	payload, err := contracts.Encode(&contracts.EditJob{
+		JobID: jobID,
+		Edits: req.Edits,
+	})
+	if err == nil {
+		err = msgBus.Publish(ctx, contracts.TopicEdits, []byte(jobID), payload)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to publish edit for job %s: %v", jobID, err)
+		return
+	}
+	log.Printf("✅ Published edit for job %s (%d clips)", jobID, len(req.Edits))
+}
+
+// ConsumeEditResults dispatches jobs with an edit list once a worker has rendered it, until ctx
+// is cancelled.
+func ConsumeEditResults(ctx context.Context) error {
+	return msgBus.Subscribe(ctx, contracts.TopicEditResults, "transcoding-controller", handleEditResult)
+}
+
+func handleEditResult(m bus.Message) {
+	var msg contracts.EditResult
+	if err := contracts.Decode(m.Value, &msg); err != nil {
+		log.Printf("❌ Rejected edit result (%v): %s", err, string(m.Value))
+		return
+	}
+
+	job, err := jobHashes.Get(ctx, msg.JobID)
+	if err == nil && job == nil {
+		err = fmt.Errorf("job hash %s not found", jobHashes.Key(msg.JobID))
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read job %s for its edit: %v", msg.JobID, err)
+		return
+	}
+	if job.Status == "cancelled" {
+		log.Printf("🛑 Job %s was cancelled during the edit, not dispatching it", msg.JobID)
+		return
+	}
+	if edited, err := jobHashes.Field(ctx, msg.JobID, jobhash.FieldEditedInput); err != nil || edited != "" {
+		log.Printf("⚠️ Job %s edit already dispatched, ignoring redelivered result", msg.JobID)
+		return
+	}
+
+	raw, err := jobHashes.Field(ctx, msg.JobID, jobhash.FieldRequest)
+	var req TranscodeRequest
+	if err == nil {
+		err = json.Unmarshal([]byte(raw), &req)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read the request of job %s: %v", msg.JobID, err)
+		return
+	}
+
+	// From here on the job is encoded from the rendered edit, including by any later worker
+	// stage that reads the stored request
+	req.InputURL = msg.InputURL
+	req.Edits = nil
+	edited, err := json.Marshal(req)
+	if err == nil {
+		err = jobHashes.Set(ctx, msg.JobID, jobhash.FieldEditedInput, msg.InputURL, jobhash.FieldRequest, string(edited))
+	}
+	if err != nil {
+		log.Printf("❌ Failed to store the edit of job %s: %v", msg.JobID, err)
+		return
+	}
+	if err := UpdateJobDuration(msg.JobID, msg.Duration); err != nil {
+		log.Printf("⚠️ Failed to record the duration of job %s: %v", msg.JobID, err)
+	}
+	log.Printf("✂️ Job %s edit list rendered: %.3fs", msg.JobID, msg.Duration)
+	DispatchRepresentations(msg.JobID, job.TenantID, req)
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"common/contracts"
+)
+
+// maxGOPSeconds is the longest default GOP. A segment is split into the fewest GOPs no longer
+// than this, so players can still start and switch within it.
+const maxGOPSeconds = 2.0
+
+// probeTimeout bounds the frame rate probe of a submission's input; ffprobe only reads the
+// input's headers for it.
+const probeTimeout = 15 * time.Second
+
+// applyDefaultGOP sets a GOP that divides the DASH segment evenly, from the input's frame rate,
+// when the request leaves it to the encoder: every rendition then has keyframes at the same
+// times and every segment starts on one. KeyintMin defaults to the GOP, which keeps scene cuts
+// from adding keyframes in some renditions only.
+func applyDefaultGOP(req *TranscodeRequest) {
+	if req.GopSize == 0 {
+		fps, err := probeFrameRate(req.InputURL)
+		if err != nil {
+			log.Printf("⚠️ Could not probe the frame rate of %s, leaving keyframe placement to the encoder: %v", req.InputURL, err)
+			return
+		}
+		req.GopSize = defaultGOP(fps, contracts.SegmentSeconds)
+		log.Printf("🎞️ %s runs at %.3f fps, using a GOP of %d frames", req.InputURL, fps, req.GopSize)
+	}
+	if req.KeyintMin == 0 {
+		req.KeyintMin = req.GopSize
+	}
+}
+
+// defaultGOP is the GOP, in frames, that splits a segment into the fewest GOPs of at most
+// maxGOPSeconds.
+func defaultGOP(fps, segmentSeconds float64) int {
+	gops := math.Ceil(segmentSeconds / maxGOPSeconds)
+	return int(math.Max(1, math.Round(fps*segmentSeconds/gops)))
+}
+
+// probeFrameRate asks ffprobe for the frame rate of the input's first video stream.
+func probeFrameRate(inputURL string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=r_frame_rate",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		inputURL,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	rate := strings.TrimSpace(string(out))
+	num, den, ok := strings.Cut(rate, "/")
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if !ok || err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("invalid frame rate %q", rate)
+	}
+	return n / d, nil
+}
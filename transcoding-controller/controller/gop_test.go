@@ -0,0 +1,47 @@
+package controller
+
+import "testing"
+
+func TestDefaultGOP(t *testing.T) {
+	tests := []struct {
+		fps, segment float64
+		want         int
+	}{
+		{24, 4, 48},
+		{25, 4, 50},
+		{23.976, 4, 48},
+		{29.97, 4, 60},
+		{60, 4, 120},
+		{30, 6, 60}, // three 2s GOPs
+		{30, 5, 50}, // three GOPs, as two would be longer than 2s
+		{30, 1, 30}, // a segment shorter than the longest GOP is one GOP
+		{0.1, 4, 1}, // never less than a frame
+	}
+	for _, tt := range tests {
+		if got := defaultGOP(tt.fps, tt.segment); got != tt.want {
+			t.Errorf("defaultGOP(%v, %v) = %d, want %d", tt.fps, tt.segment, got, tt.want)
+		}
+	}
+}
+
+func TestApplyDefaultGOP(t *testing.T) {
+	// A GOP set by the request is kept, and the minimum keyframe interval follows it
+	req := TranscodeRequest{InputURL: "/nonexistent/input.mp4", GopSize: 60}
+	applyDefaultGOP(&req)
+	if req.GopSize != 60 || req.KeyintMin != 60 {
+		t.Errorf("gop_size %d, keyint_min %d, want 60 and 60", req.GopSize, req.KeyintMin)
+	}
+
+	req = TranscodeRequest{InputURL: "/nonexistent/input.mp4", GopSize: 60, KeyintMin: 30}
+	applyDefaultGOP(&req)
+	if req.KeyintMin != 30 {
+		t.Errorf("keyint_min %d, want the requested 30", req.KeyintMin)
+	}
+
+	// An input whose frame rate cannot be probed is left to the encoder
+	req = TranscodeRequest{InputURL: "/nonexistent/input.mp4"}
+	applyDefaultGOP(&req)
+	if req.GopSize != 0 || req.KeyintMin != 0 {
+		t.Errorf("gop_size %d, keyint_min %d, want both left unset", req.GopSize, req.KeyintMin)
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var idempotencyTTL = 24 * time.Hour
+
+// IdempotencyRecord maps a client-supplied key to the job it created.
+type IdempotencyRecord struct {
+	JobID       string `json:"job_id"`
+	RequestHash string `json:"request_hash"`
+}
+
+// InitIdempotency loads the retention window for idempotency keys.
+func InitIdempotency() {
+	if hours := envInt("IDEMPOTENCY_TTL_HOURS", 24); hours > 0 {
+		idempotencyTTL = time.Duration(hours) * time.Hour
+	}
+	log.Printf("🔁 Idempotency keys retained for %s", idempotencyTTL)
+}
+
+// idempotencyKeyFromRequest prefers the Idempotency-Key header over the client_request_id body field.
+func idempotencyKeyFromRequest(r *http.Request, req TranscodeRequest) string {
+	if key := strings.TrimSpace(r.Header.Get("Idempotency-Key")); key != "" {
+		return key
+	}
+	return strings.TrimSpace(req.ClientRequestID)
+}
+
+func idempotencyRedisKey(tenant, key string) string {
+	return jobHashes.Namespaced(fmt.Sprintf("idempotency:%s:%s", tenant, key))
+}
+
+// RequestHash fingerprints the body the client sent, so a reused key with a different payload can
+// be detected. The body is canonicalised (keys sorted, whitespace and client_request_id dropped)
+// but hashed before any defaults are applied, so a replay still matches after a deploy that adds
+// a field or changes a default.
+func RequestHash(body []byte) (string, error) {
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return "", err
+	}
+	delete(fields, "client_request_id")
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LookupIdempotencyKey returns the stored record for a key, or nil if the key is unused.
+func LookupIdempotencyKey(tenant, key string) (*IdempotencyRecord, error) {
+	val, err := redisClient.Get(ctx, idempotencyRedisKey(tenant, key)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec IdempotencyRecord
+	if err := json.Unmarshal([]byte(val), &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// ClaimIdempotencyKey stores key→jobID unless the key is already taken, in which case the
+// existing record is returned so concurrent retries resolve to a single job.
+func ClaimIdempotencyKey(tenant, key, jobID, hash string) (*IdempotencyRecord, error) {
+	payload, _ := json.Marshal(IdempotencyRecord{JobID: jobID, RequestHash: hash})
+	ok, err := redisClient.SetNX(ctx, idempotencyRedisKey(tenant, key), payload, idempotencyTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+	return LookupIdempotencyKey(tenant, key)
+}
+
+// ReleaseIdempotencyKey forgets a key whose submission failed, so the client can retry it.
+func ReleaseIdempotencyKey(tenant, key string) {
+	if err := redisClient.Del(ctx, idempotencyRedisKey(tenant, key)).Err(); err != nil {
+		log.Printf("⚠️ Failed to release idempotency key %s: %v", key, err)
+	}
+}
+
+// writeIdempotentReplay answers a retried request with the original job, or 409 if the payload changed.
+func writeIdempotentReplay(w http.ResponseWriter, rec *IdempotencyRecord, hash string) {
+	if rec.RequestHash != hash {
+		http.Error(w, "Idempotency-Key reused with a different request body", http.StatusConflict)
+		return
+	}
+
+	log.Printf("🔁 Replaying idempotent submission for job %s", rec.JobID)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, `{"job_id": "%s", "status": "submitted"}`, rec.JobID)
+}
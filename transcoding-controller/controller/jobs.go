@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"common/jobstore"
+)
+
+const (
+	defaultJobPageSize = 50
+	maxJobPageSize     = 200
+)
+
+// parseJobListQuery reads the /jobs query string:
+// status (comma-separated), codec, stream_name_prefix, created_after, created_before (RFC 3339),
+// worker_id, sort (created_at|updated_at|stream_name), order (asc|desc), limit and cursor.
+func parseJobListQuery(values url.Values) (JobListQuery, error) {
+	q := JobListQuery{
+		Codec:            values.Get("codec"),
+		StreamNamePrefix: values.Get("stream_name_prefix"),
+		WorkerID:         values.Get("worker_id"),
+		SortBy:           values.Get("sort"),
+		Descending:       true,
+		Limit:            defaultJobPageSize,
+	}
+
+	for _, status := range strings.Split(values.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			q.Statuses = append(q.Statuses, status)
+		}
+	}
+
+	if q.SortBy == "" {
+		q.SortBy = "created_at"
+	}
+	if _, ok := jobstore.SortColumns[q.SortBy]; !ok {
+		return q, fmt.Errorf("unsupported sort %q", q.SortBy)
+	}
+
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Descending = false
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxJobPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxJobPageSize)
+		}
+		q.Limit = limit
+	}
+
+	var err error
+	if q.CreatedAfter, err = parseTimeParam(values, "created_after"); err != nil {
+		return q, err
+	}
+	if q.CreatedBefore, err = parseTimeParam(values, "created_before"); err != nil {
+		return q, err
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		cursor, err := jobstore.DecodeCursor(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.After = &cursor
+	}
+	return q, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query value; the zero time means unset.
+func parseTimeParam(values url.Values, name string) (time.Time, error) {
+	v := values.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return t, nil
+}
+
+// JobDetail is the response body of GET /jobs/{id}.
+type JobDetail struct {
+	Job             TranscodedJob             `json:"job"`
+	Representations []jobstore.Representation `json:"representations"`
+}
+
+// JobHistory is the response body of GET /jobs/{id}/history.
+type JobHistory struct {
+	JobID  string              `json:"job_id"`
+	Events []jobstore.JobEvent `json:"events"`
+}
+
+// handleJobByID serves GET /jobs/{id} and GET /jobs/{id}/history.
+func handleJobByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	jobID, action, _ := strings.Cut(path, "/")
+	if jobID == "" || (action != "" && action != "history") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	detail, err := GetJobDetail(jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job", http.StatusInternalServerError)
+		log.Printf("❌ Failed to fetch job %s: %v", jobID, err)
+		return
+	}
+	if detail == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	var body interface{} = detail
+	if action == "history" {
+		events, err := GetJobHistory(jobID)
+		if err != nil {
+			http.Error(w, "Failed to fetch job history", http.StatusInternalServerError)
+			log.Printf("❌ Failed to fetch history for job %s: %v", jobID, err)
+			return
+		}
+		body = JobHistory{JobID: jobID, Events: events}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
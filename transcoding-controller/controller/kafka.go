@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"log"
+	"time"
+
+	"common/bus"
+	"common/contracts"
+)
+
+// msgBus carries job and status messages; Kafka in deployments, in-memory when embedded.
+var msgBus bus.Bus
+
+func PublishJob(topic string, job TranscodeJob) error {
+	payload, err := contracts.Encode(&job)
+	if err != nil {
+		return err
+	}
+
+	return msgBus.Publish(ctx, topic, []byte(job.JobID), payload)
+}
+
+// statusTopic carries job state transitions; the tracker appends them to the job's history.
+const statusTopic = contracts.TopicStatus
+
+// PublishStatusEvent reports a job state change made by the controller. Failures are logged, not returned,
+// because the history is best-effort and must not fail the request.
+func PublishStatusEvent(jobID, from, to, message string) {
+	payload, err := contracts.Encode(&contracts.StatusEvent{
+		JobID:     jobID,
+		Service:   "transcoding-controller",
+		FromState: from,
+		ToState:   to,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to encode status event for job %s: %v", jobID, err)
+		return
+	}
+
+	if err := msgBus.Publish(ctx, statusTopic, []byte(jobID), payload); err != nil {
+		log.Printf("⚠️ Failed to publish status event for job %s: %v", jobID, err)
+	}
+}
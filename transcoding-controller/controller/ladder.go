@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"common/bus"
+	"common/contracts"
+	"common/jobhash"
+)
+
+// LadderAuto has a worker choose the job's rungs from probe encodes of its input, instead of
+// encoding every requested resolution at its static bitrate.
+const LadderAuto = "auto"
+
+// defaultLadderCandidates are the resolutions an automatic ladder chooses from when the request
+// names none.
+var defaultLadderCandidates = []string{"240p", "360p", "480p", "720p", "1080p"}
+
+// validateLadder checks the ladder mode and fills in the default candidates. With an automatic
+// ladder every resolution must be known, since each one is probe-encoded.
+func validateLadder(req *TranscodeRequest) string {
+	switch req.Ladder {
+	case "":
+		return ""
+	case LadderAuto:
+	default:
+		return "Unsupported ladder (use auto, or omit it for the static ladder)"
+	}
+	if len(req.Resolutions) == 0 {
+		req.Resolutions = append([]string(nil), defaultLadderCandidates...)
+	}
+	for _, rep := range req.Resolutions {
+		if _, ok := resolutionMap[strings.ToLower(rep)]; !ok {
+			return fmt.Sprintf("Unsupported resolution %s", rep)
+		}
+	}
+	return ""
+}
+
+// staticLadder returns the resolutionMap rungs of the requested resolutions, lowest first as
+// requested, skipping unknown ones.
+func staticLadder(resolutions []string) []contracts.LadderRung {
+	var rungs []contracts.LadderRung
+	for _, rep := range resolutions {
+		info, ok := resolutionMap[rep]
+		if !ok {
+			log.Printf("⚠️ Unsupported resolution: %s", rep)
+			continue
+		}
+		rungs = append(rungs, contracts.LadderRung{Representation: rep, Resolution: info.Resolution, Bitrate: info.Bitrate})
+	}
+	return rungs
+}
+
+// dispatchedResolutions are the representations a job starts with. An automatic ladder has none
+// until its rungs are chosen.
+func dispatchedResolutions(req TranscodeRequest) []string {
+	if req.Ladder == LadderAuto {
+		return nil
+	}
+	return req.Resolutions
+}
+
+// DispatchLadderAnalysis asks a worker to choose the job's rungs among the requested resolutions.
+func DispatchLadderAnalysis(jobID string, req TranscodeRequest) {
+	payload, err := contracts.Encode(&contracts.LadderAnalysisJob{
+		JobID:      jobID,
+		InputURL:   req.InputURL,
+		Codec:      req.Codec,
+		Candidates: staticLadder(req.Resolutions),
+	})
+	if err == nil {
+		err = msgBus.Publish(ctx, contracts.TopicLadderAnalysis, []byte(jobID), payload)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to publish ladder analysis for job %s: %v", jobID, err)
+		return
+	}
+	log.Printf("✅ Published ladder analysis for job %s (%s)", jobID, strings.Join(req.Resolutions, ","))
+}
+
+// ConsumeLadders dispatches the rungs workers choose for automatic ladders until ctx is cancelled.
+func ConsumeLadders(ctx context.Context) error {
+	return msgBus.Subscribe(ctx, contracts.TopicLadders, "transcoding-controller", handleLadderResult)
+}
+
+func handleLadderResult(m bus.Message) {
+	var msg contracts.LadderResult
+	if err := contracts.Decode(m.Value, &msg); err != nil {
+		log.Printf("❌ Rejected ladder result (%v): %s", err, string(m.Value))
+		return
+	}
+
+	job, err := jobHashes.Get(ctx, msg.JobID)
+	if err == nil && job == nil {
+		err = fmt.Errorf("job hash %s not found", jobHashes.Key(msg.JobID))
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read job %s for its ladder: %v", msg.JobID, err)
+		return
+	}
+	switch {
+	case job.Status == "cancelled":
+		log.Printf("🛑 Job %s was cancelled during analysis, not dispatching its ladder", msg.JobID)
+		return
+	case len(job.RequiredResolutions) > 0:
+		log.Printf("⚠️ Job %s ladder already dispatched, ignoring redelivered result", msg.JobID)
+		return
+	}
+
+	raw, err := jobHashes.Field(ctx, msg.JobID, jobhash.FieldRequest)
+	var req TranscodeRequest
+	if err == nil {
+		err = json.Unmarshal([]byte(raw), &req)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read the request of job %s: %v", msg.JobID, err)
+		return
+	}
+
+	reps := make([]string, len(msg.Rungs))
+	for i, rung := range msg.Rungs {
+		reps[i] = rung.Representation
+	}
+	if err := jobHashes.Set(ctx, msg.JobID, jobhash.FieldRequiredResolutions, strings.Join(reps, ",")); err != nil {
+		log.Printf("❌ Failed to store the ladder of job %s: %v", msg.JobID, err)
+		return
+	}
+	log.Printf("🪜 Job %s ladder chosen: %+v", msg.JobID, msg.Rungs)
+	dispatchRungs(msg.JobID, job.TenantID, req, msg.Rungs, nil)
+}
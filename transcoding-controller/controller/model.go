@@ -0,0 +1,26 @@
+package controller
+
+import "common/contracts"
+
+type TranscodeRequest struct {
+	StreamName      string                  `json:"stream_name"`
+	InputURL        string                  `json:"input_url"`
+	Resolutions     []string                `json:"resolutions"`
+	Codec           string                  `json:"codec"`
+	GopSize         int                     `json:"gop_size"`                    // ✅ Added field
+	KeyintMin       int                     `json:"keyint_min"`                  // ✅ Added field
+	Priority        string                  `json:"priority"`                    // high, normal (default) or low
+	ClientRequestID string                  `json:"client_request_id,omitempty"` // alternative to the Idempotency-Key header
+	RateControl     string                  `json:"rate_control,omitempty"`      // cbr, vbr, crf or 2pass; empty is plain average bitrate
+	CRF             int                     `json:"crf,omitempty"`               // crf quality on the codec's scale; 0 uses the encoder default
+	QualityProfile  string                  `json:"quality_profile,omitempty"`   // fast, balanced (default) or archive
+	Ladder          string                  `json:"ladder,omitempty"`            // "auto" picks rungs per title among resolutions (default 240p-1080p)
+	QualityCheck    *contracts.QualityCheck `json:"quality_check,omitempty"`     // score every rendition, optionally with minimums
+	ChunkSeconds    float64                 `json:"chunk_seconds,omitempty"`     // cut the input into chunks of about this length, encoded in parallel
+	Thumbnails      *contracts.Thumbnails   `json:"thumbnails,omitempty"`        // poster, scrubbing thumbnails, sprite sheets and a WebVTT track
+	Subtitles       *contracts.Subtitles    `json:"subtitles,omitempty"`         // sidecar files and embedded text streams, packaged as text tracks
+	Edits           []contracts.Edit        `json:"edits,omitempty"`             // clips of one or more inputs, rendered back to back into the input that is encoded
+}
+
+// TranscodeJob is the per-representation message published to the workers.
+type TranscodeJob = contracts.TranscodeJob
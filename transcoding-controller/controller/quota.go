@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultTenantID = "default"
+
+// QuotaLimits holds the per-tenant limits enforced on job submission. A zero value disables that limit.
+type QuotaLimits struct {
+	RequestsPerMinute  int
+	MaxActiveJobs      int
+	DailyEncodeMinutes float64
+}
+
+var quotaLimits QuotaLimits
+
+// tenantKeys maps API keys to the tenant they belong to. When it is empty the controller trusts
+// the X-Tenant-ID header, which only a proxy that authenticates clients may set.
+var tenantKeys map[string]string
+
+// QuotaExceededError is returned when a tenant is over one of its limits.
+type QuotaExceededError struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s", e.Limit)
+}
+
+// tokenBucketScript refills and takes ARGV[4] tokens atomically. Returns {allowed, tokens_left, retry_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((cost - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return {allowed, tostring(tokens), retry}
+`)
+
+// reserveActiveJobsScript adds jobs (ARGV[2] onwards) to the tenant's active set only if all of
+// them fit under the limit.
+var reserveActiveJobsScript = redis.NewScript(`
+if redis.call('SCARD', KEYS[1]) + #ARGV - 1 > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SADD', KEYS[1], unpack(ARGV, 2))
+return 1
+`)
+
+// InitQuotas loads per-tenant limits and, from TENANT_API_KEYS ("key=tenant,key=tenant"), the
+// tenants' API keys from the environment.
+func InitQuotas() {
+	quotaLimits = QuotaLimits{
+		RequestsPerMinute:  envInt("TENANT_REQUESTS_PER_MINUTE", 60),
+		MaxActiveJobs:      envInt("TENANT_MAX_ACTIVE_JOBS", 10),
+		DailyEncodeMinutes: envFloat("TENANT_DAILY_ENCODE_MINUTES", 600),
+	}
+	log.Printf("📏 Tenant quotas: %d req/min, %d active jobs, %.0f encoded min/day",
+		quotaLimits.RequestsPerMinute, quotaLimits.MaxActiveJobs, quotaLimits.DailyEncodeMinutes)
+
+	tenantKeys = make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("TENANT_API_KEYS"), ",") {
+		key, tenant, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if pair == "" {
+			continue
+		}
+		if !ok || key == "" || tenant == "" {
+			log.Fatalf("❌ Invalid TENANT_API_KEYS entry %q", pair)
+		}
+		tenantKeys[key] = tenant
+	}
+	if len(tenantKeys) == 0 {
+		log.Printf("⚠️ TENANT_API_KEYS is not set: tenants are taken from the X-Tenant-ID header, which must be set by a trusted proxy")
+	}
+}
+
+func envInt(name string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return v
+	}
+	return fallback
+}
+
+// tenantFromRequest identifies the tenant by the API key in the Authorization header ("Bearer
+// <key>") when TENANT_API_KEYS is set, and otherwise by the X-Tenant-ID header, falling back to
+// "default". It writes a 401 or 400 and returns false if the request does not identify one.
+func tenantFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.URL.Query().Has("tenant") {
+		http.Error(w, "The tenant must be sent in the X-Tenant-ID header", http.StatusBadRequest)
+		return "", false
+	}
+	if len(tenantKeys) > 0 {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		tenant := tenantKeys[strings.TrimSpace(key)]
+		if !ok || tenant == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Missing or unknown API key", http.StatusUnauthorized)
+			return "", false
+		}
+		return tenant, true
+	}
+	if tenant := strings.TrimSpace(r.Header.Get("X-Tenant-ID")); tenant != "" {
+		return tenant, true
+	}
+	return defaultTenantID, true
+}
+
+func rateLimitKey(tenant string) string {
+	return jobHashes.Namespaced(fmt.Sprintf("quota:%s:rpm", tenant))
+}
+
+func activeJobsKey(tenant string) string {
+	return jobHashes.Namespaced(fmt.Sprintf("quota:%s:active_jobs", tenant))
+}
+
+// encodedMinutesKey must match the key the worker increments after each encode.
+func encodedMinutesKey(tenant string, day time.Time) string {
+	return jobHashes.Namespaced(fmt.Sprintf("quota:%s:encoded_minutes:%s", tenant, day.UTC().Format("2006-01-02")))
+}
+
+// CheckRateLimit takes one token per submitted job from the tenant's request bucket.
+func CheckRateLimit(tenant string, jobs int) error {
+	if quotaLimits.RequestsPerMinute <= 0 {
+		return nil
+	}
+	rate := float64(quotaLimits.RequestsPerMinute) / 60
+	res, err := tokenBucketScript.Run(ctx, redisClient, []string{rateLimitKey(tenant)},
+		rate, quotaLimits.RequestsPerMinute, time.Now().UnixMilli(), jobs).Slice()
+	if err != nil {
+		return err
+	}
+	if allowed, _ := res[0].(int64); allowed == 1 {
+		return nil
+	}
+	retryMs, _ := res[2].(int64)
+	return &QuotaExceededError{
+		Limit:      "requests_per_minute",
+		RetryAfter: time.Duration(retryMs) * time.Millisecond,
+	}
+}
+
+// CheckDailyMinutes rejects submissions once the tenant's encoded minutes for today reach the limit.
+func CheckDailyMinutes(tenant string) error {
+	if quotaLimits.DailyEncodeMinutes <= 0 {
+		return nil
+	}
+	used, err := encodedMinutesToday(tenant)
+	if err != nil {
+		return err
+	}
+	if used < quotaLimits.DailyEncodeMinutes {
+		return nil
+	}
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return &QuotaExceededError{
+		Limit:      "encoded_minutes_per_day",
+		RetryAfter: midnight.Sub(now),
+	}
+}
+
+// ReserveActiveJob registers jobID against the tenant's concurrent job limit.
+func ReserveActiveJob(tenant, jobID string) error {
+	return ReserveActiveJobs(tenant, []string{jobID})
+}
+
+// ReserveActiveJobs registers all of jobIDs against the tenant's concurrent job limit, or none of
+// them if they do not all fit.
+func ReserveActiveJobs(tenant string, jobIDs []string) error {
+	if quotaLimits.MaxActiveJobs <= 0 {
+		return nil
+	}
+	if _, err := pruneActiveJobs(tenant); err != nil {
+		return err
+	}
+	args := []interface{}{quotaLimits.MaxActiveJobs}
+	for _, jobID := range jobIDs {
+		args = append(args, jobID)
+	}
+	ok, err := reserveActiveJobsScript.Run(ctx, redisClient, []string{activeJobsKey(tenant)}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return &QuotaExceededError{
+			Limit:      "max_active_jobs",
+			RetryAfter: 30 * time.Second,
+		}
+	}
+	return nil
+}
+
+// ReleaseActiveJob frees a reservation, e.g. when the submission fails after reserving.
+func ReleaseActiveJob(tenant, jobID string) {
+	if err := redisClient.SRem(ctx, activeJobsKey(tenant), jobID).Err(); err != nil {
+		log.Printf("⚠️ Failed to release active job %s for tenant %s: %v", jobID, tenant, err)
+	}
+}
+
+// pruneActiveJobs drops finished jobs, and jobs whose hash has expired, from the tenant's active
+// set and returns the remaining count. A job has a status from the moment it is stored, so a job
+// still waiting for a worker counts as active.
+func pruneActiveJobs(tenant string) (int, error) {
+	key := activeJobsKey(tenant)
+	jobIDs, err := redisClient.SMembers(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	active := 0
+	for _, jobID := range jobIDs {
+		status, err := jobHashes.Status(ctx, jobID)
+		if err != nil {
+			return 0, err
+		}
+		if status == "" {
+			exists, err := redisClient.Exists(ctx, jobHashes.Key(jobID)).Result()
+			if err != nil {
+				return 0, err
+			}
+			if exists == 0 {
+				redisClient.SRem(ctx, key, jobID)
+				continue
+			}
+		}
+		if terminalJobStatuses[status] {
+			redisClient.SRem(ctx, key, jobID)
+			continue
+		}
+		active++
+	}
+	return active, nil
+}
+
+func encodedMinutesToday(tenant string) (float64, error) {
+	used, err := redisClient.Get(ctx, encodedMinutesKey(tenant, time.Now())).Float64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return used, err
+}
+
+// TenantUsage is the response body of GET /usage.
+type TenantUsage struct {
+	TenantID            string  `json:"tenant_id"`
+	RequestsPerMinute   int     `json:"requests_per_minute_limit"`
+	RequestTokensLeft   float64 `json:"request_tokens_left"`
+	ActiveJobs          int     `json:"active_jobs"`
+	MaxActiveJobs       int     `json:"max_active_jobs"`
+	EncodedMinutesToday float64 `json:"encoded_minutes_today"`
+	DailyEncodeMinutes  float64 `json:"daily_encode_minutes_limit"`
+}
+
+// GetTenantUsage reports current usage against each limit without consuming quota.
+func GetTenantUsage(tenant string) (TenantUsage, error) {
+	usage := TenantUsage{
+		TenantID:           tenant,
+		RequestsPerMinute:  quotaLimits.RequestsPerMinute,
+		MaxActiveJobs:      quotaLimits.MaxActiveJobs,
+		DailyEncodeMinutes: quotaLimits.DailyEncodeMinutes,
+	}
+
+	usage.RequestTokensLeft = float64(quotaLimits.RequestsPerMinute)
+	bucket, err := redisClient.HMGet(ctx, rateLimitKey(tenant), "tokens", "ts").Result()
+	if err != nil {
+		return usage, err
+	}
+	if tokensStr, ok := bucket[0].(string); ok {
+		tokens, _ := strconv.ParseFloat(tokensStr, 64)
+		ts, _ := strconv.ParseInt(fmt.Sprint(bucket[1]), 10, 64)
+		elapsed := float64(time.Now().UnixMilli()-ts) / 1000
+		refilled := tokens + elapsed*float64(quotaLimits.RequestsPerMinute)/60
+		if refilled < usage.RequestTokensLeft {
+			usage.RequestTokensLeft = refilled
+		}
+	}
+
+	if usage.ActiveJobs, err = pruneActiveJobs(tenant); err != nil {
+		return usage, err
+	}
+	if usage.EncodedMinutesToday, err = encodedMinutesToday(tenant); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// writeQuotaExceeded replies 429 with a Retry-After header rounded up to whole seconds.
+func writeQuotaExceeded(w http.ResponseWriter, qe *QuotaExceededError) {
+	seconds := int((qe.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprintf(w, `{"error": "quota exceeded", "limit": "%s", "retry_after": %d}`, qe.Limit, seconds)
+}
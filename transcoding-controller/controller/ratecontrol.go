@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"common/contracts"
+)
+
+// maxCRF is the top of each codec's CRF scale; for VVC it is libvvenc's QP.
+var maxCRF = map[string]int{
+	"h264": 51,
+	"hevc": 51,
+	"vvc":  63,
+	"vp9":  63,
+	"av1":  63,
+}
+
+// codecEncoders is the FFmpeg encoder the workers use for each codec.
+var codecEncoders = contracts.DefaultEncoders
+
+// InitEncoders reads AV1_ENCODER, which must match the workers' so that jobs are validated
+// against the encoder that will run them.
+func InitEncoders() {
+	name := os.Getenv("AV1_ENCODER")
+	if name == "" {
+		return
+	}
+	if caps, ok := contracts.Encoders[name]; !ok || caps.Codec != "av1" {
+		log.Fatalf("❌ Invalid AV1_ENCODER %q", name)
+	}
+	codecEncoders = make(map[string]string, len(contracts.DefaultEncoders))
+	for codec, encoder := range contracts.DefaultEncoders {
+		codecEncoders[codec] = encoder
+	}
+	codecEncoders["av1"] = name
+}
+
+// validateRateControl refuses a rate control mode the codec's encoder cannot do, which would
+// otherwise only fail once a worker builds its arguments.
+func validateRateControl(codec, mode string) string {
+	encoder := codecEncoders[codec]
+	if !contracts.Encoders[encoder].SupportsRateControl(mode) {
+		return fmt.Sprintf("rate_control %s is not supported with %s (encoded with %s)", mode, codec, encoder)
+	}
+	return ""
+}
+
+// vbrPeakRatio is how far constrained VBR and capped CRF may peak above a rung's bitrate.
+const vbrPeakRatio = 1.5
+
+// rungVBV returns the peak rate and VBV buffer for one ladder rung: CBR peaks at the rung's
+// bitrate, constrained VBR and capped CRF at vbrPeakRatio times it, and the buffer holds two
+// seconds at the peak. Modes without a VBV constraint get neither.
+func rungVBV(mode, bitrate string) (maxrate, bufsize string) {
+	kbps, err := parseKbps(bitrate)
+	if err != nil {
+		return "", ""
+	}
+
+	var peak int
+	switch mode {
+	case contracts.RateControlCBR:
+		peak = kbps
+	case contracts.RateControlVBR, contracts.RateControlCRF:
+		peak = int(float64(kbps) * vbrPeakRatio)
+	default:
+		return "", ""
+	}
+	return fmt.Sprintf("%dk", peak), fmt.Sprintf("%dk", 2*peak)
+}
+
+// parseKbps reads an FFmpeg bitrate such as "2500k" or "4M" in kbit/s.
+func parseKbps(bitrate string) (int, error) {
+	multiplier := 1
+	value := strings.ToLower(bitrate)
+	switch {
+	case strings.HasSuffix(value, "k"):
+		value = strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		value = strings.TrimSuffix(value, "m")
+		multiplier = 1000
+	default:
+		n, err := strconv.Atoi(value)
+		return n / 1000, err
+	}
+	n, err := strconv.Atoi(value)
+	return n * multiplier, err
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+
+	"common/jobhash"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient *redis.Client
+	jobHashes   *jobhash.Store
+	ctx         = context.Background()
+)
+
+// InitRedis initializes the Redis client using REDIS_ADDR or defaults to localhost
+func InitRedis() {
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+	log.Printf("🔗 Connecting to Redis at: %s", redisAddr)
+
+	redisClient = redis.NewClient(&redis.Options{
+		Addr: redisAddr,
+	})
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		log.Fatalf("❌ Could not connect to Redis: %v", err)
+	}
+	jobHashes = jobhash.New(redisClient)
+	log.Println("✅ Redis connection successful")
+}
+
+// StoreJobMetadata saves the TranscodeRequest in the job hash and adds the job to the active index
+func StoreJobMetadata(jobID, tenantID string, req TranscodeRequest) error {
+	key := jobHashes.Key(jobID)
+	log.Printf("🔄 Storing job metadata with key: %s", key)
+
+	// Normalize all resolutions to lowercase
+	for i, res := range req.Resolutions {
+		req.Resolutions[i] = strings.ToLower(res)
+	}
+
+	requiredRes := strings.Join(dispatchedResolutions(req), ",")
+
+	data := map[string]interface{}{
+		jobhash.FieldStreamName:          req.StreamName,
+		jobhash.FieldInputURL:            req.InputURL,
+		jobhash.FieldCodec:               req.Codec,
+		jobhash.FieldRequiredResolutions: requiredRes,
+		jobhash.FieldTenantID:            tenantID,
+		jobhash.FieldPriority:            req.Priority,
+		// Until a worker picks it up, or a batch job is dispatched
+		jobhash.FieldStatus: "waiting",
+	}
+	if req.Thumbnails != nil {
+		data[jobhash.FieldThumbnails] = "queued"
+	}
+	if req.Subtitles != nil {
+		data[jobhash.FieldSubtitles] = "queued"
+	}
+	if req.Ladder == LadderAuto || req.ChunkSeconds > 0 || len(req.Edits) > 0 {
+		// Kept until the edit is rendered, the rungs are chosen or the input is cut, so they are
+		// dispatched with the job's settings
+		raw, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		data[jobhash.FieldRequest] = string(raw)
+	}
+
+	if err := jobHashes.Create(ctx, jobID, data); err != nil {
+		log.Printf("❌ Redis HSET error: %v", err)
+		return err
+	}
+
+	log.Printf("✅ Job metadata stored in Redis hash for jobID %s with required_resolutions: %s", jobID, requiredRes)
+	return nil
+}
+
+// MarkJobCancelled flags a job so workers skip its pending representations and the tracker stops promoting it.
+// A job that has already failed, been cancelled or finished encoding keeps its status.
+func MarkJobCancelled(jobID, reason string) {
+	from, ok, err := jobHashes.TransitionUnless(ctx, jobID, jobhash.FieldStatus, "cancelled",
+		append([]string{"done"}, jobhash.FinalStatuses...))
+	if err != nil {
+		log.Printf("⚠️ Failed to mark job %s cancelled in Redis: %v", jobID, err)
+		return
+	}
+	if !ok {
+		log.Printf("⏭️ Job %s is %s, not cancelling it", jobID, from)
+		return
+	}
+	PublishStatusEvent(jobID, from, "cancelled", reason)
+	jobHashes.Finish(ctx, jobID)
+}
+
+// MarkRepresentationQueued records a dispatched representation's target so the tracker can persist it before a worker picks it up.
+func MarkRepresentationQueued(jobID, rep, resolution, bitrate string) {
+	err := jobHashes.Set(ctx, jobID,
+		rep, "queued",
+		jobhash.RepField(rep, jobhash.RepResolution), resolution,
+		jobhash.RepField(rep, jobhash.RepBitrate), bitrate,
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to mark %s queued for job %s: %v", rep, jobID, err)
+	}
+}
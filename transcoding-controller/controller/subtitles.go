@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"common/contracts"
+)
+
+// maxSubtitleSidecars caps the sidecar files of one job, each of which becomes a track in the
+// manifest.
+const maxSubtitleSidecars = 32
+
+// subtitleLanguage is a BCP 47 tag as players expect it: a two- or three-letter language,
+// optionally followed by a script, region or variant, e.g. en, pt-BR or zh-Hant.
+var subtitleLanguage = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// validateSubtitles checks the subtitle options and fills in the default format, side-loaded
+// WebVTT, which every player supports.
+func validateSubtitles(req *TranscodeRequest) string {
+	s := req.Subtitles
+	if s == nil {
+		return ""
+	}
+	if err := s.Validate(); err != nil {
+		return "Invalid subtitles: " + err.Error()
+	}
+	if s.Format == "" {
+		s.Format = contracts.SubtitleWebVTT
+	}
+	if len(s.Sidecars) > maxSubtitleSidecars {
+		return fmt.Sprintf("subtitles may have at most %d sidecars", maxSubtitleSidecars)
+	}
+	for i, sc := range s.Sidecars {
+		if !subtitleLanguage.MatchString(sc.Language) {
+			return fmt.Sprintf("subtitles sidecars[%d]: language %q is not a BCP 47 tag", i, sc.Language)
+		}
+	}
+	return ""
+}
+
+// DispatchSubtitles asks a worker to convert the job's subtitles. Like thumbnails they come from
+// the input, so they are converted alongside the renditions whatever the ladder.
+func DispatchSubtitles(jobID string, req TranscodeRequest) {
+	payload, err := contracts.Encode(&contracts.SubtitleJob{
+		JobID:     jobID,
+		InputURL:  req.InputURL,
+		Subtitles: *req.Subtitles,
+	})
+	if err == nil {
+		err = msgBus.Publish(ctx, contracts.TopicSubtitles, []byte(jobID), payload)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to publish subtitles for job %s: %v", jobID, err)
+		return
+	}
+	log.Printf("✅ Published subtitles for job %s (%d sidecars, embedded %t, %s)", jobID,
+		len(req.Subtitles.Sidecars), req.Subtitles.Embedded, req.Subtitles.Format)
+}
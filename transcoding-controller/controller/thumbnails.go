@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+
+	"common/contracts"
+)
+
+// Thumbnail defaults: a 160px thumbnail every 10s, 25 to a sprite sheet, which is the size most
+// players' scrubbing previews are drawn at.
+const (
+	defaultThumbnailInterval = 10
+	defaultThumbnailWidth    = 160
+	defaultSpriteTiles       = 5
+)
+
+// Thumbnail limits keep a sprite sheet small enough for a player to fetch while scrubbing.
+const (
+	minThumbnailInterval = 1
+	minThumbnailWidth    = 32
+	maxThumbnailWidth    = 640
+	maxSpriteTiles       = 10
+)
+
+// validateThumbnails checks the thumbnail options and fills in the defaults.
+func validateThumbnails(req *TranscodeRequest) string {
+	t := req.Thumbnails
+	if t == nil {
+		return ""
+	}
+	if err := t.Validate(); err != nil {
+		return "Invalid thumbnails: " + err.Error()
+	}
+	if t.Interval == 0 {
+		t.Interval = defaultThumbnailInterval
+	}
+	if t.Width == 0 {
+		t.Width = defaultThumbnailWidth
+	}
+	if t.Columns == 0 {
+		t.Columns = defaultSpriteTiles
+	}
+	if t.Rows == 0 {
+		t.Rows = defaultSpriteTiles
+	}
+	switch {
+	case t.Interval < minThumbnailInterval:
+		return fmt.Sprintf("thumbnails interval must be at least %ds", minThumbnailInterval)
+	case t.Width < minThumbnailWidth || t.Width > maxThumbnailWidth || t.Width%2 != 0:
+		return fmt.Sprintf("thumbnails width must be an even number between %d and %d", minThumbnailWidth, maxThumbnailWidth)
+	case t.Columns > maxSpriteTiles || t.Rows > maxSpriteTiles:
+		return fmt.Sprintf("thumbnails columns and rows must be at most %d", maxSpriteTiles)
+	}
+	return ""
+}
+
+// DispatchThumbnails asks a worker to generate the job's poster and thumbnails. They are made
+// from the input, so they are generated alongside the renditions whatever the ladder.
+func DispatchThumbnails(jobID string, req TranscodeRequest) {
+	payload, err := contracts.Encode(&contracts.ThumbnailJob{
+		JobID:      jobID,
+		InputURL:   req.InputURL,
+		Thumbnails: *req.Thumbnails,
+	})
+	if err == nil {
+		err = msgBus.Publish(ctx, contracts.TopicThumbnails, []byte(jobID), payload)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to publish thumbnails for job %s: %v", jobID, err)
+		return
+	}
+	log.Printf("✅ Published thumbnails for job %s (every %gs, %dpx)", jobID, req.Thumbnails.Interval, req.Thumbnails.Width)
+}